@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"weirdstats/internal/config"
+)
+
+// runCheckConfig implements `weirdstats check-config`: report which envs are
+// set and fail when webhook auto-registration cannot work.
+func runCheckConfig(cfg config.Config, w io.Writer) error {
+	present := []struct {
+		key string
+		set bool
+	}{
+		{"STRAVA_CLIENT_ID", cfg.StravaClientID != ""},
+		{"STRAVA_CLIENT_SECRET", cfg.StravaClientSecret != ""},
+		{"STRAVA_REFRESH_TOKEN", cfg.StravaRefreshToken != ""},
+		{"STRAVA_ACCESS_TOKEN", cfg.StravaAccessToken != ""},
+		{"BASE_URL", cfg.BaseURL != ""},
+		{"STRAVA_VERIFY_TOKEN", cfg.StravaVerifyToken != ""},
+		{"STRAVA_WEBHOOK_SECRET", cfg.StravaWebhookSecret != ""},
+		{"SESSION_SECRET", cfg.SessionSecret != ""},
+	}
+	for _, env := range present {
+		fmt.Fprintf(w, "%-28s %t\n", env.key, env.set)
+	}
+	fmt.Fprintf(w, "%-28s %t\n", "STRAVA_WEBHOOK_AUTO_REGISTER", cfg.StravaWebhookAutoRegister)
+
+	if !cfg.StravaWebhookAutoRegister {
+		return nil
+	}
+	if missing := missingWebhookEnvs(cfg); len(missing) > 0 {
+		return fmt.Errorf("webhook auto-register enabled but missing: %s", strings.Join(missing, ", "))
+	}
+	fmt.Fprintln(w, "webhook auto-register ready")
+	return nil
+}
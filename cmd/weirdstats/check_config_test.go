@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"weirdstats/internal/config"
+)
+
+func TestMissingWebhookEnvs(t *testing.T) {
+	complete := config.Config{
+		BaseURL:            "https://weirdstats.example",
+		StravaVerifyToken:  "verify",
+		StravaClientID:     "id",
+		StravaClientSecret: "secret",
+	}
+	if missing := missingWebhookEnvs(complete); len(missing) != 0 {
+		t.Fatalf("expected nothing missing, got %v", missing)
+	}
+
+	partial := complete
+	partial.BaseURL = ""
+	partial.StravaClientSecret = ""
+	want := []string{"BASE_URL", "STRAVA_CLIENT_SECRET"}
+	if got := missingWebhookEnvs(partial); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	cfg := config.Config{StravaClientID: "id"}
+	if err := runCheckConfig(cfg, io.Discard); err != nil {
+		t.Fatalf("expected success without auto-register, got %v", err)
+	}
+	cfg.StravaWebhookAutoRegister = true
+	if err := runCheckConfig(cfg, io.Discard); err == nil {
+		t.Fatalf("expected error when auto-register lacks required envs")
+	}
+}
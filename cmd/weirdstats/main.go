@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -27,27 +29,47 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "process" {
+		if err := runProcess(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("process: %v", err)
+		}
+		return
+	}
+
 	cfg, err := config.Load(".env")
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		if err := runCheckConfig(cfg, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	logStartupConfig(cfg)
 
-	store, err := storage.Open(cfg.DatabasePath)
+	store, err := storage.OpenWithPragmas(cfg.DatabasePath, cfg.DatabasePragmas)
 	if err != nil {
 		log.Fatalf("open db: %v", err)
 	}
 	defer store.Close()
 
+	// Two background loops: the queue worker and the job runner.
+	readiness := web.NewReadiness(2)
 	if err := store.InitSchema(context.Background()); err != nil {
 		log.Fatalf("init schema: %v", err)
 	}
+	readiness.MarkSchemaReady()
 
 	seedStravaToken(store, cfg)
 
+	rateLimits := &strava.RateLimitTracker{}
 	stravaClient := &strava.Client{
 		BaseURL:     cfg.StravaBaseURL,
 		AccessToken: cfg.StravaAccessToken,
+		RateLimits:  rateLimits,
+		UserAgent:   cfg.UserAgent,
 	}
 	if cfg.StravaRefreshToken != "" || (cfg.StravaClientID != "" && cfg.StravaClientSecret != "") {
 		stravaClient.TokenSource = &strava.RefreshTokenSource{
@@ -56,6 +78,8 @@ func main() {
 			ClientID:     cfg.StravaClientID,
 			ClientSecret: cfg.StravaClientSecret,
 			BaseURL:      cfg.StravaAuthBaseURL,
+			APIBaseURL:   cfg.StravaBaseURL,
+			UserAgent:    cfg.UserAgent,
 		}
 	}
 	stravaFactory := &strava.ClientFactory{
@@ -64,22 +88,39 @@ func main() {
 		AuthBaseURL:  cfg.StravaAuthBaseURL,
 		ClientID:     cfg.StravaClientID,
 		ClientSecret: cfg.StravaClientSecret,
+		RateLimits:   rateLimits,
+		UserAgent:    cfg.UserAgent,
+	}
+	ingestor := &ingest.Ingestor{
+		Store:              store,
+		Strava:             stravaClient,
+		Clients:            stravaFactory,
+		SimplifyToleranceM: float64(cfg.SimplifyToleranceM),
+		StopSpeedThreshold: cfg.StopSpeedThreshold,
 	}
-	ingestor := &ingest.Ingestor{Store: store, Strava: stravaClient, Clients: stravaFactory}
-	overpassClient := &maps.OverpassClient{
-		BaseURL:    cfg.OverpassURL,
-		MirrorURLs: cfg.OverpassURLs,
-		Timeout:    time.Duration(cfg.OverpassTimeoutSec) * time.Second,
-		CacheTTL:   time.Duration(cfg.OverpassCacheHours) * time.Hour,
+	mapAPI, overpassClient, err := newMapClients(cfg)
+	if err != nil {
+		log.Fatalf("map clients: %v", err)
 	}
 
-	stopOpts := gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 3 * time.Second, GlitchTolerance: 10 * time.Second}
-	var mapAPI maps.API = overpassClient
+	stopOpts := gps.StopOptions{
+		SpeedThreshold:  cfg.StopSpeedThreshold,
+		MinDuration:     time.Duration(cfg.StopMinDurationSec) * time.Second,
+		GlitchTolerance: 10 * time.Second,
+	}
 	statsProcessor := &processor.StopStatsProcessor{
-		Store:    store,
-		MapAPI:   mapAPI,
-		Overpass: overpassClient,
-		Options:  stopOpts,
+		Store:                 store,
+		MapAPI:                mapAPI,
+		Overpass:              overpassClient,
+		Options:               stopOpts,
+		PrefetchSignals:       cfg.OverpassPrefetchSignals,
+		MaxLookupsPerActivity: cfg.OverpassMaxLookups,
+		MatchRadiusMeters:     float64(cfg.TrafficLightMatchRadiusM),
+		ClimbNoiseThresholdM:  float64(cfg.ClimbNoiseThresholdM),
+		Effort: processor.EffortOptions{
+			HRWindow:          cfg.EffortHRWindow,
+			HRRecencyWeighted: cfg.EffortHRReference == "decay",
+		},
 	}
 	rulesProcessor := &processor.RulesProcessor{
 		Store:    store,
@@ -94,36 +135,47 @@ func main() {
 		Processor:    pipeline,
 		PollInterval: time.Duration(cfg.WorkerPollIntervalMS) * time.Millisecond,
 		StaleAfter:   10 * time.Minute,
+		RateLimits:   rateLimits,
 	}
 
 	webServer, err := web.NewServer(store, ingestor, mapAPI, overpassClient, stopOpts, web.StravaConfig{
-		ClientID:             cfg.StravaClientID,
-		ClientSecret:         cfg.StravaClientSecret,
-		AuthBaseURL:          cfg.StravaAuthBaseURL,
-		RedirectURL:          cfg.StravaRedirectURL,
-		MobileRedirectURL:    cfg.StravaMobileRedirectURL,
-		MobileAppRedirectURL: cfg.MobileAppRedirectURL,
-		InitialSyncDays:      cfg.StravaInitialSyncDays,
-		Clients:              stravaFactory,
-		SessionSecret:        cfg.SessionSecret,
+		ClientID:                cfg.StravaClientID,
+		ClientSecret:            cfg.StravaClientSecret,
+		AuthBaseURL:             cfg.StravaAuthBaseURL,
+		RedirectURL:             cfg.StravaRedirectURL,
+		MobileRedirectURL:       cfg.StravaMobileRedirectURL,
+		MobileAppRedirectURL:    cfg.MobileAppRedirectURL,
+		InitialSyncDays:         cfg.StravaInitialSyncDays,
+		Clients:                 stravaFactory,
+		SessionSecret:           cfg.SessionSecret,
+		UserAgent:               cfg.UserAgent,
+		DisableDescriptionWrite: !cfg.StravaWriteDescription,
 	})
 	if err != nil {
 		log.Fatalf("load templates: %v", err)
 	}
 	statsProcessor.Facts = webServer
+	webServer.SetStatsRecomputer(statsProcessor)
 	pipeline.Applier = webServer
 	jobRunner.Applier = webServer
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", webServer.Landing)
-	mux.HandleFunc("/connect/strava", webServer.ConnectStrava)
-	mux.HandleFunc("/connect/strava/callback", webServer.StravaCallback)
-	mux.HandleFunc("/connect/strava/mobile", webServer.ConnectStravaMobile)
-	mux.HandleFunc("/connect/strava/mobile/callback", webServer.StravaMobileCallback)
+	oauthLimiter := web.NewRateLimiter(cfg.OAuthRateLimitPerMinute, cfg.OAuthRateLimitBurst)
+	if oauthLimiter != nil {
+		oauthLimiter.TrustedProxies = cfg.TrustedProxies
+	}
+	mux.Handle("/connect/strava", oauthLimiter.Middleware(http.HandlerFunc(webServer.ConnectStrava)))
+	mux.Handle("/connect/strava/callback", oauthLimiter.Middleware(http.HandlerFunc(webServer.StravaCallback)))
+	mux.Handle("/connect/strava/mobile", oauthLimiter.Middleware(http.HandlerFunc(webServer.ConnectStravaMobile)))
+	mux.Handle("/connect/strava/mobile/callback", oauthLimiter.Middleware(http.HandlerFunc(webServer.StravaMobileCallback)))
 	mux.HandleFunc("/activities", webServer.Activities)
 	mux.HandleFunc("/activities/", webServer.Activities)
 	mux.HandleFunc("/activities/settings", webServer.Settings)
+	mux.HandleFunc("/activities/export.csv", webServer.ExportCSV)
 	mux.HandleFunc("/api/rules/metadata", webServer.RulesMetadata)
+	mux.HandleFunc("/api/rules/preview", webServer.RulePreview)
+	mux.HandleFunc("/api/summary", webServer.SummaryJSON)
 	mux.HandleFunc("/api/mobile/session/exchange", webServer.MobileSessionExchange)
 	mux.HandleFunc("/api/mobile/me", webServer.MobileMe)
 	mux.HandleFunc("/api/mobile/activities", webServer.MobileActivities)
@@ -136,18 +188,13 @@ func main() {
 		Store:         store,
 		VerifyToken:   cfg.StravaVerifyToken,
 		SigningSecret: cfg.StravaWebhookSecret,
+		MaxEventAge:   time.Duration(cfg.StravaWebhookMaxAgeSec) * time.Second,
+		MaxBodyBytes:  cfg.StravaWebhookMaxBodyBytes,
 	})
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
-	})
+	mux.HandleFunc("/healthz", webServer.Healthz)
+	mux.Handle("/readyz", readiness)
 
-	server := &http.Server{
-		Addr:         cfg.ServerAddr,
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-	}
+	server := newHTTPServer(cfg, web.AccessLog(web.Gzip(mux), cfg.AccessLog, nil))
 
 	listener, err := net.Listen("tcp", cfg.ServerAddr)
 	if err != nil {
@@ -165,13 +212,99 @@ func main() {
 	}()
 
 	go ensureWebhookSubscription(ctx, cfg)
-	go runWorker(ctx, queueWorker, time.Duration(cfg.WorkerPollIntervalMS)*time.Millisecond)
-	go runJobRunner(ctx, jobRunner)
+	requeueStaleStats(ctx, store, statsProcessor.OptionsHash(), processor.StatsVersion)
+	workCtx, cancelWork := drainContext(ctx, shutdownDrainTimeout)
+	defer cancelWork()
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		runWorker(ctx, workCtx, queueWorker, time.Duration(cfg.WorkerPollIntervalMS)*time.Millisecond, readiness.WorkerStarted())
+	}()
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		runJobRunner(ctx, workCtx, jobRunner, readiness.WorkerStarted())
+	}()
+
+	go runRetention(ctx, store, cfg)
 
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = server.Shutdown(shutdownCtx)
+	if !waitForDrain(shutdownDrainTimeout, workerDone, jobsDone) {
+		log.Printf("shutdown: background work still running after %s, cancelling it", shutdownDrainTimeout)
+		cancelWork()
+		if !waitForDrain(shutdownCancelGrace, workerDone, jobsDone) {
+			log.Printf("shutdown: background work ignored cancellation, closing the store anyway")
+		}
+	}
+}
+
+const (
+	// shutdownDrainTimeout bounds how long shutdown waits for an in-flight
+	// activity or job to finish before its context is cancelled.
+	shutdownDrainTimeout = 30 * time.Second
+	// shutdownCancelGrace is how long cancelled work gets to unwind before the
+	// store is closed underneath it.
+	shutdownCancelGrace = 5 * time.Second
+)
+
+// drainContext returns a context for in-flight work that outlives ctx by up
+// to timeout: it is not cancelled when ctx is, but timeout after that.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	workCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	var timer *time.Timer
+	var mu sync.Mutex
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		timer = time.AfterFunc(timeout, cancel)
+	})
+	return workCtx, func() {
+		stop()
+		mu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		mu.Unlock()
+		cancel()
+	}
+}
+
+// waitForDrain waits for every done channel to close and reports whether
+// they all did before the timeout.
+func waitForDrain(timeout time.Duration, done ...<-chan struct{}) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for _, ch := range done {
+		select {
+		case <-ch:
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
+}
+
+// maxReadHeaderTimeout bounds how long a client may take to send headers,
+// independent of the (possibly long) body read timeout for uploads.
+const maxReadHeaderTimeout = 5 * time.Second
+
+func newHTTPServer(cfg config.Config, handler http.Handler) *http.Server {
+	readTimeout := time.Duration(cfg.ServerReadTimeoutSec) * time.Second
+	readHeaderTimeout := maxReadHeaderTimeout
+	if readTimeout > 0 && readTimeout < readHeaderTimeout {
+		readHeaderTimeout = readTimeout
+	}
+	return &http.Server{
+		Addr:              cfg.ServerAddr,
+		Handler:           handler,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      time.Duration(cfg.ServerWriteTimeoutSec) * time.Second,
+		IdleTimeout:       time.Duration(cfg.ServerIdleTimeoutSec) * time.Second,
+	}
 }
 
 func seedStravaToken(store *storage.Store, cfg config.Config) {
@@ -198,7 +331,9 @@ func seedStravaToken(store *storage.Store, cfg config.Config) {
 	}
 }
 
-func runWorker(ctx context.Context, queueWorker *worker.Worker, idleDelay time.Duration) {
+// runWorker polls until ctx is cancelled. Each item runs under workCtx so the
+// one already in hand can finish while shutdown drains.
+func runWorker(ctx, workCtx context.Context, queueWorker *worker.Worker, idleDelay time.Duration, looped func()) {
 	if idleDelay <= 0 {
 		idleDelay = 2 * time.Second
 	}
@@ -227,7 +362,8 @@ func runWorker(ctx context.Context, queueWorker *worker.Worker, idleDelay time.D
 		default:
 		}
 
-		processed, err := queueWorker.ProcessNext(ctx)
+		processed, err := queueWorker.ProcessNext(workCtx)
+		looped()
 		if err != nil {
 			if strava.IsRateLimited(err) {
 				fallback := nextBackoff(rateLimitBackoff)
@@ -258,7 +394,7 @@ func runWorker(ctx context.Context, queueWorker *worker.Worker, idleDelay time.D
 	}
 }
 
-func runJobRunner(ctx context.Context, runner *jobs.Runner) {
+func runJobRunner(ctx, workCtx context.Context, runner *jobs.Runner, looped func()) {
 	idleDelay := runner.PollInterval
 	if idleDelay <= 0 {
 		idleDelay = 2 * time.Second
@@ -271,7 +407,8 @@ func runJobRunner(ctx context.Context, runner *jobs.Runner) {
 		default:
 		}
 
-		processed, err := runner.ProcessNext(ctx)
+		processed, err := runner.ProcessNext(workCtx)
+		looped()
 		if err != nil {
 			log.Printf("job runner error: %v", err)
 		}
@@ -285,38 +422,153 @@ func runJobRunner(ctx context.Context, runner *jobs.Runner) {
 	}
 }
 
-func logStartupConfig(cfg config.Config) {
-	hasClientID := cfg.StravaClientID != ""
-	hasClientSecret := cfg.StravaClientSecret != ""
-	hasRefreshToken := cfg.StravaRefreshToken != ""
-	hasAccessToken := cfg.StravaAccessToken != ""
-	hasBaseURL := cfg.BaseURL != ""
-	hasVerifyToken := cfg.StravaVerifyToken != ""
-	hasWebhookSecret := cfg.StravaWebhookSecret != ""
+const retentionInterval = 6 * time.Hour
 
-	log.Printf("strava env: client_id=%t client_secret=%t refresh_token=%t access_token=%t",
-		hasClientID, hasClientSecret, hasRefreshToken, hasAccessToken)
-	log.Printf("webhook env: auto_register=%t base_url=%t verify_token=%t client_credentials=%t signing_secret=%t",
-		cfg.StravaWebhookAutoRegister, hasBaseURL, hasVerifyToken, hasClientID && hasClientSecret, hasWebhookSecret)
+// runRetention periodically deletes bookkeeping rows past their retention
+// window. A zero retention keeps rows forever.
+func runRetention(ctx context.Context, store *storage.Store, cfg config.Config) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		purgeExpired(ctx, store, cfg, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-	if cfg.StravaWebhookAutoRegister {
-		var missing []string
-		if !hasBaseURL {
-			missing = append(missing, "BASE_URL")
+// staleStatsVersionLimit caps how many activities with outdated stats are
+// re-enqueued per startup, newest first, so a StatsVersion bump does not
+// flood the queue with the whole history at once.
+const staleStatsVersionLimit = 500
+
+// requeueStaleStats enqueues every activity whose stats were computed with
+// different options, e.g. after STOP_SPEED_THRESHOLD,
+// TRAFFIC_LIGHT_MATCH_RADIUS_M or CLIMB_NOISE_THRESHOLD_M changed, plus up to staleStatsVersionLimit
+// activities whose stats predate statsVersion. It returns how many activities
+// were enqueued.
+func requeueStaleStats(ctx context.Context, store *storage.Store, optionsHash string, statsVersion int) int {
+	stale, err := store.ListStaleStatsActivities(ctx, optionsHash)
+	if err != nil {
+		log.Printf("stale stats: list: %v", err)
+		return 0
+	}
+	outdated, err := store.ListActivitiesBelowStatsVersion(ctx, statsVersion, staleStatsVersionLimit)
+	if err != nil {
+		log.Printf("stale stats: list below version %d: %v", statsVersion, err)
+	}
+	seen := make(map[int64]bool, len(stale)+len(outdated))
+	enqueued := 0
+	for _, item := range append(stale, outdated...) {
+		if seen[item.ActivityID] {
+			continue
+		}
+		seen[item.ActivityID] = true
+		if err := store.EnqueueActivity(ctx, item.ActivityID, item.UserID); err != nil {
+			log.Printf("stale stats: enqueue activity %d: %v", item.ActivityID, err)
+			continue
 		}
-		if !hasVerifyToken {
-			missing = append(missing, "STRAVA_VERIFY_TOKEN")
+		enqueued++
+	}
+	if enqueued > 0 {
+		log.Printf("stale stats: stats options or version changed, re-enqueued %d activities", enqueued)
+	}
+	return enqueued
+}
+
+func purgeExpired(ctx context.Context, store *storage.Store, cfg config.Config, now time.Time) {
+	if cfg.QueueRetentionDays > 0 {
+		purged, err := store.PurgeFinishedJobs(ctx, now.AddDate(0, 0, -cfg.QueueRetentionDays))
+		if err != nil {
+			log.Printf("retention: purge finished jobs: %v", err)
+		} else if purged > 0 {
+			log.Printf("retention: purged %d finished jobs", purged)
 		}
-		if !hasClientID {
-			missing = append(missing, "STRAVA_CLIENT_ID")
+	}
+	if cfg.WebhookRetentionDays > 0 {
+		purged, err := store.PurgeWebhookEvents(ctx, now.AddDate(0, 0, -cfg.WebhookRetentionDays))
+		if err != nil {
+			log.Printf("retention: purge webhook events: %v", err)
+		} else if purged > 0 {
+			log.Printf("retention: purged %d webhook events", purged)
 		}
-		if !hasClientSecret {
-			missing = append(missing, "STRAVA_CLIENT_SECRET")
+	}
+}
+
+// newMapClients returns nil clients when DISABLE_OVERPASS is set so the
+// pipeline counts stops without making any network calls. MAP_SIGNALS_FILE
+// replaces per-stop Overpass lookups with a local signal list.
+func newMapClients(cfg config.Config) (maps.API, *maps.OverpassClient, error) {
+	var mapAPI maps.API
+	if cfg.MapSignalsFile != "" {
+		fileAPI, err := maps.LoadFileAPI(cfg.MapSignalsFile)
+		if err != nil {
+			return nil, nil, err
 		}
-		if len(missing) == 0 {
+		if cfg.OverpassRadiusMeters > 0 {
+			fileAPI.RadiusMeters = float64(cfg.OverpassRadiusMeters)
+		}
+		mapAPI = fileAPI
+	}
+	if cfg.DisableOverpass {
+		return mapAPI, nil, nil
+	}
+	client := newOverpassClient(cfg)
+	if mapAPI == nil {
+		mapAPI = client
+	}
+	return mapAPI, client, nil
+}
+
+func newOverpassClient(cfg config.Config) *maps.OverpassClient {
+	return &maps.OverpassClient{
+		BaseURL:            cfg.OverpassURL,
+		MirrorURLs:         cfg.OverpassURLs,
+		Timeout:            time.Duration(cfg.OverpassTimeoutSec) * time.Second,
+		CacheTTL:           time.Duration(cfg.OverpassCacheHours) * time.Hour,
+		MaxAttempts:        cfg.OverpassMaxAttempts,
+		BackoffBase:        time.Duration(cfg.OverpassBackoffMS) * time.Millisecond,
+		SearchRadiusMeters: cfg.OverpassRadiusMeters,
+		UserAgent:          cfg.UserAgent,
+	}
+}
+
+func logStartupConfig(cfg config.Config) {
+	log.Printf("strava env: client_id=%t client_secret=%t refresh_token=%t access_token=%t",
+		cfg.StravaClientID != "", cfg.StravaClientSecret != "", cfg.StravaRefreshToken != "", cfg.StravaAccessToken != "")
+	log.Printf("webhook env: auto_register=%t base_url=%t verify_token=%t client_credentials=%t signing_secret=%t",
+		cfg.StravaWebhookAutoRegister, cfg.BaseURL != "", cfg.StravaVerifyToken != "",
+		cfg.StravaClientID != "" && cfg.StravaClientSecret != "", cfg.StravaWebhookSecret != "")
+	if cfg.DisableOverpass {
+		log.Printf("overpass disabled: stops are counted without traffic light or road crossing lookups")
+	}
+
+	if cfg.StravaWebhookAutoRegister {
+		if missing := missingWebhookEnvs(cfg); len(missing) == 0 {
 			log.Printf("webhook auto-register ready")
 		} else {
 			log.Printf("webhook auto-register missing envs: %s", strings.Join(missing, ", "))
 		}
 	}
 }
+
+// missingWebhookEnvs lists the env vars webhook auto-registration needs but
+// that are not set.
+func missingWebhookEnvs(cfg config.Config) []string {
+	var missing []string
+	if cfg.BaseURL == "" {
+		missing = append(missing, "BASE_URL")
+	}
+	if cfg.StravaVerifyToken == "" {
+		missing = append(missing, "STRAVA_VERIFY_TOKEN")
+	}
+	if cfg.StravaClientID == "" {
+		missing = append(missing, "STRAVA_CLIENT_ID")
+	}
+	if cfg.StravaClientSecret == "" {
+		missing = append(missing, "STRAVA_CLIENT_SECRET")
+	}
+	return missing
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"weirdstats/internal/config"
+	"weirdstats/internal/gps"
+	"weirdstats/internal/maps"
+	"weirdstats/internal/processor"
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+	"weirdstats/internal/worker"
+)
+
+func TestNewOverpassClientAppliesTuning(t *testing.T) {
+	client := newOverpassClient(config.Config{
+		OverpassURL:          "http://overpass.test",
+		OverpassTimeoutSec:   7,
+		OverpassMaxAttempts:  2,
+		OverpassBackoffMS:    150,
+		OverpassRadiusMeters: 25,
+	})
+
+	if client.BaseURL != "http://overpass.test" || client.Timeout != 7*time.Second {
+		t.Fatalf("unexpected base settings: %+v", client)
+	}
+	if client.MaxAttempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", client.MaxAttempts)
+	}
+	if client.BackoffBase != 150*time.Millisecond {
+		t.Fatalf("expected 150ms backoff, got %s", client.BackoffBase)
+	}
+	if client.SearchRadiusMeters != 25 {
+		t.Fatalf("expected 25m radius, got %d", client.SearchRadiusMeters)
+	}
+}
+
+func TestNewMapClientsDisableOverpassSkipsLookups(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"elements":[{"type":"node","id":1,"lat":40.0,"lon":-73.0,"tags":{"highway":"traffic_signals"}}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	store, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	now := time.Now().Truncate(time.Second)
+	points := []gps.Point{
+		{Lat: 40.0, Lon: -73.0, Time: now, Speed: 3.0},
+		{Lat: 40.0, Lon: -73.0, Time: now.Add(10 * time.Second), Speed: 0},
+		{Lat: 40.0, Lon: -73.0, Time: now.Add(50 * time.Second), Speed: 0},
+		{Lat: 40.0001, Lon: -73.0001, Time: now.Add(60 * time.Second), Speed: 3.0},
+	}
+	activityID, err := store.InsertActivity(ctx, storage.Activity{UserID: 1, Type: "Ride", Name: "No Overpass", StartTime: now, Distance: 500}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	// The enabled run shows the server's signal would be counted, so the
+	// disabled run's zero count and missing requests come from the flag.
+	for _, disabled := range []bool{false, true} {
+		mapAPI, overpassClient, err := newMapClients(config.Config{OverpassURL: server.URL, DisableOverpass: disabled})
+		if err != nil {
+			t.Fatalf("disabled=%v: new map clients: %v", disabled, err)
+		}
+		if disabled && (mapAPI != nil || overpassClient != nil) {
+			t.Fatalf("expected nil map clients when overpass is disabled, got %v %v", mapAPI, overpassClient)
+		}
+		hits.Store(0)
+		statsProcessor := &processor.StopStatsProcessor{
+			Store:    store,
+			MapAPI:   mapAPI,
+			Overpass: overpassClient,
+			Options:  gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second},
+		}
+		if err := statsProcessor.Process(ctx, activityID); err != nil {
+			t.Fatalf("disabled=%v: process: %v", disabled, err)
+		}
+		got, err := store.GetActivityStats(ctx, activityID)
+		if err != nil {
+			t.Fatalf("disabled=%v: get stats: %v", disabled, err)
+		}
+		wantLights := 1
+		if disabled {
+			wantLights = 0
+		}
+		if got.StopCount != 1 || got.TrafficLightStopCount != wantLights {
+			t.Fatalf("disabled=%v: expected 1 stop with %d traffic lights, got %+v", disabled, wantLights, got)
+		}
+		if n := hits.Load(); disabled != (n == 0) {
+			t.Fatalf("disabled=%v: unexpected overpass request count %d", disabled, n)
+		}
+	}
+}
+
+func TestNewMapClientsUsesSignalsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signals.json")
+	if err := os.WriteFile(path, []byte(`[{"lat": 40.0, "lon": -73.0}]`), 0o644); err != nil {
+		t.Fatalf("write signals: %v", err)
+	}
+	mapAPI, overpassClient, err := newMapClients(config.Config{MapSignalsFile: path, DisableOverpass: true, OverpassRadiusMeters: 25})
+	if err != nil {
+		t.Fatalf("new map clients: %v", err)
+	}
+	if overpassClient != nil {
+		t.Fatalf("expected no overpass client when disabled")
+	}
+	fileAPI, ok := mapAPI.(*maps.FileAPI)
+	if !ok {
+		t.Fatalf("expected file map API, got %T", mapAPI)
+	}
+	if fileAPI.RadiusMeters != 25 || len(fileAPI.Signals) != 1 {
+		t.Fatalf("unexpected file map API: %+v", fileAPI)
+	}
+	if _, _, err := newMapClients(config.Config{MapSignalsFile: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Fatalf("expected error for missing signals file")
+	}
+}
+
+func TestRunWorkerExitsPromptlyOnCancel(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	looped := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// An hour-long idle delay would hang the test if cancellation were
+		// only noticed between polls.
+		runWorker(runCtx, context.WithoutCancel(runCtx), &worker.Worker{Store: store}, time.Hour, func() {
+			select {
+			case looped <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	select {
+	case <-looped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("worker never polled the queue")
+	}
+	cancel()
+	if !waitForDrain(time.Second, done) {
+		t.Fatalf("worker did not exit after cancel")
+	}
+}
+
+func TestDrainContextOutlivesParentUntilTimeout(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	workCtx, cancelWork := drainContext(parent, 50*time.Millisecond)
+	defer cancelWork()
+
+	cancelParent()
+	select {
+	case <-workCtx.Done():
+		t.Fatalf("work context cancelled together with its parent")
+	case <-time.After(10 * time.Millisecond):
+	}
+	select {
+	case <-workCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("work context not cancelled after the drain timeout")
+	}
+
+	idle, cancelIdle := drainContext(context.Background(), time.Hour)
+	cancelIdle()
+	if idle.Err() == nil {
+		t.Fatalf("expected cancel func to cancel the work context")
+	}
+}
+
+func TestWaitForDrainTimesOut(t *testing.T) {
+	closed := make(chan struct{})
+	close(closed)
+	stuck := make(chan struct{})
+	if waitForDrain(20*time.Millisecond, closed, stuck) {
+		t.Fatalf("expected timeout while a channel stays open")
+	}
+	if !waitForDrain(20*time.Millisecond, closed) {
+		t.Fatalf("expected closed channels to drain")
+	}
+}
+
+func TestNewHTTPServerAppliesTimeouts(t *testing.T) {
+	server := newHTTPServer(config.Config{
+		ServerAddr:            ":9999",
+		ServerReadTimeoutSec:  120,
+		ServerWriteTimeoutSec: 90,
+		ServerIdleTimeoutSec:  30,
+	}, http.NotFoundHandler())
+	if server.Addr != ":9999" {
+		t.Fatalf("unexpected addr %q", server.Addr)
+	}
+	if server.ReadTimeout != 120*time.Second || server.WriteTimeout != 90*time.Second || server.IdleTimeout != 30*time.Second {
+		t.Fatalf("unexpected timeouts: read=%s write=%s idle=%s", server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+	if server.ReadHeaderTimeout != maxReadHeaderTimeout {
+		t.Fatalf("expected header timeout %s, got %s", maxReadHeaderTimeout, server.ReadHeaderTimeout)
+	}
+
+	short := newHTTPServer(config.Config{ServerReadTimeoutSec: 2, ServerWriteTimeoutSec: 2, ServerIdleTimeoutSec: 2}, http.NotFoundHandler())
+	if short.ReadHeaderTimeout != 2*time.Second {
+		t.Fatalf("expected header timeout capped by read timeout, got %s", short.ReadHeaderTimeout)
+	}
+}
+
+func TestRequeueStaleStatsEnqueuesChangedOptions(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	oldOpts := gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 3 * time.Second}
+	start := time.Date(2026, time.March, 1, 7, 0, 0, 0, time.UTC)
+	for _, id := range []int64{1, 2} {
+		if _, err := store.InsertActivity(ctx, storage.Activity{ID: id, UserID: 1, Type: "Ride", Name: "Ride", StartTime: start}, nil); err != nil {
+			t.Fatalf("insert activity: %v", err)
+		}
+		if err := store.UpsertActivityStats(ctx, id, stats.StopStats{OptionsHash: oldOpts.Hash(), StatsVersion: 2}); err != nil {
+			t.Fatalf("upsert stats: %v", err)
+		}
+	}
+
+	if got := requeueStaleStats(ctx, store, oldOpts.Hash(), 2); got != 0 {
+		t.Fatalf("expected nothing to requeue with unchanged options, got %d", got)
+	}
+	climbChanged := &processor.StopStatsProcessor{Options: oldOpts, ClimbNoiseThresholdM: 3}
+	if got := requeueStaleStats(ctx, store, climbChanged.OptionsHash(), 2); got != 2 {
+		t.Fatalf("expected a climb threshold change to requeue 2 activities, got %d", got)
+	}
+	newOpts := oldOpts
+	newOpts.SpeedThreshold = 1.0
+	if got := requeueStaleStats(ctx, store, newOpts.Hash(), 2); got != 2 {
+		t.Fatalf("expected 2 activities requeued, got %d", got)
+	}
+	jobs, err := store.ListJobsByType(ctx, "process_activity", 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 process jobs, got %d", len(jobs))
+	}
+}
+
+func TestRequeueStaleStatsEnqueuesOutdatedStatsVersion(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	opts := gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 3 * time.Second}
+	start := time.Date(2026, time.March, 1, 7, 0, 0, 0, time.UTC)
+	for id, version := range map[int64]int{1: 1, 2: 2} {
+		if _, err := store.InsertActivity(ctx, storage.Activity{ID: id, UserID: 1, Type: "Ride", Name: "Ride", StartTime: start}, nil); err != nil {
+			t.Fatalf("insert activity: %v", err)
+		}
+		if err := store.UpsertActivityStats(ctx, id, stats.StopStats{OptionsHash: opts.Hash(), StatsVersion: version}); err != nil {
+			t.Fatalf("upsert stats: %v", err)
+		}
+	}
+
+	if got := requeueStaleStats(ctx, store, opts.Hash(), 2); got != 1 {
+		t.Fatalf("expected 1 activity requeued for its old stats version, got %d", got)
+	}
+	jobs, err := store.ListJobsByType(ctx, "process_activity", 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 1 || !strings.Contains(jobs[0].Payload, `"activity_id":1`) {
+		t.Fatalf("expected one process job for activity 1, got %+v", jobs)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/maps"
+)
+
+type processedStop struct {
+	Lat             float64   `json:"lat"`
+	Lon             float64   `json:"lon"`
+	StartTime       time.Time `json:"start_time"`
+	DurationSeconds int       `json:"duration_seconds"`
+	HasTrafficLight *bool     `json:"has_traffic_light,omitempty"`
+}
+
+// runProcess implements `weirdstats process <file.gpx>`: detect stops in a GPX
+// file and print them as JSON. Nothing touches the database; map lookups only
+// happen with -overpass.
+func runProcess(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	speedThreshold := fs.Float64("speed-threshold", 0.5, "speed in m/s at or below which the rider counts as stopped")
+	minDuration := fs.Duration("min-duration", 3*time.Second, "minimum stop duration")
+	glitchTolerance := fs.Duration("glitch-tolerance", 10*time.Second, "ignore speed spikes shorter than this during a stop")
+	useOverpass := fs.Bool("overpass", false, "look up traffic lights near each stop via Overpass")
+	overpassURL := fs.String("overpass-url", "", "Overpass endpoint (default: public instance)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: weirdstats process [flags] <file.gpx>")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	points, err := gps.ParseGPX(file)
+	if err != nil {
+		return err
+	}
+
+	opts := gps.StopOptions{SpeedThreshold: *speedThreshold, MinDuration: *minDuration, GlitchTolerance: *glitchTolerance}
+	var mapAPI maps.API
+	if *useOverpass {
+		mapAPI = &maps.OverpassClient{BaseURL: *overpassURL}
+	}
+	stops, err := describeStops(ctx, points, opts, mapAPI)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stops)
+}
+
+func describeStops(ctx context.Context, points []gps.Point, opts gps.StopOptions, mapAPI maps.API) ([]processedStop, error) {
+	stops := gps.DetectStops(points, opts)
+	out := make([]processedStop, 0, len(stops))
+	for _, stop := range stops {
+		row := processedStop{
+			Lat:             stop.Lat,
+			Lon:             stop.Lon,
+			StartTime:       stop.StartTime.UTC(),
+			DurationSeconds: int(stop.Duration.Seconds()),
+		}
+		if mapAPI != nil {
+			features, err := mapAPI.NearbyFeatures(ctx, stop.Lat, stop.Lon)
+			if err != nil {
+				return nil, err
+			}
+			hasLight := false
+			for _, feature := range features {
+				if feature.Type == maps.FeatureTrafficLight {
+					hasLight = true
+					break
+				}
+			}
+			row.HasTrafficLight = &hasLight
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
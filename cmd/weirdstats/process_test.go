@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunProcessDetectsStopsInGPX(t *testing.T) {
+	fixture := filepath.Join("..", "..", "testdata", "gpx", "stop_sample.gpx")
+	var out bytes.Buffer
+	if err := runProcess(context.Background(), []string{"-min-duration", "30s", fixture}, &out); err != nil {
+		t.Fatalf("run process: %v", err)
+	}
+
+	var stops []processedStop
+	if err := json.Unmarshal(out.Bytes(), &stops); err != nil {
+		t.Fatalf("decode output: %v\n%s", err, out.String())
+	}
+	if len(stops) != 1 {
+		t.Fatalf("expected 1 stop, got %d: %s", len(stops), out.String())
+	}
+	if stops[0].DurationSeconds < 50 || stops[0].DurationSeconds > 70 {
+		t.Fatalf("expected a ~60s stop, got %ds", stops[0].DurationSeconds)
+	}
+	if stops[0].Lat < 52.5226 || stops[0].Lat > 52.5228 {
+		t.Fatalf("unexpected stop latitude %f", stops[0].Lat)
+	}
+	if stops[0].HasTrafficLight != nil {
+		t.Fatalf("expected no traffic light classification without -overpass")
+	}
+}
@@ -33,6 +33,7 @@ func ensureWebhookSubscription(ctx context.Context, cfg config.Config) {
 		ClientID:     cfg.StravaClientID,
 		ClientSecret: cfg.StravaClientSecret,
 		HTTPClient:   &http.Client{Timeout: 15 * time.Second},
+		UserAgent:    cfg.UserAgent,
 	}
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
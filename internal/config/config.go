@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -12,6 +13,7 @@ import (
 type Config struct {
 	BaseURL                   string
 	DatabasePath              string
+	DatabasePragmas           []string
 	ServerAddr                string
 	SessionSecret             string
 	MobileAppRedirectURL      string
@@ -29,22 +31,62 @@ type Config struct {
 	StravaWebhookCallbackURL  string
 	StravaWebhookAutoRegister bool
 	StravaWebhookAutoReplace  bool
+	StravaWebhookMaxAgeSec    int
+	StravaWebhookMaxBodyBytes int64
 	StravaInitialSyncDays     int
+	StravaWriteDescription    bool
 	MapsAPIKey                string
 	OverpassURL               string
 	OverpassURLs              []string
 	OverpassTimeoutSec        int
 	OverpassCacheHours        int
+	OverpassPrefetchSignals   bool
+	OverpassMaxLookups        int
+	OverpassMaxAttempts       int
+	OverpassBackoffMS         int
+	OverpassRadiusMeters      int
+	TrafficLightMatchRadiusM  int
+	DisableOverpass           bool
+	MapSignalsFile            string
+	UserAgent                 string
+	SimplifyToleranceM        int
+	ClimbNoiseThresholdM      int
+	StopSpeedThreshold        float64
+	StopMinDurationSec        int
+	EffortHRReference         string
+	EffortHRWindow            int
+	ServerReadTimeoutSec      int
+	ServerWriteTimeoutSec     int
+	ServerIdleTimeoutSec      int
 	WorkerPollIntervalMS      int
+	QueueRetentionDays        int
+	WebhookRetentionDays      int
+	OAuthRateLimitPerMinute   int
+	OAuthRateLimitBurst       int
+	TrustedProxies            []netip.Prefix
+	AccessLog                 string
 }
 
 func Load(path string) (Config, error) {
 	cfg := Config{
-		ServerAddr:            ":8080",
-		StravaBaseURL:         "https://www.strava.com/api/v3",
-		StravaAuthBaseURL:     "https://www.strava.com",
-		StravaInitialSyncDays: 30,
-		WorkerPollIntervalMS:  2000,
+		ServerAddr:              ":8080",
+		StravaBaseURL:           "https://www.strava.com/api/v3",
+		StravaAuthBaseURL:       "https://www.strava.com",
+		StravaInitialSyncDays:   30,
+		StravaWriteDescription:  true,
+		StravaWebhookMaxAgeSec:  86400,
+		ServerReadTimeoutSec:    10,
+		ServerWriteTimeoutSec:   10,
+		ServerIdleTimeoutSec:    60,
+		ClimbNoiseThresholdM:    3,
+		StopSpeedThreshold:      0.5,
+		StopMinDurationSec:      3,
+		EffortHRWindow:          50,
+		WorkerPollIntervalMS:    2000,
+		QueueRetentionDays:      30,
+		WebhookRetentionDays:    30,
+		OAuthRateLimitPerMinute: 10,
+		OAuthRateLimitBurst:     5,
 	}
 
 	if path != "" {
@@ -54,6 +96,9 @@ func Load(path string) (Config, error) {
 	}
 
 	cfg.DatabasePath = getenv("DATABASE_PATH", "weirdstats.db")
+	if v := os.Getenv("DATABASE_PRAGMAS"); v != "" {
+		cfg.DatabasePragmas = splitAndTrim(v)
+	}
 	cfg.ServerAddr = getenv("SERVER_ADDR", cfg.ServerAddr)
 	cfg.BaseURL = normalizeBaseURL(os.Getenv("BASE_URL"))
 	cfg.SessionSecret = os.Getenv("SESSION_SECRET")
@@ -71,12 +116,42 @@ func Load(path string) (Config, error) {
 		cfg.StravaMobileRedirectURL = joinURL(cfg.BaseURL, "/connect/strava/mobile/callback")
 		cfg.StravaWebhookCallbackURL = joinURL(cfg.BaseURL, "/webhook")
 	}
+	cfg.AccessLog = strings.ToLower(getenv("ACCESS_LOG", "all"))
+	switch cfg.AccessLog {
+	case "off", "errors", "all":
+	default:
+		return Config{}, fmt.Errorf("ACCESS_LOG: expected off, errors or all, got %q", cfg.AccessLog)
+	}
 	cfg.MapsAPIKey = os.Getenv("MAPS_API_KEY")
 	cfg.OverpassURL = os.Getenv("OVERPASS_URL")
 	if v := os.Getenv("OVERPASS_URLS"); v != "" {
 		cfg.OverpassURLs = splitAndTrim(v)
 	}
 
+	if v := os.Getenv("SERVER_READ_TIMEOUT_SEC"); v != "" {
+		if err := parseInt(&cfg.ServerReadTimeoutSec, v); err != nil {
+			return Config{}, fmt.Errorf("SERVER_READ_TIMEOUT_SEC: %w", err)
+		}
+		if cfg.ServerReadTimeoutSec <= 0 {
+			return Config{}, fmt.Errorf("SERVER_READ_TIMEOUT_SEC: must be positive, got %d", cfg.ServerReadTimeoutSec)
+		}
+	}
+	if v := os.Getenv("SERVER_WRITE_TIMEOUT_SEC"); v != "" {
+		if err := parseInt(&cfg.ServerWriteTimeoutSec, v); err != nil {
+			return Config{}, fmt.Errorf("SERVER_WRITE_TIMEOUT_SEC: %w", err)
+		}
+		if cfg.ServerWriteTimeoutSec <= 0 {
+			return Config{}, fmt.Errorf("SERVER_WRITE_TIMEOUT_SEC: must be positive, got %d", cfg.ServerWriteTimeoutSec)
+		}
+	}
+	if v := os.Getenv("SERVER_IDLE_TIMEOUT_SEC"); v != "" {
+		if err := parseInt(&cfg.ServerIdleTimeoutSec, v); err != nil {
+			return Config{}, fmt.Errorf("SERVER_IDLE_TIMEOUT_SEC: %w", err)
+		}
+		if cfg.ServerIdleTimeoutSec <= 0 {
+			return Config{}, fmt.Errorf("SERVER_IDLE_TIMEOUT_SEC: must be positive, got %d", cfg.ServerIdleTimeoutSec)
+		}
+	}
 	if v := os.Getenv("WORKER_POLL_INTERVAL_MS"); v != "" {
 		if err := parseInt(&cfg.WorkerPollIntervalMS, v); err != nil {
 			return Config{}, fmt.Errorf("WORKER_POLL_INTERVAL_MS: %w", err)
@@ -87,6 +162,11 @@ func Load(path string) (Config, error) {
 			return Config{}, fmt.Errorf("STRAVA_INITIAL_SYNC_DAYS: %w", err)
 		}
 	}
+	if v := os.Getenv("STRAVA_WRITE_DESCRIPTION"); v != "" {
+		if err := parseBool(&cfg.StravaWriteDescription, v); err != nil {
+			return Config{}, fmt.Errorf("STRAVA_WRITE_DESCRIPTION: %w", err)
+		}
+	}
 	if v := os.Getenv("STRAVA_WEBHOOK_AUTO_REGISTER"); v != "" {
 		if err := parseBool(&cfg.StravaWebhookAutoRegister, v); err != nil {
 			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_AUTO_REGISTER: %w", err)
@@ -97,6 +177,22 @@ func Load(path string) (Config, error) {
 			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_AUTO_REPLACE: %w", err)
 		}
 	}
+	if v := os.Getenv("STRAVA_WEBHOOK_MAX_AGE_SECONDS"); v != "" {
+		if err := parseInt(&cfg.StravaWebhookMaxAgeSec, v); err != nil {
+			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_MAX_AGE_SECONDS: %w", err)
+		}
+		if cfg.StravaWebhookMaxAgeSec < 0 {
+			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_MAX_AGE_SECONDS: must not be negative, got %d", cfg.StravaWebhookMaxAgeSec)
+		}
+	}
+	if v := os.Getenv("STRAVA_WEBHOOK_MAX_BODY_BYTES"); v != "" {
+		if err := parseInt64(&cfg.StravaWebhookMaxBodyBytes, v); err != nil {
+			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_MAX_BODY_BYTES: %w", err)
+		}
+		if cfg.StravaWebhookMaxBodyBytes < 0 {
+			return Config{}, fmt.Errorf("STRAVA_WEBHOOK_MAX_BODY_BYTES: must not be negative, got %d", cfg.StravaWebhookMaxBodyBytes)
+		}
+	}
 	if v := os.Getenv("OVERPASS_TIMEOUT_SECONDS"); v != "" {
 		if err := parseInt(&cfg.OverpassTimeoutSec, v); err != nil {
 			return Config{}, fmt.Errorf("OVERPASS_TIMEOUT_SECONDS: %w", err)
@@ -107,6 +203,134 @@ func Load(path string) (Config, error) {
 			return Config{}, fmt.Errorf("OVERPASS_CACHE_HOURS: %w", err)
 		}
 	}
+	if v := os.Getenv("OVERPASS_PREFETCH_SIGNALS"); v != "" {
+		if err := parseBool(&cfg.OverpassPrefetchSignals, v); err != nil {
+			return Config{}, fmt.Errorf("OVERPASS_PREFETCH_SIGNALS: %w", err)
+		}
+	}
+	if v := os.Getenv("OVERPASS_MAX_LOOKUPS_PER_ACTIVITY"); v != "" {
+		if err := parseInt(&cfg.OverpassMaxLookups, v); err != nil {
+			return Config{}, fmt.Errorf("OVERPASS_MAX_LOOKUPS_PER_ACTIVITY: %w", err)
+		}
+	}
+	if v := os.Getenv("OVERPASS_MAX_ATTEMPTS"); v != "" {
+		if err := parseInt(&cfg.OverpassMaxAttempts, v); err != nil {
+			return Config{}, fmt.Errorf("OVERPASS_MAX_ATTEMPTS: %w", err)
+		}
+		if cfg.OverpassMaxAttempts < 1 || cfg.OverpassMaxAttempts > 10 {
+			return Config{}, fmt.Errorf("OVERPASS_MAX_ATTEMPTS: must be between 1 and 10, got %d", cfg.OverpassMaxAttempts)
+		}
+	}
+	if v := os.Getenv("OVERPASS_BACKOFF_MS"); v != "" {
+		if err := parseInt(&cfg.OverpassBackoffMS, v); err != nil {
+			return Config{}, fmt.Errorf("OVERPASS_BACKOFF_MS: %w", err)
+		}
+		if cfg.OverpassBackoffMS < 0 {
+			return Config{}, fmt.Errorf("OVERPASS_BACKOFF_MS: must not be negative, got %d", cfg.OverpassBackoffMS)
+		}
+	}
+	if v := os.Getenv("OVERPASS_RADIUS_M"); v != "" {
+		if err := parseInt(&cfg.OverpassRadiusMeters, v); err != nil {
+			return Config{}, fmt.Errorf("OVERPASS_RADIUS_M: %w", err)
+		}
+		if cfg.OverpassRadiusMeters < 1 || cfg.OverpassRadiusMeters > 1000 {
+			return Config{}, fmt.Errorf("OVERPASS_RADIUS_M: must be between 1 and 1000, got %d", cfg.OverpassRadiusMeters)
+		}
+	}
+	if v := os.Getenv("TRAFFIC_LIGHT_MATCH_RADIUS_M"); v != "" {
+		if err := parseInt(&cfg.TrafficLightMatchRadiusM, v); err != nil {
+			return Config{}, fmt.Errorf("TRAFFIC_LIGHT_MATCH_RADIUS_M: %w", err)
+		}
+		if cfg.TrafficLightMatchRadiusM < 0 || cfg.TrafficLightMatchRadiusM > 1000 {
+			return Config{}, fmt.Errorf("TRAFFIC_LIGHT_MATCH_RADIUS_M: must be between 0 and 1000, got %d", cfg.TrafficLightMatchRadiusM)
+		}
+	}
+	if v := os.Getenv("DISABLE_OVERPASS"); v != "" {
+		if err := parseBool(&cfg.DisableOverpass, v); err != nil {
+			return Config{}, fmt.Errorf("DISABLE_OVERPASS: %w", err)
+		}
+	}
+	cfg.MapSignalsFile = os.Getenv("MAP_SIGNALS_FILE")
+	cfg.UserAgent = os.Getenv("HTTP_USER_AGENT")
+	if v := os.Getenv("SIMPLIFY_TOLERANCE_M"); v != "" {
+		if err := parseInt(&cfg.SimplifyToleranceM, v); err != nil {
+			return Config{}, fmt.Errorf("SIMPLIFY_TOLERANCE_M: %w", err)
+		}
+		if cfg.SimplifyToleranceM < 0 {
+			return Config{}, fmt.Errorf("SIMPLIFY_TOLERANCE_M: must not be negative, got %d", cfg.SimplifyToleranceM)
+		}
+	}
+	if v := os.Getenv("CLIMB_NOISE_THRESHOLD_M"); v != "" {
+		if err := parseInt(&cfg.ClimbNoiseThresholdM, v); err != nil {
+			return Config{}, fmt.Errorf("CLIMB_NOISE_THRESHOLD_M: %w", err)
+		}
+		if cfg.ClimbNoiseThresholdM < 0 {
+			return Config{}, fmt.Errorf("CLIMB_NOISE_THRESHOLD_M: must not be negative, got %d", cfg.ClimbNoiseThresholdM)
+		}
+	}
+	if v := os.Getenv("STOP_SPEED_THRESHOLD"); v != "" {
+		if err := parseFloat(&cfg.StopSpeedThreshold, v); err != nil {
+			return Config{}, fmt.Errorf("STOP_SPEED_THRESHOLD: %w", err)
+		}
+		if cfg.StopSpeedThreshold <= 0 {
+			return Config{}, fmt.Errorf("STOP_SPEED_THRESHOLD: must be positive, got %g", cfg.StopSpeedThreshold)
+		}
+	}
+	if v := os.Getenv("STOP_MIN_DURATION_SECONDS"); v != "" {
+		if err := parseInt(&cfg.StopMinDurationSec, v); err != nil {
+			return Config{}, fmt.Errorf("STOP_MIN_DURATION_SECONDS: %w", err)
+		}
+		if cfg.StopMinDurationSec < 1 {
+			return Config{}, fmt.Errorf("STOP_MIN_DURATION_SECONDS: must be at least 1, got %d", cfg.StopMinDurationSec)
+		}
+	}
+	cfg.EffortHRReference = strings.ToLower(getenv("EFFORT_HR_REFERENCE", "median"))
+	switch cfg.EffortHRReference {
+	case "median", "decay":
+	default:
+		return Config{}, fmt.Errorf("EFFORT_HR_REFERENCE: expected median or decay, got %q", cfg.EffortHRReference)
+	}
+	if v := os.Getenv("EFFORT_HR_WINDOW"); v != "" {
+		if err := parseInt(&cfg.EffortHRWindow, v); err != nil {
+			return Config{}, fmt.Errorf("EFFORT_HR_WINDOW: %w", err)
+		}
+		if cfg.EffortHRWindow < 1 || cfg.EffortHRWindow > 1000 {
+			return Config{}, fmt.Errorf("EFFORT_HR_WINDOW: must be between 1 and 1000, got %d", cfg.EffortHRWindow)
+		}
+	}
+	if v := os.Getenv("QUEUE_RETENTION_DAYS"); v != "" {
+		if err := parseInt(&cfg.QueueRetentionDays, v); err != nil {
+			return Config{}, fmt.Errorf("QUEUE_RETENTION_DAYS: %w", err)
+		}
+		if cfg.QueueRetentionDays < 0 {
+			return Config{}, fmt.Errorf("QUEUE_RETENTION_DAYS: must not be negative, got %d", cfg.QueueRetentionDays)
+		}
+	}
+	if v := os.Getenv("WEBHOOK_RETENTION_DAYS"); v != "" {
+		if err := parseInt(&cfg.WebhookRetentionDays, v); err != nil {
+			return Config{}, fmt.Errorf("WEBHOOK_RETENTION_DAYS: %w", err)
+		}
+		if cfg.WebhookRetentionDays < 0 {
+			return Config{}, fmt.Errorf("WEBHOOK_RETENTION_DAYS: must not be negative, got %d", cfg.WebhookRetentionDays)
+		}
+	}
+	if v := os.Getenv("OAUTH_RATE_LIMIT_PER_MINUTE"); v != "" {
+		if err := parseInt(&cfg.OAuthRateLimitPerMinute, v); err != nil {
+			return Config{}, fmt.Errorf("OAUTH_RATE_LIMIT_PER_MINUTE: %w", err)
+		}
+	}
+	if v := os.Getenv("OAUTH_RATE_LIMIT_BURST"); v != "" {
+		if err := parseInt(&cfg.OAuthRateLimitBurst, v); err != nil {
+			return Config{}, fmt.Errorf("OAUTH_RATE_LIMIT_BURST: %w", err)
+		}
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		proxies, err := parseTrustedProxies(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		cfg.TrustedProxies = proxies
+	}
 	if v := os.Getenv("STRAVA_ACCESS_TOKEN_EXPIRES_AT"); v != "" {
 		if err := parseInt64(&cfg.StravaAccessExpiry, v); err != nil {
 			return Config{}, fmt.Errorf("STRAVA_ACCESS_TOKEN_EXPIRES_AT: %w", err)
@@ -171,6 +395,15 @@ func parseInt64(target *int64, value string) error {
 	return nil
 }
 
+func parseFloat(target *float64, value string) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
 func parseBool(target *bool, value string) error {
 	parsed, err := strconv.ParseBool(value)
 	if err != nil {
@@ -221,3 +454,24 @@ func normalizeBaseURL(raw string) string {
 	}
 	return "https://" + base
 }
+
+// parseTrustedProxies reads a comma-separated list of IPs and CIDR ranges.
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
@@ -22,3 +22,318 @@ func TestNormalizeBaseURL(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadOverpassTuning(t *testing.T) {
+	t.Setenv("OVERPASS_MAX_ATTEMPTS", "3")
+	t.Setenv("OVERPASS_BACKOFF_MS", "250")
+	t.Setenv("OVERPASS_RADIUS_M", "60")
+	t.Setenv("TRAFFIC_LIGHT_MATCH_RADIUS_M", "20")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.OverpassMaxAttempts != 3 || cfg.OverpassBackoffMS != 250 || cfg.OverpassRadiusMeters != 60 || cfg.TrafficLightMatchRadiusM != 20 {
+		t.Fatalf("unexpected overpass tuning: attempts=%d backoff=%d radius=%d match=%d",
+			cfg.OverpassMaxAttempts, cfg.OverpassBackoffMS, cfg.OverpassRadiusMeters, cfg.TrafficLightMatchRadiusM)
+	}
+}
+
+func TestLoadOverpassTuningRejectsOutOfRange(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{key: "OVERPASS_MAX_ATTEMPTS", value: "0"},
+		{key: "OVERPASS_MAX_ATTEMPTS", value: "11"},
+		{key: "OVERPASS_MAX_ATTEMPTS", value: "many"},
+		{key: "OVERPASS_BACKOFF_MS", value: "-1"},
+		{key: "OVERPASS_RADIUS_M", value: "0"},
+		{key: "TRAFFIC_LIGHT_MATCH_RADIUS_M", value: "-1"},
+		{key: "TRAFFIC_LIGHT_MATCH_RADIUS_M", value: "1001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := Load(""); err == nil {
+				t.Fatalf("expected error for %s=%s", tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestLoadOAuthRateLimit(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.OAuthRateLimitPerMinute != 10 || cfg.OAuthRateLimitBurst != 5 {
+		t.Fatalf("unexpected defaults: %d/min burst %d", cfg.OAuthRateLimitPerMinute, cfg.OAuthRateLimitBurst)
+	}
+
+	t.Setenv("OAUTH_RATE_LIMIT_PER_MINUTE", "0")
+	t.Setenv("OAUTH_RATE_LIMIT_BURST", "2")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.OAuthRateLimitPerMinute != 0 || cfg.OAuthRateLimitBurst != 2 {
+		t.Fatalf("unexpected overrides: %d/min burst %d", cfg.OAuthRateLimitPerMinute, cfg.OAuthRateLimitBurst)
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.1, 10.1.2.3/8,::1")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := []string{"127.0.0.1/32", "10.0.0.0/8", "::1/128"}
+	if len(cfg.TrustedProxies) != len(want) {
+		t.Fatalf("expected %v, got %v", want, cfg.TrustedProxies)
+	}
+	for i, prefix := range cfg.TrustedProxies {
+		if prefix.String() != want[i] {
+			t.Fatalf("expected %v, got %v", want, cfg.TrustedProxies)
+		}
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "proxy.local")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for a hostname")
+	}
+}
+
+func TestLoadAccessLog(t *testing.T) {
+	t.Setenv("ACCESS_LOG", "Errors")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.AccessLog != "errors" {
+		t.Fatalf("expected errors, got %q", cfg.AccessLog)
+	}
+
+	t.Setenv("ACCESS_LOG", "verbose")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for unknown access log level")
+	}
+}
+
+func TestLoadStravaWriteDescription(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.StravaWriteDescription {
+		t.Fatalf("expected description writes enabled by default")
+	}
+
+	t.Setenv("STRAVA_WRITE_DESCRIPTION", "false")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StravaWriteDescription {
+		t.Fatalf("expected description writes disabled")
+	}
+}
+
+func TestLoadDisableOverpass(t *testing.T) {
+	t.Setenv("DISABLE_OVERPASS", "true")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.DisableOverpass {
+		t.Fatalf("expected overpass disabled")
+	}
+}
+
+func TestLoadUserAgent(t *testing.T) {
+	t.Setenv("HTTP_USER_AGENT", "weirdstats-selfhosted/1.0 (ops@example.com)")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.UserAgent != "weirdstats-selfhosted/1.0 (ops@example.com)" {
+		t.Fatalf("unexpected user agent %q", cfg.UserAgent)
+	}
+}
+
+func TestLoadStravaWebhookMaxAge(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StravaWebhookMaxAgeSec != 86400 {
+		t.Fatalf("expected a one day default, got %d", cfg.StravaWebhookMaxAgeSec)
+	}
+
+	t.Setenv("STRAVA_WEBHOOK_MAX_AGE_SECONDS", "0")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StravaWebhookMaxAgeSec != 0 {
+		t.Fatalf("expected the age check disabled, got %d", cfg.StravaWebhookMaxAgeSec)
+	}
+
+	t.Setenv("STRAVA_WEBHOOK_MAX_AGE_SECONDS", "-1")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for negative max age")
+	}
+}
+
+func TestLoadStravaWebhookMaxBodyBytes(t *testing.T) {
+	t.Setenv("STRAVA_WEBHOOK_MAX_BODY_BYTES", "4096")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StravaWebhookMaxBodyBytes != 4096 {
+		t.Fatalf("expected 4096, got %d", cfg.StravaWebhookMaxBodyBytes)
+	}
+
+	t.Setenv("STRAVA_WEBHOOK_MAX_BODY_BYTES", "-5")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for negative body limit")
+	}
+}
+
+func TestLoadRetentionDays(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.QueueRetentionDays != 30 || cfg.WebhookRetentionDays != 30 {
+		t.Fatalf("expected 30 day defaults, got queue=%d webhook=%d", cfg.QueueRetentionDays, cfg.WebhookRetentionDays)
+	}
+
+	t.Setenv("WEBHOOK_RETENTION_DAYS", "7")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.WebhookRetentionDays != 7 {
+		t.Fatalf("expected 7 day webhook retention, got %d", cfg.WebhookRetentionDays)
+	}
+
+	t.Setenv("QUEUE_RETENTION_DAYS", "-1")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for negative retention")
+	}
+}
+
+func TestLoadClimbNoiseThreshold(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ClimbNoiseThresholdM != 3 {
+		t.Fatalf("expected 3m default, got %d", cfg.ClimbNoiseThresholdM)
+	}
+
+	t.Setenv("CLIMB_NOISE_THRESHOLD_M", "0")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ClimbNoiseThresholdM != 0 {
+		t.Fatalf("expected 0 to disable smoothing, got %d", cfg.ClimbNoiseThresholdM)
+	}
+
+	t.Setenv("CLIMB_NOISE_THRESHOLD_M", "-2")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for negative threshold")
+	}
+}
+
+func TestLoadStopOptions(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StopSpeedThreshold != 0.5 || cfg.StopMinDurationSec != 3 {
+		t.Fatalf("unexpected defaults: speed=%g min=%d", cfg.StopSpeedThreshold, cfg.StopMinDurationSec)
+	}
+
+	t.Setenv("STOP_SPEED_THRESHOLD", "0.8")
+	t.Setenv("STOP_MIN_DURATION_SECONDS", "20")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StopSpeedThreshold != 0.8 || cfg.StopMinDurationSec != 20 {
+		t.Fatalf("unexpected overrides: speed=%g min=%d", cfg.StopSpeedThreshold, cfg.StopMinDurationSec)
+	}
+
+	t.Setenv("STOP_SPEED_THRESHOLD", "0")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for zero speed threshold")
+	}
+	t.Setenv("STOP_SPEED_THRESHOLD", "fast")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for non-numeric speed threshold")
+	}
+}
+
+func TestLoadEffortHRReference(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.EffortHRReference != "median" || cfg.EffortHRWindow != 50 {
+		t.Fatalf("unexpected defaults: reference=%q window=%d", cfg.EffortHRReference, cfg.EffortHRWindow)
+	}
+
+	t.Setenv("EFFORT_HR_REFERENCE", "Decay")
+	t.Setenv("EFFORT_HR_WINDOW", "20")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.EffortHRReference != "decay" || cfg.EffortHRWindow != 20 {
+		t.Fatalf("unexpected overrides: reference=%q window=%d", cfg.EffortHRReference, cfg.EffortHRWindow)
+	}
+
+	t.Setenv("EFFORT_HR_WINDOW", "0")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for zero window")
+	}
+	t.Setenv("EFFORT_HR_WINDOW", "20")
+	t.Setenv("EFFORT_HR_REFERENCE", "mean")
+	if _, err := Load(""); err == nil {
+		t.Fatalf("expected error for unknown reference")
+	}
+}
+
+func TestLoadServerTimeouts(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ServerReadTimeoutSec != 10 || cfg.ServerWriteTimeoutSec != 10 || cfg.ServerIdleTimeoutSec != 60 {
+		t.Fatalf("unexpected defaults: read=%d write=%d idle=%d", cfg.ServerReadTimeoutSec, cfg.ServerWriteTimeoutSec, cfg.ServerIdleTimeoutSec)
+	}
+
+	t.Setenv("SERVER_READ_TIMEOUT_SEC", "120")
+	t.Setenv("SERVER_WRITE_TIMEOUT_SEC", "90")
+	t.Setenv("SERVER_IDLE_TIMEOUT_SEC", "30")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ServerReadTimeoutSec != 120 || cfg.ServerWriteTimeoutSec != 90 || cfg.ServerIdleTimeoutSec != 30 {
+		t.Fatalf("unexpected overrides: read=%d write=%d idle=%d", cfg.ServerReadTimeoutSec, cfg.ServerWriteTimeoutSec, cfg.ServerIdleTimeoutSec)
+	}
+
+	for _, env := range []string{"SERVER_READ_TIMEOUT_SEC", "SERVER_WRITE_TIMEOUT_SEC", "SERVER_IDLE_TIMEOUT_SEC"} {
+		t.Setenv(env, "0")
+		if _, err := Load(""); err == nil {
+			t.Fatalf("%s: expected error for zero timeout", env)
+		}
+		t.Setenv(env, "10")
+	}
+}
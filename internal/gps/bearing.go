@@ -0,0 +1,30 @@
+package gps
+
+import "math"
+
+// Bearing returns the initial great-circle bearing from the first point to
+// the second in degrees clockwise from north, in [0, 360).
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	y := math.Sin(dLon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
+	bearing := math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+	if bearing >= 360 {
+		bearing -= 360
+	}
+	return bearing
+}
+
+// AngleDifference returns the smallest signed rotation from heading a to
+// heading b in degrees, in (-180, 180]. Positive means clockwise.
+func AngleDifference(a, b float64) float64 {
+	d := math.Mod(b-a, 360)
+	if d > 180 {
+		d -= 360
+	} else if d <= -180 {
+		d += 360
+	}
+	return d
+}
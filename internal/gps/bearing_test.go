@@ -0,0 +1,50 @@
+package gps
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBearingCardinalDirections(t *testing.T) {
+	cases := []struct {
+		name       string
+		lat2, lon2 float64
+		want       float64
+	}{
+		{name: "north", lat2: 1, lon2: 0, want: 0},
+		{name: "east", lat2: 0, lon2: 1, want: 90},
+		{name: "south", lat2: -1, lon2: 0, want: 180},
+		{name: "west", lat2: 0, lon2: -1, want: 270},
+		{name: "north-east", lat2: 0.001, lon2: 0.001, want: 45},
+	}
+	for _, tc := range cases {
+		got := Bearing(0, 0, tc.lat2, tc.lon2)
+		if math.Abs(got-tc.want) > 0.01 {
+			t.Fatalf("%s: expected %.2f, got %.4f", tc.name, tc.want, got)
+		}
+	}
+	if got := Bearing(52.5, 13.4, 52.5, 13.4); got != 0 {
+		t.Fatalf("expected 0 for identical points, got %f", got)
+	}
+}
+
+func TestAngleDifference(t *testing.T) {
+	cases := []struct {
+		a, b, want float64
+	}{
+		{a: 0, b: 90, want: 90},
+		{a: 90, b: 0, want: -90},
+		{a: 350, b: 10, want: 20},
+		{a: 10, b: 350, want: -20},
+		{a: 0, b: 180, want: 180},
+		{a: 180, b: 0, want: 180},
+		{a: 270, b: 90, want: 180},
+		{a: 45, b: 45, want: 0},
+		{a: 720, b: 30, want: 30},
+	}
+	for _, tc := range cases {
+		if got := AngleDifference(tc.a, tc.b); math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("AngleDifference(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
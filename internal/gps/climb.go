@@ -0,0 +1,29 @@
+package gps
+
+// ClimbMeters sums elevation gain over points that carry altitude. Rises and
+// dips smaller than noiseThresholdM around the last reference altitude are
+// treated as GPS noise; the reference only moves once the track has climbed
+// or dropped by at least the threshold. A threshold of 0 sums every rise.
+func ClimbMeters(points []Point, noiseThresholdM float64) float64 {
+	if noiseThresholdM < 0 {
+		noiseThresholdM = 0
+	}
+	var climb, ref float64
+	haveRef := false
+	for _, p := range points {
+		if !p.HasAltitude {
+			continue
+		}
+		switch {
+		case !haveRef:
+			ref = p.Altitude
+			haveRef = true
+		case p.Altitude-ref >= noiseThresholdM:
+			climb += p.Altitude - ref
+			ref = p.Altitude
+		case ref-p.Altitude >= noiseThresholdM:
+			ref = p.Altitude
+		}
+	}
+	return climb
+}
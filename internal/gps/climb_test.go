@@ -0,0 +1,46 @@
+package gps
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestClimbMeters_SmoothingIgnoresNoise(t *testing.T) {
+	start := time.Date(2026, time.April, 2, 7, 0, 0, 0, time.UTC)
+	// A steady 100m climb in 1m steps with ±1.5m of alternating GPS jitter,
+	// followed by a flat but noisy stretch.
+	var points []Point
+	for i := 0; i <= 100; i++ {
+		jitter := 1.5
+		if i%2 == 1 {
+			jitter = -1.5
+		}
+		points = append(points, Point{Time: start.Add(time.Duration(i) * time.Second), Altitude: 500 + float64(i) + jitter, HasAltitude: true})
+	}
+	for i := 0; i < 50; i++ {
+		jitter := 2.0
+		if i%2 == 1 {
+			jitter = -2.0
+		}
+		points = append(points, Point{Time: start.Add(time.Duration(101+i) * time.Second), Altitude: 600 + jitter, HasAltitude: true})
+	}
+	// A point without altitude must not reset the reference.
+	points = append(points, Point{Time: start.Add(200 * time.Second)})
+
+	raw := ClimbMeters(points, 0)
+	smoothed := ClimbMeters(points, 5)
+	if raw < 250 {
+		t.Fatalf("expected noise to inflate raw climb well past 100m, got %.1f", raw)
+	}
+	if math.Abs(smoothed-100) > 5 {
+		t.Fatalf("expected smoothed climb ~100m, got %.1f (raw %.1f)", smoothed, raw)
+	}
+}
+
+func TestClimbMeters_NoAltitude(t *testing.T) {
+	points := []Point{{Lat: 1}, {Lat: 2}}
+	if got := ClimbMeters(points, 3); got != 0 {
+		t.Fatalf("expected 0 without altitude data, got %.1f", got)
+	}
+}
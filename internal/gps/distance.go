@@ -0,0 +1,25 @@
+package gps
+
+// outlierSpeedMPS is the implied speed above which a point is treated as a
+// GPS jump rather than real movement (~250 km/h).
+const outlierSpeedMPS = 70.0
+
+// TotalDistanceMeters sums the great-circle distance between consecutive
+// points. A point reached faster than outlierSpeedMPS is skipped and the next
+// segment is measured from the last accepted point instead.
+func TotalDistanceMeters(points []Point) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+	var total float64
+	prev := points[0]
+	for _, cur := range points[1:] {
+		d := haversineMeters(prev.Lat, prev.Lon, cur.Lat, cur.Lon)
+		if dt := cur.Time.Sub(prev.Time).Seconds(); dt > 0 && d/dt > outlierSpeedMPS {
+			continue
+		}
+		total += d
+		prev = cur
+	}
+	return total
+}
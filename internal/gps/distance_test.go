@@ -0,0 +1,57 @@
+package gps
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// metersPerDegree is one degree of arc on the haversine sphere.
+const metersPerDegree = 6371000 * math.Pi / 180
+
+func TestTotalDistanceMeters_StraightLatitudeTrack(t *testing.T) {
+	start := time.Date(2026, time.April, 1, 6, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i <= 100; i++ {
+		points = append(points, Point{Lat: 47 + float64(i)*0.01, Lon: 8, Time: start.Add(time.Duration(i) * 200 * time.Second)})
+	}
+	got := TotalDistanceMeters(points)
+	if math.Abs(got-metersPerDegree) > 1 {
+		t.Fatalf("expected ~%.0fm for 1° of latitude, got %.1f", metersPerDegree, got)
+	}
+}
+
+func TestTotalDistanceMeters_SquareLoop(t *testing.T) {
+	start := time.Date(2026, time.April, 1, 6, 0, 0, 0, time.UTC)
+	corners := [][2]float64{{0, 0}, {0.01, 0}, {0.01, 0.01}, {0, 0.01}, {0, 0}}
+	var points []Point
+	for i, c := range corners {
+		points = append(points, Point{Lat: c[0], Lon: c[1], Time: start.Add(time.Duration(i) * 5 * time.Minute)})
+	}
+	got := TotalDistanceMeters(points)
+	// At the equator every side is 0.01° of arc; the north side is
+	// shorter by cos(0.01°), which is below a millimeter.
+	want := 4 * 0.01 * metersPerDegree
+	if math.Abs(got-want) > 0.5 {
+		t.Fatalf("expected ~%.1fm around the square, got %.1f", want, got)
+	}
+}
+
+func TestTotalDistanceMeters_SkipsOutlierJumps(t *testing.T) {
+	start := time.Date(2026, time.April, 1, 6, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Lat: 52.52, Lon: 13.405, Time: start},
+		{Lat: 52.521, Lon: 13.405, Time: start.Add(20 * time.Second)},
+		// A 10km jump in 10 seconds.
+		{Lat: 52.611, Lon: 13.405, Time: start.Add(30 * time.Second)},
+		{Lat: 52.522, Lon: 13.405, Time: start.Add(40 * time.Second)},
+	}
+	got := TotalDistanceMeters(points)
+	want := 0.002 * metersPerDegree
+	if math.Abs(got-want) > 0.5 {
+		t.Fatalf("expected the jump to be ignored (~%.1fm), got %.1f", want, got)
+	}
+	if got := TotalDistanceMeters(points[:1]); got != 0 {
+		t.Fatalf("expected 0 for a single point, got %.1f", got)
+	}
+}
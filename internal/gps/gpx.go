@@ -0,0 +1,68 @@
+package gps
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele"`
+	Time string   `xml:"time"`
+}
+
+// ParseGPX reads track points from a GPX document. GPX carries no speed, so
+// each point's speed is derived from the distance to the previous point.
+func ParseGPX(r io.Reader) ([]Point, error) {
+	var doc gpxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode gpx: %w", err)
+	}
+
+	var points []Point
+	for _, track := range doc.Tracks {
+		for _, segment := range track.Segments {
+			for _, raw := range segment.Points {
+				ts, err := time.Parse(time.RFC3339, raw.Time)
+				if err != nil {
+					return nil, fmt.Errorf("trkpt time %q: %w", raw.Time, err)
+				}
+				point := Point{Lat: raw.Lat, Lon: raw.Lon, Time: ts}
+				if raw.Ele != nil {
+					point.Altitude = *raw.Ele
+					point.HasAltitude = true
+				}
+				if n := len(points); n > 0 {
+					point.Speed = SpeedBetween(points[n-1], point)
+				}
+				points = append(points, point)
+			}
+		}
+	}
+	if len(points) == 0 {
+		return nil, errors.New("gpx has no track points")
+	}
+	return points, nil
+}
+
+// SpeedBetween derives speed in m/s from the distance and time between two
+// points. Zero or negative time deltas yield 0.
+func SpeedBetween(prev, cur Point) float64 {
+	dt := cur.Time.Sub(prev.Time).Seconds()
+	if dt <= 0 {
+		return 0
+	}
+	return haversineMeters(prev.Lat, prev.Lon, cur.Lat, cur.Lon) / dt
+}
@@ -0,0 +1,50 @@
+package gps
+
+import "errors"
+
+var errPolylineTruncated = errors.New("polyline truncated")
+
+// DecodePolyline decodes a Google encoded polyline (precision 5), as used by
+// Strava's map.summary_polyline, into lat/lon pairs.
+func DecodePolyline(encoded string) ([][2]float64, error) {
+	var coords [][2]float64
+	var lat, lon int
+	for i := 0; i < len(encoded); {
+		dLat, next, err := decodePolylineValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLon, next, err := decodePolylineValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+		lat += dLat
+		lon += dLon
+		coords = append(coords, [2]float64{float64(lat) / 1e5, float64(lon) / 1e5})
+	}
+	return coords, nil
+}
+
+func decodePolylineValue(encoded string, i int) (int, int, error) {
+	var result, shift int
+	for {
+		if i >= len(encoded) {
+			return 0, i, errPolylineTruncated
+		}
+		b := int(encoded[i]) - 63
+		i++
+		if b < 0 || b > 63 {
+			return 0, i, errors.New("polyline has invalid character")
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+	}
+	if result&1 != 0 {
+		return ^(result >> 1), i, nil
+	}
+	return result >> 1, i, nil
+}
@@ -0,0 +1,52 @@
+package gps
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodePolyline(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoded string
+		want    [][2]float64
+	}{
+		{
+			name:    "google reference",
+			encoded: "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+			want:    [][2]float64{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}},
+		},
+		{
+			name:    "single point",
+			encoded: "_ibE_mcbA",
+			want:    [][2]float64{{1, 11}},
+		},
+		{
+			name:    "empty",
+			encoded: "",
+			want:    nil,
+		},
+	}
+	for _, tc := range cases {
+		got, err := DecodePolyline(tc.encoded)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %d points, got %v", tc.name, len(tc.want), got)
+		}
+		for i := range got {
+			if math.Abs(got[i][0]-tc.want[i][0]) > 1e-9 || math.Abs(got[i][1]-tc.want[i][1]) > 1e-9 {
+				t.Fatalf("%s: point %d expected %v, got %v", tc.name, i, tc.want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestDecodePolylineRejectsMalformedInput(t *testing.T) {
+	for _, encoded := range []string{"_p~iF~ps|U_ulL", "_p~iF~ps|", "  "} {
+		if _, err := DecodePolyline(encoded); err == nil {
+			t.Fatalf("expected error for %q", encoded)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gps
+
+import "math"
+
+// Simplify reduces a track with Ramer–Douglas–Peucker, dropping points that
+// lie within toleranceMeters of the line between their retained neighbours.
+// Points at or below stopSpeed are kept as-is so stop detection with that
+// speed threshold sees the same stops, and retained points keep their
+// timestamps and stream values.
+func Simplify(points []Point, toleranceMeters, stopSpeed float64) []Point {
+	if toleranceMeters <= 0 || len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0] = true
+	keep[len(points)-1] = true
+	for i, p := range points {
+		if p.Speed <= stopSpeed {
+			keep[i] = true
+		}
+	}
+	// Each run between kept points is simplified independently.
+	start := 0
+	for i := 1; i < len(points); i++ {
+		if !keep[i] {
+			continue
+		}
+		if i-start > 1 {
+			simplifyRange(points, start, i, toleranceMeters, keep)
+		}
+		start = i
+	}
+
+	simplified := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+func simplifyRange(points []Point, first, last int, tolerance float64, keep []bool) {
+	maxDist := 0.0
+	index := -1
+	for i := first + 1; i < last; i++ {
+		d := crossTrackMeters(points[i], points[first], points[last])
+		if d > maxDist {
+			maxDist = d
+			index = i
+		}
+	}
+	if index < 0 || maxDist <= tolerance {
+		return
+	}
+	keep[index] = true
+	simplifyRange(points, first, index, tolerance, keep)
+	simplifyRange(points, index, last, tolerance, keep)
+}
+
+// crossTrackMeters is the distance from p to segment a-b, using a local
+// equirectangular projection around a. Accurate enough at track scale.
+func crossTrackMeters(p, a, b Point) float64 {
+	const earthRadius = 6371000.0
+	cosLat := math.Cos(a.Lat * math.Pi / 180)
+	project := func(q Point) (float64, float64) {
+		x := (q.Lon - a.Lon) * math.Pi / 180 * earthRadius * cosLat
+		y := (q.Lat - a.Lat) * math.Pi / 180 * earthRadius
+		return x, y
+	}
+	px, py := project(p)
+	bx, by := project(b)
+	lengthSq := bx*bx + by*by
+	if lengthSq == 0 {
+		return math.Hypot(px, py)
+	}
+	t := (px*bx + py*by) / lengthSq
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-t*bx, py-t*by)
+}
@@ -0,0 +1,88 @@
+package gps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimplifyZigZag(t *testing.T) {
+	start := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	var points []Point
+	// Eastbound along a line with ~2m of jitter, then a sharp 50m zig-zag.
+	for i := 0; i < 20; i++ {
+		jitter := 0.0
+		if i%2 == 1 {
+			jitter = 0.00002
+		}
+		points = append(points, Point{Lat: 52.5 + jitter, Lon: 13.4 + float64(i)*0.0002, Time: start.Add(time.Duration(i) * 3 * time.Second), Speed: 5})
+	}
+	zig := []float64{0.00045, 0, 0.00045, 0}
+	for i, dLat := range zig {
+		points = append(points, Point{Lat: 52.5 + dLat, Lon: 13.4 + float64(20+i)*0.0002, Time: start.Add(time.Duration(20+i) * 3 * time.Second), Speed: 5})
+	}
+
+	simplified := Simplify(points, 5, 0.5)
+	if len(simplified) >= len(points) {
+		t.Fatalf("expected fewer points, got %d of %d", len(simplified), len(points))
+	}
+	if simplified[0] != points[0] || simplified[len(simplified)-1] != points[len(points)-1] {
+		t.Fatalf("expected endpoints to be kept")
+	}
+	peaks := 0
+	for _, p := range simplified {
+		if p.Lat > 52.5004 {
+			peaks++
+		}
+	}
+	if peaks != 2 {
+		t.Fatalf("expected both zig-zag peaks to survive, got %d in %+v", peaks, simplified)
+	}
+	for i := 1; i < len(simplified); i++ {
+		if !simplified[i].Time.After(simplified[i-1].Time) {
+			t.Fatalf("expected retained points to keep their timestamps in order")
+		}
+	}
+	if got := Simplify(points, 0, 0.5); len(got) != len(points) {
+		t.Fatalf("expected zero tolerance to keep every point")
+	}
+}
+
+func TestSimplifyKeepsStoppedPoints(t *testing.T) {
+	start := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 30; i++ {
+		speed := 5.0
+		if i >= 10 && i < 16 {
+			speed = 0
+		}
+		points = append(points, Point{Lat: 52.5, Lon: 13.4 + float64(i)*0.0001, Time: start.Add(time.Duration(i) * 10 * time.Second), Speed: speed})
+	}
+	opts := StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second}
+	before := DetectStops(points, opts)
+	simplified := Simplify(points, 10, opts.SpeedThreshold)
+	after := DetectStops(simplified, opts)
+	if len(simplified) >= len(points) {
+		t.Fatalf("expected straight moving segments to be simplified")
+	}
+	if len(before) != 1 || len(after) != 1 || before[0].Duration != after[0].Duration {
+		t.Fatalf("expected the stop to survive simplification, before=%+v after=%+v", before, after)
+	}
+}
+
+func TestSimplifyHonoursStopSpeed(t *testing.T) {
+	start := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 30; i++ {
+		speed := 5.0
+		if i >= 10 && i < 16 {
+			speed = 0.8
+		}
+		points = append(points, Point{Lat: 52.5, Lon: 13.4 + float64(i)*0.0001, Time: start.Add(time.Duration(i) * 10 * time.Second), Speed: speed})
+	}
+	opts := StopOptions{SpeedThreshold: 1.0, MinDuration: 30 * time.Second}
+	before := DetectStops(points, opts)
+	after := DetectStops(Simplify(points, 10, opts.SpeedThreshold), opts)
+	if len(before) != 1 || len(after) != 1 || before[0].Duration != after[0].Duration {
+		t.Fatalf("expected a crawl below the configured threshold to survive, before=%+v after=%+v", before, after)
+	}
+}
@@ -1,6 +1,11 @@
 package gps
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
 
 type Point struct {
 	Lat          float64
@@ -13,6 +18,8 @@ type Point struct {
 	HasGrade     bool
 	HeartRate    float64
 	HasHeartRate bool
+	Altitude     float64
+	HasAltitude  bool
 }
 
 type Stop struct {
@@ -28,6 +35,13 @@ type StopOptions struct {
 	GlitchTolerance time.Duration // ignore brief speed spikes shorter than this during a stop
 }
 
+// Hash identifies the options stop stats were computed with, so stats from
+// different settings can be told apart.
+func (o StopOptions) Hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("speed=%g;min=%s;glitch=%s", o.SpeedThreshold, o.MinDuration, o.GlitchTolerance)))
+	return hex.EncodeToString(sum[:8])
+}
+
 func DetectStops(points []Point, opts StopOptions) []Stop {
 	if len(points) == 0 {
 		return nil
@@ -0,0 +1,59 @@
+package gps
+
+import (
+	"math"
+	"time"
+)
+
+// TurnThresholdDegrees is the heading change above which leaving a stop
+// counts as a turn.
+const TurnThresholdDegrees = 60.0
+
+// turnProbeMeters is how far before and after the stop the headings are
+// measured, so GPS jitter at the stop itself does not dominate.
+const turnProbeMeters = 20.0
+
+// turnProbeWindow bounds how far in time the probes search from the stop
+// end. The backward search has to cross the whole stop, so the window is
+// sized for long waits rather than a fixed number of samples.
+const turnProbeWindow = 15 * time.Minute
+
+// DetectTurnAfterStop compares the heading arriving at a stop with the
+// heading leaving it. stopEndIdx is the first moving point after the stop,
+// as returned by FindStopEndIndex. The angle is signed: positive for a right
+// (clockwise) turn, negative for a left turn.
+func DetectTurnAfterStop(points []Point, stopEndIdx int) (angleDeg float64, turned bool) {
+	if stopEndIdx <= 0 || stopEndIdx >= len(points)-1 {
+		return 0, false
+	}
+	anchor := points[stopEndIdx]
+
+	before := -1
+	for i := stopEndIdx - 1; i >= 0; i-- {
+		if anchor.Time.Sub(points[i].Time) > turnProbeWindow {
+			break
+		}
+		if haversineMeters(points[i].Lat, points[i].Lon, anchor.Lat, anchor.Lon) >= turnProbeMeters {
+			before = i
+			break
+		}
+	}
+	after := -1
+	for i := stopEndIdx + 1; i < len(points); i++ {
+		if points[i].Time.Sub(anchor.Time) > turnProbeWindow {
+			break
+		}
+		if haversineMeters(anchor.Lat, anchor.Lon, points[i].Lat, points[i].Lon) >= turnProbeMeters {
+			after = i
+			break
+		}
+	}
+	if before < 0 || after < 0 {
+		return 0, false
+	}
+
+	in := Bearing(points[before].Lat, points[before].Lon, anchor.Lat, anchor.Lon)
+	out := Bearing(anchor.Lat, anchor.Lon, points[after].Lat, points[after].Lon)
+	angleDeg = AngleDifference(in, out)
+	return angleDeg, math.Abs(angleDeg) > TurnThresholdDegrees
+}
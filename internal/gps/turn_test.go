@@ -0,0 +1,64 @@
+package gps
+
+import (
+	"testing"
+	"time"
+)
+
+// stopThenLeave builds a northbound approach, a stop, and an exit heading
+// along (dLat, dLon) per point.
+func stopThenLeave(dLat, dLon float64) ([]Point, int) {
+	return stopThenLeaveEvery(5*time.Second, 3, dLat, dLon)
+}
+
+// stopThenLeaveEvery is stopThenLeave with a custom sample interval and
+// number of stationary samples.
+func stopThenLeaveEvery(interval time.Duration, stopSamples int, dLat, dLon float64) ([]Point, int) {
+	start := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	var points []Point
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * interval) }
+	lat, lon := 52.5, 13.4
+	for i := 0; i < 5; i++ {
+		points = append(points, Point{Lat: lat - float64(5-i)*0.0001, Lon: lon, Time: at(len(points)), Speed: 5})
+	}
+	for i := 0; i < stopSamples; i++ {
+		points = append(points, Point{Lat: lat, Lon: lon, Time: at(len(points)), Speed: 0})
+	}
+	stopEnd := len(points)
+	for i := 0; i < 5; i++ {
+		points = append(points, Point{Lat: lat + float64(i)*dLat, Lon: lon + float64(i)*dLon, Time: at(len(points)), Speed: 5})
+	}
+	return points, stopEnd
+}
+
+func TestDetectTurnAfterStop(t *testing.T) {
+	straight, stopEnd := stopThenLeave(0.0001, 0)
+	angle, turned := DetectTurnAfterStop(straight, stopEnd)
+	if turned || angle < -5 || angle > 5 {
+		t.Fatalf("straight through: expected no turn, got angle %.1f turned=%t", angle, turned)
+	}
+
+	left, stopEnd := stopThenLeave(0, -0.00015)
+	angle, turned = DetectTurnAfterStop(left, stopEnd)
+	if !turned || angle > -80 || angle < -100 {
+		t.Fatalf("left turn: expected ~-90, got angle %.1f turned=%t", angle, turned)
+	}
+
+	right, stopEnd := stopThenLeave(0.00002, 0.00015)
+	angle, turned = DetectTurnAfterStop(right, stopEnd)
+	if !turned || angle < 60 {
+		t.Fatalf("right turn: expected > 60, got angle %.1f turned=%t", angle, turned)
+	}
+
+	if _, turned := DetectTurnAfterStop(left, len(left)-1); turned {
+		t.Fatalf("expected no turn without points after the stop")
+	}
+}
+
+func TestDetectTurnAfterLongStopAtOneHertz(t *testing.T) {
+	left, stopEnd := stopThenLeaveEvery(time.Second, 60, 0, -0.00015)
+	angle, turned := DetectTurnAfterStop(left, stopEnd)
+	if !turned || angle > -80 || angle < -100 {
+		t.Fatalf("left turn after 60 s stop: expected ~-90, got angle %.1f turned=%t", angle, turned)
+	}
+}
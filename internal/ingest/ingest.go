@@ -2,8 +2,10 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"time"
 
 	"weirdstats/internal/gps"
@@ -11,10 +13,20 @@ import (
 	"weirdstats/internal/strava"
 )
 
+// ErrNoGPSData is returned when an activity was stored without any GPS points,
+// e.g. manual entries or indoor workouts.
+var ErrNoGPSData = errors.New("activity has no GPS data")
+
 type Ingestor struct {
 	Store   *storage.Store
 	Strava  *strava.Client
 	Clients *strava.ClientFactory
+	// SimplifyToleranceM stores tracks simplified with gps.Simplify. Zero
+	// keeps every stream point.
+	SimplifyToleranceM float64
+	// StopSpeedThreshold is the stop detection speed; slower points are never
+	// simplified away.
+	StopSpeedThreshold float64
 }
 
 func (i *Ingestor) EnsureActivity(ctx context.Context, activityID int64) error {
@@ -43,6 +55,15 @@ func (i *Ingestor) EnsureActivity(ctx context.Context, activityID int64) error {
 		return err
 	}
 	if count == 0 {
+		// Manual and indoor activities legitimately have no GPS data; only
+		// go back to Strava if nothing has been fetched for them yet.
+		fetched, err := i.Store.ActivityPointsFetched(ctx, activityID)
+		if err != nil {
+			return err
+		}
+		if fetched {
+			return ErrNoGPSData
+		}
 		return i.fetchAndUpsert(ctx, userID, activityID)
 	}
 
@@ -61,6 +82,10 @@ func (i *Ingestor) fetchAndUpsert(ctx context.Context, userID, activityID int64)
 	}
 
 	streams, err := client.GetStreams(ctx, activityID)
+	var apiErr *strava.APIError
+	if err != nil && activity.SummaryPolyline != "" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		streams, err = strava.StreamSet{}, nil
+	}
 	if err != nil {
 		return err
 	}
@@ -69,14 +94,27 @@ func (i *Ingestor) fetchAndUpsert(ctx context.Context, userID, activityID int64)
 	if err != nil {
 		return err
 	}
+	if len(points) == 0 && activity.SummaryPolyline != "" {
+		points, err = polylinePoints(activity.StartDate, time.Duration(activity.ElapsedTime)*time.Second, activity.SummaryPolyline)
+		if err != nil {
+			log.Printf("Activity %d summary polyline decode failed: %v", activity.ID, err)
+			points = nil
+		} else {
+			log.Printf("Activity %d (%s) has no streams; using %d summary polyline points", activity.ID, activity.Name, len(points))
+		}
+	}
 	if len(points) == 0 {
 		log.Printf("Activity %d (%s) has no GPS data", activity.ID, activity.Name)
 	}
+	if i.SimplifyToleranceM > 0 {
+		points = gps.Simplify(points, i.SimplifyToleranceM, i.StopSpeedThreshold)
+	}
 
 	_, err = i.Store.UpsertActivity(ctx, storage.Activity{
 		ID:               activity.ID,
 		UserID:           userID,
 		Type:             activity.Type,
+		SportType:        activity.SportType,
 		Name:             activity.Name,
 		StartTime:        activity.StartDate,
 		Description:      activity.Description,
@@ -88,8 +126,16 @@ func (i *Ingestor) fetchAndUpsert(ctx context.Context, userID, activityID int64)
 		IsPrivate:        activity.Private,
 		HideFromHome:     activity.HideFromHome,
 		PhotoURL:         activity.PhotoURL,
+		GearID:           activity.GearID,
+		Commute:          activity.Commute,
 	}, points)
-	return err
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return ErrNoGPSData
+	}
+	return nil
 }
 
 func (i *Ingestor) SyncLatestActivity(ctx context.Context, userID int64) (int, error) {
@@ -107,7 +153,7 @@ func (i *Ingestor) SyncLatestActivity(ctx context.Context, userID int64) (int, e
 		return 0, nil
 	}
 
-	if err := i.fetchAndUpsert(ctx, userID, activities[0].ID); err != nil {
+	if err := i.fetchAndUpsert(ctx, userID, activities[0].ID); err != nil && !errors.Is(err, ErrNoGPSData) {
 		return 0, err
 	}
 
@@ -129,18 +175,14 @@ func (i *Ingestor) SyncActivitiesSince(ctx context.Context, userID int64, after
 	perPage := 100
 
 	for {
-		activities, err := client.ListActivities(ctx, after, time.Time{}, page, perPage)
+		result, err := client.ListActivitiesPage(ctx, after, time.Time{}, page, perPage)
 		if err != nil {
 			return 0, err
 		}
 
-		if len(activities) == 0 {
-			break
-		}
-
-		allActivities = append(allActivities, activities...)
+		allActivities = append(allActivities, result.Activities...)
 
-		if len(activities) < perPage {
+		if !result.HasMore {
 			break
 		}
 		page++
@@ -148,7 +190,7 @@ func (i *Ingestor) SyncActivitiesSince(ctx context.Context, userID int64, after
 
 	synced := 0
 	for _, activity := range allActivities {
-		if err := i.fetchAndUpsert(ctx, userID, activity.ID); err != nil {
+		if err := i.fetchAndUpsert(ctx, userID, activity.ID); err != nil && !errors.Is(err, ErrNoGPSData) {
 			return synced, fmt.Errorf("activity %d: %w", activity.ID, err)
 		}
 
@@ -215,8 +257,17 @@ func buildPoints(start time.Time, streams strava.StreamSet) ([]gps.Point, error)
 			Lon:  coord[1],
 			Time: start.Add(time.Duration(streams.TimeOffsetsSec[idx]) * time.Second),
 		}
+		// Keep times strictly increasing: duplicate or backwards timestamps
+		// (common at stream boundaries) are dropped, keeping the first sample.
+		if n := len(points); n > 0 && !p.Time.After(points[n-1].Time) {
+			continue
+		}
 		if idx < len(streams.VelocitySmooth) {
 			p.Speed = streams.VelocitySmooth[idx]
+		} else if n := len(points); n > 0 {
+			// No velocity_smooth for this point: derive it, otherwise the
+			// whole track reads as one long stop.
+			p.Speed = gps.SpeedBetween(points[n-1], p)
 		}
 		if idx < len(streams.Watts) {
 			p.Power = streams.Watts[idx]
@@ -230,7 +281,44 @@ func buildPoints(start time.Time, streams strava.StreamSet) ([]gps.Point, error)
 			p.HeartRate = streams.Heartrate[idx]
 			p.HasHeartRate = true
 		}
+		if idx < len(streams.Altitude) {
+			p.Altitude = streams.Altitude[idx]
+			p.HasAltitude = true
+		}
 		points = append(points, p)
 	}
 	return points, nil
 }
+
+// polylinePoints turns a summary polyline into route-only points. The
+// polyline has no timestamps, so times are spread over elapsed in proportion
+// to distance and every point gets the average speed: mapping features work,
+// and stop detection never sees a fake stop. Without an elapsed time all
+// points keep the start time and speed 0, which is too short to count as a
+// stop.
+func polylinePoints(start time.Time, elapsed time.Duration, encoded string) ([]gps.Point, error) {
+	coords, err := gps.DecodePolyline(encoded)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]gps.Point, 0, len(coords))
+	cumulative := make([]float64, 0, len(coords))
+	var total float64
+	for _, coord := range coords {
+		point := gps.Point{Lat: coord[0], Lon: coord[1], Time: start}
+		if len(points) > 0 {
+			total += gps.TotalDistanceMeters([]gps.Point{points[len(points)-1], point})
+		}
+		points = append(points, point)
+		cumulative = append(cumulative, total)
+	}
+	if elapsed <= 0 || total <= 0 {
+		return points, nil
+	}
+	speed := total / elapsed.Seconds()
+	for i := range points {
+		points[i].Time = start.Add(time.Duration(float64(elapsed) * cumulative[i] / total))
+		points[i].Speed = speed
+	}
+	return points, nil
+}
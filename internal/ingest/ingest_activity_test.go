@@ -0,0 +1,179 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/rules"
+	"weirdstats/internal/storage"
+	"weirdstats/internal/strava"
+)
+
+func TestEnsureActivityStoresGearAndCommute(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/activities/123":
+			_, _ = w.Write([]byte(`{"id":123,"name":"Commute","type":"Ride","start_date":"2024-01-01T10:00:00Z","gear_id":"b1234567","commute":true}`))
+		case "/activities/123/streams":
+			_, _ = w.Write([]byte(`{"latlng":{"data":[[1.0,2.0],[1.0,2.001]]},"time":{"data":[0,60]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ingestor := &Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}}
+	if err := ingestor.EnsureActivity(ContextWithUserID(ctx, 1), 123); err != nil {
+		t.Fatalf("ensure activity: %v", err)
+	}
+
+	activity, err := store.GetActivity(ctx, 123)
+	if err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	if activity.GearID != "b1234567" {
+		t.Fatalf("expected gear id b1234567, got %q", activity.GearID)
+	}
+	if !activity.Commute {
+		t.Fatalf("expected commute flag to be stored")
+	}
+
+	rule, err := rules.ParseRuleJSON(`{"match":"all","conditions":[{"metric":"gear_id","op":"eq","values":["b1234567"]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	matched, hide, err := rules.Evaluate(rule, rules.DefaultRegistry(), rules.Context{
+		Activity: rules.ActivitySource{ID: activity.ID, Type: activity.Type, GearID: activity.GearID},
+	}, 1)
+	if err != nil {
+		t.Fatalf("evaluate rule: %v", err)
+	}
+	if !matched || !hide {
+		t.Fatalf("expected gear rule to hide activity, matched=%t hide=%t", matched, hide)
+	}
+}
+
+func TestEnsureActivityWithoutLatLngStoresEmptyActivity(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/activities/456":
+			_, _ = w.Write([]byte(`{"id":456,"name":"Manual entry","type":"Run","start_date":"2024-01-01T10:00:00Z","distance":5000}`))
+		case "/activities/456/streams":
+			_, _ = w.Write([]byte(`{"latlng":{"data":[]},"time":{"data":[0,60]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ingestor := &Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}}
+	err = ingestor.EnsureActivity(ContextWithUserID(ctx, 1), 456)
+	if !errors.Is(err, ErrNoGPSData) {
+		t.Fatalf("expected ErrNoGPSData, got %v", err)
+	}
+
+	activity, err := store.GetActivity(ctx, 456)
+	if err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	if activity.Name != "Manual entry" || activity.Distance != 5000 {
+		t.Fatalf("unexpected stored activity: %+v", activity)
+	}
+	count, err := store.CountActivityPoints(ctx, 456)
+	if err != nil {
+		t.Fatalf("count points: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no points, got %d", count)
+	}
+
+	fetched := requests
+	err = ingestor.EnsureActivity(ContextWithUserID(ctx, 1), 456)
+	if !errors.Is(err, ErrNoGPSData) {
+		t.Fatalf("expected ErrNoGPSData on second ensure, got %v", err)
+	}
+	if requests != fetched {
+		t.Fatalf("expected no refetch for an activity without GPS data, got %d extra requests", requests-fetched)
+	}
+}
+
+func TestEnsureActivityFallsBackToSummaryPolyline(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/activities/789":
+			_, _ = w.Write([]byte(`{"id":789,"name":"Old ride","type":"Ride","start_date":"2014-05-01T10:00:00Z","distance":20000,"elapsed_time":3600,"map":{"summary_polyline":"_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ingestor := &Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}}
+	if err := ingestor.EnsureActivity(ContextWithUserID(ctx, 1), 789); err != nil {
+		t.Fatalf("ensure activity: %v", err)
+	}
+
+	points, err := store.LoadActivityPoints(ctx, 789)
+	if err != nil {
+		t.Fatalf("load points: %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("expected 3 polyline points, got %d", len(points))
+	}
+	if points[0].Lat != 38.5 || points[0].Lon != -120.2 {
+		t.Fatalf("unexpected first point: %+v", points[0])
+	}
+	start := time.Date(2014, time.May, 1, 10, 0, 0, 0, time.UTC)
+	if !points[0].Time.Equal(start) || !points[2].Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected times spread over the elapsed hour, got %s..%s", points[0].Time, points[2].Time)
+	}
+	if !points[1].Time.After(points[0].Time) || !points[1].Time.Before(points[2].Time) {
+		t.Fatalf("expected interpolated middle time, got %s", points[1].Time)
+	}
+	if points[1].Speed <= 0 {
+		t.Fatalf("expected average speed on polyline points, got %v", points[1].Speed)
+	}
+	if stops := gps.DetectStops(points, gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second}); len(stops) != 0 {
+		t.Fatalf("expected no stops from polyline points, got %d", len(stops))
+	}
+}
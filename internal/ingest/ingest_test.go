@@ -33,3 +33,63 @@ func TestBuildPoints(t *testing.T) {
 		t.Fatalf("unexpected heartrate: %+v", points[1])
 	}
 }
+
+func TestBuildPointsDerivesSpeedWithoutVelocity(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	streams := strava.StreamSet{
+		// ~11.1m per 0.0001 degrees of latitude.
+		LatLng:         [][2]float64{{52.5, 13.4}, {52.5001, 13.4}, {52.5001, 13.4}, {52.5003, 13.4}},
+		TimeOffsetsSec: []int{0, 2, 4, 8},
+	}
+
+	points, err := buildPoints(start, streams)
+	if err != nil {
+		t.Fatalf("build points: %v", err)
+	}
+	if points[0].Speed != 0 {
+		t.Fatalf("expected first point speed 0, got %v", points[0].Speed)
+	}
+	if points[1].Speed < 5.4 || points[1].Speed > 5.7 {
+		t.Fatalf("expected ~5.56 m/s, got %v", points[1].Speed)
+	}
+	if points[2].Speed != 0 {
+		t.Fatalf("expected stationary point speed 0, got %v", points[2].Speed)
+	}
+	if points[3].Speed < 5.4 || points[3].Speed > 5.7 {
+		t.Fatalf("expected ~5.56 m/s, got %v", points[3].Speed)
+	}
+}
+
+func TestBuildPointsDropsDuplicateTimestamps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	streams := strava.StreamSet{
+		LatLng:         [][2]float64{{52.5, 13.4}, {52.5001, 13.4}, {52.5001, 13.4}, {52.5002, 13.4}, {52.5002, 13.4}, {52.5003, 13.4}},
+		TimeOffsetsSec: []int{0, 2, 2, 4, 3, 6},
+		Heartrate:      []float64{120, 121, 199, 122, 199, 123},
+	}
+
+	points, err := buildPoints(start, streams)
+	if err != nil {
+		t.Fatalf("build points: %v", err)
+	}
+	if len(points) != 4 {
+		t.Fatalf("expected 4 points after dropping duplicates, got %d", len(points))
+	}
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		if !points[i].Time.After(points[i-1].Time) {
+			t.Fatalf("expected strictly increasing times, got %s then %s", points[i-1].Time, points[i].Time)
+		}
+		if points[i].HeartRate == 199 {
+			t.Fatalf("expected duplicate samples to be dropped, got %+v", points[i])
+		}
+		if points[i].Speed <= 0 {
+			t.Fatalf("expected derived speed at point %d, got %v", i, points[i].Speed)
+		}
+		total += points[i].Speed * points[i].Time.Sub(points[i-1].Time).Seconds()
+	}
+	// Speeds integrate back to the ~33.4m between first and last point.
+	if total < 33 || total > 34 {
+		t.Fatalf("expected ~33.4m total distance, got %.2f", total)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"weirdstats/internal/ingest"
@@ -20,11 +21,24 @@ const (
 	JobTypeApplyActivityRules  = "apply_activity_rules"
 )
 
+const (
+	rateLimitThrottleRatio = 0.8
+	defaultThrottleDelay   = 5 * time.Second
+)
+
 type SyncSincePayload struct {
 	UserID     int64 `json:"user_id"`
 	AfterUnix  int64 `json:"after_unix"`
 	PerPage    int   `json:"per_page"`
 	WindowDays int   `json:"window_days"`
+	// OldestSyncUnix is a hard lower bound: windows never start before it and
+	// older activities are not enqueued, whatever AfterUnix says.
+	OldestSyncUnix int64 `json:"oldest_sync_unix,omitempty"`
+	// Types limits enqueued activities to these Strava types (e.g. "Ride").
+	// Empty means all types.
+	Types []string `json:"types,omitempty"`
+	// Force re-enqueues activities that already have stats.
+	Force bool `json:"force,omitempty"`
 }
 
 type SyncSinceCursor struct {
@@ -63,6 +77,11 @@ type Runner struct {
 	Applier      ActivityRuleApplier
 	PollInterval time.Duration
 	StaleAfter   time.Duration
+
+	// RateLimits, when set, lets the runner slow down before Strava starts
+	// answering with 429s.
+	RateLimits    *strava.RateLimitTracker
+	ThrottleDelay time.Duration
 }
 
 func (r *Runner) ProcessNext(ctx context.Context) (bool, error) {
@@ -76,6 +95,7 @@ func (r *Runner) ProcessNext(ctx context.Context) (bool, error) {
 		}
 		return false, err
 	}
+	defer r.throttle(ctx)
 
 	if job.MaxAttempts > 0 && job.Attempts >= job.MaxAttempts {
 		if err := r.Store.MarkJobFailed(ctx, job.ID, job.Cursor, "max attempts exceeded"); err != nil {
@@ -131,12 +151,18 @@ func (r *Runner) handleSyncSince(ctx context.Context, job storage.Job) error {
 	if cursor.MaxBeforeUnix <= 0 {
 		cursor.MaxBeforeUnix = time.Now().Unix()
 	}
-	if payload.AfterUnix > 0 && payload.AfterUnix >= cursor.MaxBeforeUnix {
+	afterUnix := payload.AfterUnix
+	if payload.OldestSyncUnix > afterUnix {
+		afterUnix = payload.OldestSyncUnix
+	}
+	if afterUnix > 0 && afterUnix >= cursor.MaxBeforeUnix {
 		cursorJSON, _ := json.Marshal(cursor)
 		return r.Store.MarkJobCompleted(ctx, job.ID, string(cursorJSON))
 	}
-	if cursor.WindowStartUnix <= 0 {
-		cursor.WindowStartUnix = payload.AfterUnix
+	if cursor.WindowStartUnix < afterUnix {
+		cursor.WindowStartUnix = afterUnix
+		cursor.WindowEndUnix = 0
+		cursor.Page = 1
 	}
 	windowDays := payload.WindowDays
 	if windowDays <= 0 {
@@ -164,19 +190,34 @@ func (r *Runner) handleSyncSince(ctx context.Context, job storage.Job) error {
 
 	after := time.Unix(cursor.WindowStartUnix, 0)
 	before := time.Unix(cursor.WindowEndUnix, 0)
-	activities, err := client.ListActivities(ctx, after, before, cursor.Page, perPage)
+	result, err := client.ListActivitiesPage(ctx, after, before, cursor.Page, perPage)
 	if err != nil {
 		return r.markJobRetry(ctx, job, cursor, err)
 	}
 
-	for _, activity := range activities {
+	for _, activity := range result.Activities {
+		if payload.OldestSyncUnix > 0 && activity.StartDate.Unix() < payload.OldestSyncUnix {
+			continue
+		}
+		if !matchesActivityType(payload.Types, activity.SportType) && !matchesActivityType(payload.Types, activity.Type) {
+			continue
+		}
+		if !payload.Force {
+			processed, err := r.alreadyProcessed(ctx, activity.ID)
+			if err != nil {
+				return r.markJobRetry(ctx, job, cursor, err)
+			}
+			if processed {
+				continue
+			}
+		}
 		if err := EnqueueProcessActivity(ctx, r.Store, activity.ID, payload.UserID); err != nil {
 			return r.markJobRetry(ctx, job, cursor, err)
 		}
 		cursor.Enqueued++
 	}
 
-	if len(activities) >= perPage {
+	if result.HasMore {
 		cursor.Page++
 		cursorJSON, _ := json.Marshal(cursor)
 		return r.Store.MarkJobQueued(ctx, job.ID, string(cursorJSON), time.Now().Add(2*time.Second))
@@ -197,6 +238,26 @@ func (r *Runner) handleSyncSince(ctx context.Context, job storage.Job) error {
 	return r.Store.MarkJobQueued(ctx, job.ID, string(cursorJSON), time.Now().Add(2*time.Second))
 }
 
+func (r *Runner) alreadyProcessed(ctx context.Context, activityID int64) (bool, error) {
+	hasActivity, err := r.Store.HasActivity(ctx, activityID)
+	if err != nil || !hasActivity {
+		return false, err
+	}
+	return r.Store.HasStats(ctx, activityID)
+}
+
+func matchesActivityType(types []string, activityType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if strings.EqualFold(t, activityType) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Runner) handleSyncLatest(ctx context.Context, job storage.Job) error {
 	if r.Ingestor == nil {
 		return r.Store.MarkJobFailed(ctx, job.ID, job.Cursor, "ingestor not configured")
@@ -330,6 +391,36 @@ func retryDelay(attempt int) time.Duration {
 	return delay
 }
 
+// throttle pauses after a job when the latest Strava usage is close to the
+// 15-minute limit.
+func (r *Runner) throttle(ctx context.Context) {
+	delay := r.rateLimitDelay()
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func (r *Runner) rateLimitDelay() time.Duration {
+	info, ok := r.RateLimits.Latest()
+	if !ok {
+		return 0
+	}
+	ratio, ok := info.ShortUsageRatio()
+	if !ok || ratio < rateLimitThrottleRatio {
+		return 0
+	}
+	if r.ThrottleDelay > 0 {
+		return r.ThrottleDelay
+	}
+	return defaultThrottleDelay
+}
+
 func (r *Runner) staleAfter() time.Duration {
 	if r.StaleAfter > 0 {
 		return r.StaleAfter
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"weirdstats/internal/strava"
+)
+
+func TestRunnerRateLimitDelay(t *testing.T) {
+	usage := "100,500"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		w.Header().Set("X-RateLimit-Usage", usage)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	tracker := &strava.RateLimitTracker{}
+	client := &strava.Client{BaseURL: server.URL, AccessToken: "token", RateLimits: tracker}
+	runner := &Runner{RateLimits: tracker, ThrottleDelay: 3 * time.Second}
+
+	if got := runner.rateLimitDelay(); got != 0 {
+		t.Fatalf("expected no delay before any response, got %s", got)
+	}
+
+	if _, err := client.ListActivities(context.Background(), time.Time{}, time.Time{}, 1, 10); err != nil {
+		t.Fatalf("list activities: %v", err)
+	}
+	if got := runner.rateLimitDelay(); got != 0 {
+		t.Fatalf("expected no delay at 50%% usage, got %s", got)
+	}
+
+	usage = "180,500"
+	if _, err := client.ListActivities(context.Background(), time.Time{}, time.Time{}, 1, 10); err != nil {
+		t.Fatalf("list activities: %v", err)
+	}
+	if got := runner.rateLimitDelay(); got != 3*time.Second {
+		t.Fatalf("expected 3s delay at 90%% usage, got %s", got)
+	}
+}
@@ -0,0 +1,312 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"weirdstats/internal/ingest"
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+	"weirdstats/internal/strava"
+)
+
+func TestRunnerSyncSinceFollowsHasMore(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if page == "1" {
+			_, _ = w.Write([]byte(`[{"id":1,"name":"A","type":"Ride","start_date":"2024-01-01T10:00:00Z"},{"id":2,"name":"B","type":"Ride","start_date":"2024-01-02T10:00:00Z"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":3,"name":"C","type":"Ride","start_date":"2024-01-03T10:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	payloadJSON, _ := json.Marshal(SyncSincePayload{
+		UserID:     1,
+		AfterUnix:  time.Now().Add(-24 * time.Hour).Unix(),
+		PerPage:    2,
+		WindowDays: 2,
+	})
+	if _, err := store.CreateJob(ctx, storage.Job{
+		Type:        JobTypeSyncActivitiesSince,
+		Payload:     string(payloadJSON),
+		Cursor:      `{"page":1}`,
+		MaxAttempts: 10,
+		NextRunAt:   time.Now(),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	runner := &Runner{
+		Store:    store,
+		Ingestor: &ingest.Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}},
+	}
+	if _, err := runner.ProcessNext(ctx); err != nil {
+		t.Fatalf("process first page: %v", err)
+	}
+
+	jobRows, err := store.ListJobsByType(ctx, JobTypeSyncActivitiesSince, 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobRows) != 1 || jobRows[0].Status != "queued" {
+		t.Fatalf("expected sync job to be requeued after a full page, got %+v", jobRows)
+	}
+	cursor, err := parseSyncSinceCursor(jobRows[0].Cursor)
+	if err != nil {
+		t.Fatalf("parse cursor: %v", err)
+	}
+	if cursor.Page != 2 || cursor.Enqueued != 2 {
+		t.Fatalf("expected page 2 with 2 enqueued, got %+v", cursor)
+	}
+
+	if err := runner.handleSyncSince(ctx, jobRows[0]); err != nil {
+		t.Fatalf("process second page: %v", err)
+	}
+	jobRows, err = store.ListJobsByType(ctx, JobTypeSyncActivitiesSince, 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if jobRows[0].Status != "completed" {
+		t.Fatalf("expected sync job to complete after a short page, got %q", jobRows[0].Status)
+	}
+	if len(pages) != 2 || pages[0] != "1" || pages[1] != "2" {
+		t.Fatalf("unexpected pages requested: %v", pages)
+	}
+	queued, err := store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if queued != 3 {
+		t.Fatalf("expected 3 queued activities, got %d", queued)
+	}
+}
+
+func TestRunnerSyncSinceHonorsOldestSyncBoundary(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	oldest := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+	var afters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		afters = append(afters, r.URL.Query().Get("after"))
+		_, _ = w.Write([]byte(`[{"id":1,"name":"Too old","type":"Ride","start_date":"2024-01-01T10:00:00Z"},{"id":2,"name":"B","type":"Ride","start_date":"2024-01-02T10:00:00Z"},{"id":3,"name":"C","type":"Ride","start_date":"2024-01-03T10:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	payloadJSON, _ := json.Marshal(SyncSincePayload{
+		UserID:         1,
+		AfterUnix:      0,
+		PerPage:        10,
+		WindowDays:     36500,
+		OldestSyncUnix: oldest.Unix(),
+	})
+	if _, err := store.CreateJob(ctx, storage.Job{
+		Type:        JobTypeSyncActivitiesSince,
+		Payload:     string(payloadJSON),
+		Cursor:      `{"page":1}`,
+		MaxAttempts: 10,
+		NextRunAt:   time.Now(),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	runner := &Runner{
+		Store:    store,
+		Ingestor: &ingest.Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}},
+	}
+	if _, err := runner.ProcessNext(ctx); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	if len(afters) != 1 || afters[0] != fmt.Sprint(oldest.Unix()) {
+		t.Fatalf("expected a single request starting at the boundary, got after=%v", afters)
+	}
+	jobRows, err := store.ListJobsByType(ctx, JobTypeSyncActivitiesSince, 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobRows) != 1 || jobRows[0].Status != "completed" {
+		t.Fatalf("expected sync job to complete, got %+v", jobRows)
+	}
+	queued, err := store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if queued != 2 {
+		t.Fatalf("expected 2 activities on or after the boundary, got %d", queued)
+	}
+}
+
+func TestRunnerSyncSinceFiltersActivityTypes(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":1,"name":"Commute","type":"Ride","start_date":"2024-01-01T10:00:00Z"},{"id":2,"name":"Jog","type":"Run","start_date":"2024-01-01T12:00:00Z"},{"id":3,"name":"Laps","type":"Swim","start_date":"2024-01-02T08:00:00Z"},{"id":4,"name":"Errands","type":"Ride","start_date":"2024-01-02T10:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	payloadJSON, _ := json.Marshal(SyncSincePayload{
+		UserID:     1,
+		AfterUnix:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		PerPage:    10,
+		WindowDays: 36500,
+		Types:      []string{"Ride"},
+	})
+	if _, err := store.CreateJob(ctx, storage.Job{
+		Type:        JobTypeSyncActivitiesSince,
+		Payload:     string(payloadJSON),
+		Cursor:      `{"page":1}`,
+		MaxAttempts: 10,
+		NextRunAt:   time.Now(),
+	}); err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	runner := &Runner{
+		Store:    store,
+		Ingestor: &ingest.Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}},
+	}
+	if _, err := runner.ProcessNext(ctx); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	jobRows, err := store.ListJobsByType(ctx, JobTypeSyncActivitiesSince, 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	cursor, err := parseSyncSinceCursor(jobRows[0].Cursor)
+	if err != nil {
+		t.Fatalf("parse cursor: %v", err)
+	}
+	if cursor.Enqueued != 2 {
+		t.Fatalf("expected 2 rides enqueued, got %+v", cursor)
+	}
+	queued, err := store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if queued != 2 {
+		t.Fatalf("expected only rides in the queue, got %d", queued)
+	}
+}
+
+func TestRunnerSyncSinceSkipsActivitiesWithStats(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	for _, id := range []int64{1, 3} {
+		if _, err := store.InsertActivity(ctx, storage.Activity{ID: id, UserID: 1, Type: "Ride", Name: "Ride", StartTime: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+		if err := store.UpsertActivityStats(ctx, id, stats.StopStats{StopCount: 1, StopTotalSeconds: 30}); err != nil {
+			t.Fatalf("upsert stats %d: %v", id, err)
+		}
+	}
+	// Activity 2 is stored but was never processed, so it still needs stats.
+	if _, err := store.InsertActivity(ctx, storage.Activity{ID: 2, UserID: 1, Type: "Ride", Name: "Ride", StartTime: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)}, nil); err != nil {
+		t.Fatalf("insert activity 2: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":1,"name":"A","type":"Ride","start_date":"2024-01-01T10:00:00Z"},{"id":2,"name":"B","type":"Ride","start_date":"2024-01-01T12:00:00Z"},{"id":3,"name":"C","type":"Ride","start_date":"2024-01-02T10:00:00Z"},{"id":4,"name":"D","type":"Ride","start_date":"2024-01-02T12:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	runner := &Runner{
+		Store:    store,
+		Ingestor: &ingest.Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}},
+	}
+	runSync := func(force bool) SyncSinceCursor {
+		t.Helper()
+		payloadJSON, _ := json.Marshal(SyncSincePayload{
+			UserID:     1,
+			AfterUnix:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			PerPage:    10,
+			WindowDays: 36500,
+			Force:      force,
+		})
+		jobID, err := store.CreateJob(ctx, storage.Job{
+			Type:        JobTypeSyncActivitiesSince,
+			Payload:     string(payloadJSON),
+			Cursor:      `{"page":1}`,
+			MaxAttempts: 10,
+			NextRunAt:   time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("create job: %v", err)
+		}
+		findJob := func() storage.Job {
+			t.Helper()
+			jobRows, err := store.ListJobsByType(ctx, JobTypeSyncActivitiesSince, 10)
+			if err != nil {
+				t.Fatalf("list jobs: %v", err)
+			}
+			for _, job := range jobRows {
+				if job.ID == jobID {
+					return job
+				}
+			}
+			t.Fatalf("job %d not found", jobID)
+			return storage.Job{}
+		}
+		if err := runner.handleSyncSince(ctx, findJob()); err != nil {
+			t.Fatalf("process: %v", err)
+		}
+		cursor, err := parseSyncSinceCursor(findJob().Cursor)
+		if err != nil {
+			t.Fatalf("parse cursor: %v", err)
+		}
+		return cursor
+	}
+
+	if cursor := runSync(false); cursor.Enqueued != 2 {
+		t.Fatalf("expected only the 2 unprocessed activities enqueued, got %+v", cursor)
+	}
+	if cursor := runSync(true); cursor.Enqueued != 4 {
+		t.Fatalf("expected force to enqueue all 4 activities, got %+v", cursor)
+	}
+}
@@ -0,0 +1,98 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// FileAPI answers NearbyFeatures from a local list of traffic signals so
+// tests and offline setups never hit Overpass.
+type FileAPI struct {
+	Signals []POI
+	// RadiusMeters is the match radius around a stop. Defaults to 40.
+	RadiusMeters float64
+}
+
+type geoJSONFile struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+type signalFileEntry struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Name string  `json:"name"`
+}
+
+// LoadFileAPI reads traffic signals from either a GeoJSON FeatureCollection
+// of Point features or a plain JSON array of {"lat", "lon", "name"} objects.
+func LoadFileAPI(path string) (*FileAPI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signals, err := parseSignalFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &FileAPI{Signals: signals}, nil
+}
+
+func parseSignalFile(data []byte) ([]POI, error) {
+	var entries []signalFileEntry
+	if err := json.Unmarshal(data, &entries); err == nil {
+		signals := make([]POI, 0, len(entries))
+		for _, entry := range entries {
+			signals = append(signals, POI{
+				Feature: Feature{Type: FeatureTrafficLight, Name: entry.Name, Lat: entry.Lat, Lon: entry.Lon},
+			})
+		}
+		return signals, nil
+	}
+
+	var collection geoJSONFile
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.New("expected a GeoJSON FeatureCollection or a JSON array of signals")
+	}
+	var signals []POI
+	for _, feature := range collection.Features {
+		if feature.Geometry.Type != "Point" {
+			continue
+		}
+		var coords []float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &coords); err != nil || len(coords) < 2 {
+			continue
+		}
+		name, _ := feature.Properties["name"].(string)
+		signals = append(signals, POI{
+			Feature: Feature{Type: FeatureTrafficLight, Name: name, Lat: coords[1], Lon: coords[0]},
+		})
+	}
+	return signals, nil
+}
+
+func (f *FileAPI) NearbyFeatures(_ context.Context, lat, lon float64) ([]Feature, error) {
+	radius := f.RadiusMeters
+	if radius <= 0 {
+		radius = defaultSearchRadiusMeters
+	}
+	var features []Feature
+	for _, signal := range f.Signals {
+		if signal.DistanceMeters(lat, lon) <= radius {
+			features = append(features, signal.Feature)
+		}
+	}
+	return features, nil
+}
@@ -0,0 +1,89 @@
+package maps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileAPI_NearbyFeaturesRadiusBoundary(t *testing.T) {
+	api := &FileAPI{
+		Signals: []POI{
+			{Feature: Feature{Type: FeatureTrafficLight, Name: "Alexanderplatz", Lat: 52.52, Lon: 13.405}},
+			{Feature: Feature{Type: FeatureTrafficLight, Name: "Far away", Lat: 52.53, Lon: 13.405}},
+		},
+		RadiusMeters: 40,
+	}
+	ctx := context.Background()
+
+	// ~0.00036 degrees of latitude is ~40m.
+	lat := 52.52 + 0.00036
+	edge := haversineMeters(lat, 13.405, 52.52, 13.405)
+
+	cases := []struct {
+		name   string
+		radius float64
+		lat    float64
+		want   int
+	}{
+		{name: "on the stop", radius: 40, lat: 52.52, want: 1},
+		{name: "well inside", radius: 40, lat: 52.52 + 0.0002, want: 1},
+		{name: "exactly at radius", radius: edge, lat: lat, want: 1},
+		{name: "just outside radius", radius: edge - 0.01, lat: lat, want: 0},
+		{name: "well outside", radius: 40, lat: 52.521, want: 0},
+	}
+	for _, tc := range cases {
+		api.RadiusMeters = tc.radius
+		features, err := api.NearbyFeatures(ctx, tc.lat, 13.405)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(features) != tc.want {
+			t.Fatalf("%s: expected %d features, got %v", tc.name, tc.want, features)
+		}
+		if tc.want == 1 && (features[0].Type != FeatureTrafficLight || features[0].Name != "Alexanderplatz" || features[0].Lat != 52.52 || features[0].Lon != 13.405) {
+			t.Fatalf("%s: unexpected feature %+v", tc.name, features[0])
+		}
+	}
+}
+
+func TestLoadFileAPI(t *testing.T) {
+	dir := t.TempDir()
+	geojson := filepath.Join(dir, "signals.geojson")
+	if err := os.WriteFile(geojson, []byte(`{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "geometry": {"type": "Point", "coordinates": [13.405, 52.52]}, "properties": {"name": "Alexanderplatz"}},
+    {"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[13.4, 52.5], [13.5, 52.6]]}, "properties": {}}
+  ]
+}`), 0o644); err != nil {
+		t.Fatalf("write geojson: %v", err)
+	}
+	plain := filepath.Join(dir, "signals.json")
+	if err := os.WriteFile(plain, []byte(`[{"lat": 52.52, "lon": 13.405, "name": "Alexanderplatz"}]`), 0o644); err != nil {
+		t.Fatalf("write json: %v", err)
+	}
+
+	for _, path := range []string{geojson, plain} {
+		api, err := LoadFileAPI(path)
+		if err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+		if len(api.Signals) != 1 {
+			t.Fatalf("%s: expected 1 signal, got %d", path, len(api.Signals))
+		}
+		signal := api.Signals[0]
+		if signal.Lat != 52.52 || signal.Lon != 13.405 || signal.Name != "Alexanderplatz" {
+			t.Fatalf("%s: unexpected signal %+v", path, signal)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"type": "Feature"}`), 0o644); err != nil {
+		t.Fatalf("write bad: %v", err)
+	}
+	if _, err := LoadFileAPI(bad); err == nil {
+		t.Fatalf("expected error for non-collection GeoJSON")
+	}
+}
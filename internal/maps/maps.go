@@ -1,5 +1,7 @@
 package maps
 
+import "context"
+
 type FeatureType string
 
 const (
@@ -14,12 +16,27 @@ const (
 type Feature struct {
 	Type FeatureType
 	Name string
+	// Lat and Lon locate the feature; both are 0 when the source did not
+	// report coordinates.
+	Lat float64
+	Lon float64
+}
+
+// HasLocation reports whether the feature carries coordinates.
+func (f Feature) HasLocation() bool {
+	return f.Lat != 0 || f.Lon != 0
 }
 
+// DistanceMeters returns the great-circle distance from the feature to the
+// given point.
+func (f Feature) DistanceMeters(lat, lon float64) float64 {
+	return haversineMeters(lat, lon, f.Lat, f.Lon)
+}
+
+// POI is a feature with its OSM tags. Its coordinates are the embedded
+// Feature's Lat and Lon.
 type POI struct {
 	Feature
-	Lat  float64
-	Lon  float64
 	Tags map[string]string
 }
 
@@ -63,5 +80,12 @@ type MapContext struct {
 }
 
 type API interface {
-	NearbyFeatures(lat, lon float64) ([]Feature, error)
+	NearbyFeatures(ctx context.Context, lat, lon float64) ([]Feature, error)
+}
+
+// SignalPrefetcher is implemented by APIs that can fetch every traffic signal
+// in a bounding box at once and then match points against them in memory.
+type SignalPrefetcher interface {
+	FetchSignalsInBBox(ctx context.Context, bbox BBox) ([]POI, error)
+	SignalsNear(signals []POI, lat, lon float64) []Feature
 }
@@ -0,0 +1,307 @@
+// Package mapstest serves recorded Overpass data over HTTP so code built on
+// maps.OverpassClient can be tested end-to-end without network access, and
+// provides StubAPI for tests that only need a maps.API.
+package mapstest
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Element mirrors an element of an Overpass JSON response.
+type Element struct {
+	Type     string            `json:"type"`
+	ID       int64             `json:"id"`
+	Lat      float64           `json:"lat,omitempty"`
+	Lon      float64           `json:"lon,omitempty"`
+	Center   *LatLon           `json:"center,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Geometry []LatLon          `json:"geometry,omitempty"`
+}
+
+type LatLon struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// OverpassServer answers Overpass QL queries from a fixed set of elements. It
+// understands the statements OverpassClient sends: node/way/nwr with tag
+// filters plus either an around: or a bounding-box filter.
+type OverpassServer struct {
+	*httptest.Server
+
+	elements []Element
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// NewOverpassServer starts a replay server for elements and closes it when
+// the test finishes. Point OverpassClient.BaseURL at its URL.
+func NewOverpassServer(t testing.TB, elements []Element) *OverpassServer {
+	t.Helper()
+	s := &OverpassServer{elements: elements}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewRecordingServer loads a recording with LoadRecording and serves it.
+func NewRecordingServer(t testing.TB, path string) *OverpassServer {
+	t.Helper()
+	elements, err := LoadRecording(path)
+	if err != nil {
+		t.Fatalf("load overpass recording: %v", err)
+	}
+	return NewOverpassServer(t, elements)
+}
+
+// Queries returns every query the server has answered, in order.
+func (s *OverpassServer) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+type recordingFile struct {
+	Elements []Element `json:"elements"`
+	Stops    []struct {
+		Lat      float64 `json:"lat"`
+		Lon      float64 `json:"lon"`
+		Features []struct {
+			Type string
+			Name string
+		} `json:"nearby_features"`
+	} `json:"stops"`
+}
+
+// LoadRecording reads either a raw Overpass JSON response or a stop recording
+// written by the RECORD_OVERPASS test. Recorded stop features only keep their
+// type and name, so each one becomes a node placed on its stop.
+func LoadRecording(path string) ([]Element, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec recordingFile
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if rec.Elements != nil {
+		return rec.Elements, nil
+	}
+	if len(rec.Stops) == 0 {
+		return nil, errors.New("recording has neither elements nor stops")
+	}
+	var elements []Element
+	for _, stop := range rec.Stops {
+		for _, feature := range stop.Features {
+			tags := featureTags(feature.Type)
+			if tags == nil {
+				continue
+			}
+			if feature.Name != "" {
+				tags["name"] = feature.Name
+			}
+			elements = append(elements, Element{
+				Type: "node",
+				ID:   int64(len(elements) + 1),
+				Lat:  stop.Lat,
+				Lon:  stop.Lon,
+				Tags: tags,
+			})
+		}
+	}
+	return elements, nil
+}
+
+func featureTags(featureType string) map[string]string {
+	switch featureType {
+	case "traffic_light":
+		return map[string]string{"highway": "traffic_signals"}
+	case "cafe", "restaurant", "fast_food", "bar":
+		return map[string]string{"amenity": featureType}
+	}
+	return nil
+}
+
+func (s *OverpassServer) serve(w http.ResponseWriter, r *http.Request) {
+	query := r.FormValue("data")
+	if query == "" {
+		http.Error(w, "missing data parameter", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.mu.Unlock()
+
+	statements, err := parseStatements(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	matched := make([]Element, 0)
+	for _, el := range s.elements {
+		for _, st := range statements {
+			if st.matches(el) {
+				matched = append(matched, el)
+				break
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Elements []Element `json:"elements"`
+	}{Elements: matched})
+}
+
+var (
+	statementPattern = regexp.MustCompile(`(?m)^\s*(node|way|relation|nwr)((?:\[[^\]]*\]|\([^)]*\))*)\s*;`)
+	filterPattern    = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)`)
+	tagPattern       = regexp.MustCompile(`^\["([^"]+)"(?:(=|~)"([^"]*)")?\]$`)
+)
+
+type tagFilter struct {
+	key   string
+	value string
+	regex *regexp.Regexp
+}
+
+type statement struct {
+	kind string
+	tags []tagFilter
+
+	around           bool
+	radius, lat, lon float64
+
+	bbox                     bool
+	south, west, north, east float64
+}
+
+func parseStatements(query string) ([]statement, error) {
+	var statements []statement
+	for _, m := range statementPattern.FindAllStringSubmatch(query, -1) {
+		st := statement{kind: m[1]}
+		for _, filter := range filterPattern.FindAllString(m[2], -1) {
+			if strings.HasPrefix(filter, "[") {
+				tm := tagPattern.FindStringSubmatch(filter)
+				if tm == nil {
+					return nil, errors.New("unsupported tag filter " + filter)
+				}
+				tf := tagFilter{key: tm[1], value: tm[3]}
+				if tm[2] == "~" {
+					re, err := regexp.Compile(tm[3])
+					if err != nil {
+						return nil, err
+					}
+					tf.regex = re
+				}
+				st.tags = append(st.tags, tf)
+				continue
+			}
+			if err := st.parseArea(strings.Trim(filter, "()")); err != nil {
+				return nil, err
+			}
+		}
+		statements = append(statements, st)
+	}
+	if len(statements) == 0 {
+		return nil, errors.New("no supported statements in query")
+	}
+	return statements, nil
+}
+
+func (st *statement) parseArea(area string) error {
+	around := strings.HasPrefix(area, "around:")
+	nums, err := parseFloats(strings.TrimPrefix(area, "around:"))
+	if err != nil {
+		return err
+	}
+	switch {
+	case around && len(nums) == 3:
+		st.around = true
+		st.radius, st.lat, st.lon = nums[0], nums[1], nums[2]
+	case !around && len(nums) == 4:
+		st.bbox = true
+		st.south, st.west, st.north, st.east = nums[0], nums[1], nums[2], nums[3]
+	default:
+		return errors.New("unsupported area filter " + area)
+	}
+	return nil
+}
+
+func parseFloats(list string) ([]float64, error) {
+	parts := strings.Split(list, ",")
+	nums := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, v)
+	}
+	return nums, nil
+}
+
+func (st statement) matches(el Element) bool {
+	if st.kind != "nwr" && st.kind != el.Type {
+		return false
+	}
+	for _, tf := range st.tags {
+		value, ok := el.Tags[tf.key]
+		switch {
+		case !ok:
+			return false
+		case tf.regex != nil:
+			if !tf.regex.MatchString(value) {
+				return false
+			}
+		case tf.value != "" && value != tf.value:
+			return false
+		}
+	}
+	for _, p := range el.points() {
+		if st.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (st statement) contains(p LatLon) bool {
+	switch {
+	case st.around:
+		return haversineMeters(st.lat, st.lon, p.Lat, p.Lon) <= st.radius
+	case st.bbox:
+		return p.Lat >= st.south && p.Lat <= st.north && p.Lon >= st.west && p.Lon <= st.east
+	}
+	return true
+}
+
+func (el Element) points() []LatLon {
+	if el.Center != nil {
+		return []LatLon{*el.Center}
+	}
+	if len(el.Geometry) > 0 {
+		return el.Geometry
+	}
+	return []LatLon{{Lat: el.Lat, Lon: el.Lon}}
+}
+
+func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const R = 6371000.0
+	toRad := func(deg float64) float64 { return deg * (math.Pi / 180.0) }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Sin(dLon/2)*math.Sin(dLon/2)*math.Cos(toRad(lat1))*math.Cos(toRad(lat2))
+	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
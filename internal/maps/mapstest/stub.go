@@ -0,0 +1,49 @@
+package mapstest
+
+import (
+	"context"
+	"sync"
+
+	"weirdstats/internal/maps"
+)
+
+// Query is one NearbyFeatures lookup seen by StubAPI.
+type Query struct {
+	Lat float64
+	Lon float64
+}
+
+// StubAPI is an in-memory API for tests. It answers every lookup with
+// Features, or with Lookup when set, and records each queried coordinate so
+// tests can assert how many map calls were made and where.
+type StubAPI struct {
+	Features []maps.Feature
+	Lookup   func(lat, lon float64) []maps.Feature
+
+	mu      sync.Mutex
+	queries []Query
+}
+
+func (s *StubAPI) NearbyFeatures(_ context.Context, lat, lon float64) ([]maps.Feature, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, Query{Lat: lat, Lon: lon})
+	s.mu.Unlock()
+	if s.Lookup != nil {
+		return s.Lookup(lat, lon), nil
+	}
+	return s.Features, nil
+}
+
+// Calls returns how many lookups have been made.
+func (s *StubAPI) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queries)
+}
+
+// Queries returns the queried coordinates in call order.
+func (s *StubAPI) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
@@ -1,13 +1,17 @@
 package maps
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +20,11 @@ import (
 const DefaultOverpassURL = "https://overpass-api.de/api/interpreter"
 const defaultCacheTTL = 24 * time.Hour
 const defaultUserAgent = "weirdstats/1.0 (+https://github.com/ptmt/weirdstats)"
+const defaultSearchRadiusMeters = 40
+
+// maxRetryAfter caps how long a Retry-After header can stall a query; a
+// mirror asking for longer is treated as if it asked for this long.
+const maxRetryAfter = time.Minute
 
 type OverpassClient struct {
 	BaseURL      string
@@ -27,20 +36,43 @@ type OverpassClient struct {
 	BackoffBase  time.Duration
 	MirrorURLs   []string
 	UserAgent    string
-
-	mu    sync.Mutex
-	cache map[string]cacheEntry
-}
-
-func (c *OverpassClient) NearbyFeatures(lat, lon float64) ([]Feature, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.effectiveTimeout())
+	// SearchRadiusMeters is the around: radius for point lookups. Defaults to 40.
+	SearchRadiusMeters int
+	// Rand jitters retry backoff so workers don't retry in lockstep. Nil uses
+	// the global source; tests can pass a seeded one.
+	Rand *rand.Rand
+	// MirrorFailureThreshold consecutive failures take a mirror out of
+	// rotation for MirrorCooldown. Defaults to 3 failures and 1 minute.
+	MirrorFailureThreshold int
+	MirrorCooldown         time.Duration
+	// CacheNamespace scopes cached responses, so a different dataset (e.g.
+	// user-specific map data) never reads another namespace's entries for the
+	// same query. The default empty namespace is shared.
+	CacheNamespace string
+
+	// wait sleeps between retries; tests replace it to observe delays.
+	wait func(ctx context.Context, d time.Duration) error
+	now  func() time.Time
+
+	mu      sync.Mutex
+	cache   map[string]cacheEntry
+	mirrors map[string]*mirrorHealth
+}
+
+type mirrorHealth struct {
+	failures  int
+	openUntil time.Time
+}
+
+func (c *OverpassClient) NearbyFeatures(ctx context.Context, lat, lon float64) ([]Feature, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.effectiveTimeout())
 	defer cancel()
 
 	query := fmt.Sprintf(`[out:json][timeout:25];
 (
-  node(around:40,%.6f,%.6f)["highway"="traffic_signals"];
+  node(around:%d,%.6f,%.6f)["highway"="traffic_signals"];
 );
-out body;`, lat, lon)
+out body;`, c.effectiveSearchRadius(), lat, lon)
 
 	elements, err := c.fetchWithCache(ctx, query)
 	if err != nil {
@@ -50,8 +82,8 @@ out body;`, lat, lon)
 	var features []Feature
 	for _, el := range elements {
 		if el.Tags["highway"] == "traffic_signals" {
-			name := el.Tags["name"]
-			features = append(features, Feature{Type: FeatureTrafficLight, Name: name})
+			lat, lon := el.coordinates()
+			features = append(features, Feature{Type: FeatureTrafficLight, Name: el.Tags["name"], Lat: lat, Lon: lon})
 		}
 	}
 	return features, nil
@@ -87,9 +119,30 @@ out center;`, strings.Join(queries, "\n"))
 	return poisFromOverpassElements(elements), nil
 }
 
+// FetchSignalsInBBox returns all traffic signals inside bbox, padded by the
+// search radius so stops near the edge still see their signals.
+func (c *OverpassClient) FetchSignalsInBBox(ctx context.Context, bbox BBox) ([]POI, error) {
+	return c.FetchPOIs(ctx, bbox.Pad(float64(c.effectiveSearchRadius())), true, false)
+}
+
+// SignalsNear matches prefetched signals against a point using the search radius.
+func (c *OverpassClient) SignalsNear(signals []POI, lat, lon float64) []Feature {
+	radius := float64(c.effectiveSearchRadius())
+	var features []Feature
+	for _, signal := range signals {
+		if signal.Type != FeatureTrafficLight {
+			continue
+		}
+		if signal.DistanceMeters(lat, lon) <= radius {
+			features = append(features, signal.Feature)
+		}
+	}
+	return features
+}
+
 func (c *OverpassClient) FetchNearbyFoodPOIs(ctx context.Context, lat, lon float64, radiusMeters int) ([]POI, error) {
 	if radiusMeters <= 0 {
-		radiusMeters = 40
+		radiusMeters = c.effectiveSearchRadius()
 	}
 
 	query := fmt.Sprintf(`[out:json][timeout:25];
@@ -172,29 +225,55 @@ out geom;`, radiusMeters, lat, lon)
 	if err != nil {
 		return nil, err
 	}
+	return roadsFromOverpassElements(elements), nil
+}
+
+// FetchRoads returns every highway way inside bbox with its geometry, for
+// running DetectRoadCrossing over a whole activity.
+func (c *OverpassClient) FetchRoads(ctx context.Context, bbox BBox) ([]Road, error) {
+	query := fmt.Sprintf(`[out:json][timeout:25];
+way["highway"](%s);
+out geom;`, bbox.String())
+
+	ctx, cancel := context.WithTimeout(ctx, c.effectiveTimeout())
+	defer cancel()
 
+	elements, err := c.fetchWithCache(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return roadsFromOverpassElements(elements), nil
+}
+
+// roadsFromOverpassElements keeps ways with at least two geometry points;
+// ways returned without geometry are skipped.
+func roadsFromOverpassElements(elements []overpassElement) []Road {
 	var roads []Road
 	for _, el := range elements {
 		if el.Type != "way" || len(el.Geometry) < 2 {
 			continue
 		}
-		geom := make([]LatLon, len(el.Geometry))
-		for i, pt := range el.Geometry {
-			geom[i] = LatLon{Lat: pt.Lat, Lon: pt.Lon}
-		}
 		roads = append(roads, Road{
 			ID:       el.ID,
 			Name:     el.Tags["name"],
 			Highway:  el.Tags["highway"],
-			Geometry: geom,
+			Geometry: latLonGeometry(el.Geometry),
 		})
 	}
-	return roads, nil
+	return roads
+}
+
+func cacheKey(namespace, query string) string {
+	if namespace == "" {
+		return query
+	}
+	return namespace + "\x00" + query
 }
 
 func (c *OverpassClient) fetchWithCache(ctx context.Context, query string) ([]overpassElement, error) {
+	key := cacheKey(c.CacheNamespace, query)
 	if ttl := c.effectiveCacheTTL(); ttl > 0 {
-		if cached, ok := c.getCached(query); ok {
+		if cached, ok := c.getCached(key); ok {
 			return cached, nil
 		}
 	}
@@ -203,7 +282,7 @@ func (c *OverpassClient) fetchWithCache(ctx context.Context, query string) ([]ov
 		return nil, err
 	}
 	if ttl := c.effectiveCacheTTL(); ttl > 0 {
-		c.setCached(query, elements, ttl)
+		c.setCached(key, elements, ttl)
 	}
 	return elements, nil
 }
@@ -224,25 +303,98 @@ func (c *OverpassClient) runQueryWithRetry(ctx context.Context, query string) ([
 		base := endpoints[attempt%len(endpoints)]
 		elements, status, err := c.runQueryOnce(ctx, base, query)
 		if err == nil {
+			c.recordMirrorResult(base, true)
 			return elements, nil
 		}
 		lastErr = err
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
+		if isMirrorFault(status, err) {
+			c.recordMirrorResult(base, false)
+		}
 		if !isRetryable(status, err) || attempt == maxAttempts-1 {
 			break
 		}
-		sleep := baseSleep << attempt
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(sleep):
+		sleep := c.retryBackoff(baseSleep, attempt)
+		// Retry-After only describes the mirror that sent it, so it is
+		// irrelevant when the next attempt goes to a different one.
+		var statusErr *overpassStatusError
+		next := endpoints[(attempt+1)%len(endpoints)]
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 && next == base {
+			sleep = statusErr.RetryAfter
+			if sleep > maxRetryAfter {
+				sleep = maxRetryAfter
+			}
+		}
+		if err := c.sleep(ctx, sleep); err != nil {
+			return nil, err
 		}
 	}
 	return nil, lastErr
 }
 
+func (c *OverpassClient) sleep(ctx context.Context, d time.Duration) error {
+	if c.wait != nil {
+		return c.wait(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type overpassStatusError struct {
+	Status     int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *overpassStatusError) Error() string {
+	return fmt.Sprintf("overpass status %d: %s", e.Status, e.Body)
+}
+
+// parseRetryAfter accepts both forms of Retry-After: delay seconds or an
+// HTTP date. Unparseable or past values return 0.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	if d := when.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// retryBackoff applies full jitter: a random duration in [0, base<<attempt].
+func (c *OverpassClient) retryBackoff(base time.Duration, attempt int) time.Duration {
+	ceiling := int64(base << attempt)
+	if ceiling <= 0 {
+		return 0
+	}
+	if c.Rand == nil {
+		return time.Duration(rand.Int63n(ceiling + 1))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.Rand.Int63n(ceiling + 1))
+}
+
 func (c *OverpassClient) runQueryOnce(ctx context.Context, base string, query string) ([]overpassElement, int, error) {
 	endpoint, err := url.Parse(base)
 	if err != nil {
@@ -257,6 +409,9 @@ func (c *OverpassClient) runQueryOnce(ctx context.Context, base string, query st
 		return nil, 0, err
 	}
 	req.Header.Set("Accept", "application/json")
+	// Setting Accept-Encoding ourselves turns off the transport's transparent
+	// decompression, so the body is unwrapped below.
+	req.Header.Set("Accept-Encoding", "gzip")
 	req.Header.Set("User-Agent", c.effectiveUserAgent())
 
 	resp, err := c.httpClient().Do(req)
@@ -265,13 +420,27 @@ func (c *OverpassClient) runQueryOnce(ctx context.Context, base string, query st
 	}
 	defer resp.Body.Close()
 
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, resp.StatusCode, fmt.Errorf("overpass gzip: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
-		return nil, resp.StatusCode, fmt.Errorf("overpass status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		body, _ := io.ReadAll(io.LimitReader(body, 512))
+		return nil, resp.StatusCode, &overpassStatusError{
+			Status:     resp.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	var decoded overpassResponse
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+	if err := json.NewDecoder(body).Decode(&decoded); err != nil {
 		return nil, resp.StatusCode, err
 	}
 
@@ -292,6 +461,13 @@ func (c *OverpassClient) effectiveUserAgent() string {
 	return defaultUserAgent
 }
 
+func (c *OverpassClient) effectiveSearchRadius() int {
+	if c.SearchRadiusMeters > 0 {
+		return c.SearchRadiusMeters
+	}
+	return defaultSearchRadiusMeters
+}
+
 func (c *OverpassClient) effectiveTimeout() time.Duration {
 	if c.Timeout > 0 {
 		return c.Timeout
@@ -338,9 +514,22 @@ func (b BBox) String() string {
 	return fmt.Sprintf("%f,%f,%f,%f", b.South, b.West, b.North, b.East)
 }
 
+// Pad grows the box by roughly the given number of meters on every side.
+func (b BBox) Pad(meters float64) BBox {
+	const metersPerDegree = 111320.0
+	dLat := meters / metersPerDegree
+	midLat := (b.South + b.North) / 2
+	cosLat := math.Cos(midLat * math.Pi / 180)
+	if cosLat < 0.01 {
+		cosLat = 0.01
+	}
+	dLon := meters / (metersPerDegree * cosLat)
+	return BBox{South: b.South - dLat, West: b.West - dLon, North: b.North + dLat, East: b.East + dLon}
+}
+
 func (c *OverpassClient) baseURLs() []string {
 	if len(c.MirrorURLs) > 0 {
-		return c.MirrorURLs
+		return c.healthyMirrors()
 	}
 	if c.BaseURL != "" {
 		return []string{c.BaseURL}
@@ -348,6 +537,66 @@ func (c *OverpassClient) baseURLs() []string {
 	return []string{DefaultOverpassURL}
 }
 
+// healthyMirrors drops mirrors whose breaker is open. If every mirror is
+// tripped, all of them are returned so queries still get attempted.
+func (c *OverpassClient) healthyMirrors() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	healthy := make([]string, 0, len(c.MirrorURLs))
+	for _, mirror := range c.MirrorURLs {
+		if state := c.mirrors[mirror]; state != nil && now.Before(state.openUntil) {
+			continue
+		}
+		healthy = append(healthy, mirror)
+	}
+	if len(healthy) == 0 {
+		return c.MirrorURLs
+	}
+	return healthy
+}
+
+func (c *OverpassClient) recordMirrorResult(base string, ok bool) {
+	if len(c.MirrorURLs) < 2 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mirrors == nil {
+		c.mirrors = make(map[string]*mirrorHealth)
+	}
+	state := c.mirrors[base]
+	if state == nil {
+		state = &mirrorHealth{}
+		c.mirrors[base] = state
+	}
+	if ok {
+		state.failures = 0
+		state.openUntil = time.Time{}
+		return
+	}
+	state.failures++
+	threshold := c.MirrorFailureThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if state.failures >= threshold {
+		cooldown := c.MirrorCooldown
+		if cooldown <= 0 {
+			cooldown = time.Minute
+		}
+		state.openUntil = c.clock().Add(cooldown)
+		state.failures = 0
+	}
+}
+
+func (c *OverpassClient) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
 func classifyPOI(tags map[string]string) FeatureType {
 	switch tags["amenity"] {
 	case "cafe":
@@ -385,9 +634,9 @@ func poisFromOverpassElements(elements []overpassElement) []POI {
 			Feature: Feature{
 				Type: poiType,
 				Name: el.Tags["name"],
+				Lat:  lat,
+				Lon:  lon,
 			},
-			Lat:  lat,
-			Lon:  lon,
 			Tags: el.Tags,
 		})
 	}
@@ -428,9 +677,9 @@ func mapContextFromOverpassElements(elements []overpassElement) MapContext {
 				Feature: Feature{
 					Type: FeatureType(el.Tags["natural"]),
 					Name: el.Tags["name"],
+					Lat:  lat,
+					Lon:  lon,
 				},
-				Lat:  lat,
-				Lon:  lon,
 				Tags: el.Tags,
 			})
 		}
@@ -521,6 +770,16 @@ type cacheEntry struct {
 	expiresAt time.Time
 }
 
+// isMirrorFault reports whether a failed query says something about the
+// mirror's health: transport errors, 5xx and 429. Other 4xx responses and
+// bad payloads come from the query itself and would fail on every mirror.
+func isMirrorFault(status int, err error) bool {
+	if isRetryable(status, err) {
+		return true
+	}
+	return status == 0 || status >= http.StatusInternalServerError
+}
+
 func isRetryable(status int, err error) bool {
 	if status == http.StatusTooManyRequests || status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
 		return true
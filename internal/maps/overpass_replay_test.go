@@ -0,0 +1,114 @@
+package maps_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"weirdstats/internal/maps"
+	"weirdstats/internal/maps/mapstest"
+)
+
+func TestOverpassClient_FetchPOIsFromRecording(t *testing.T) {
+	server := mapstest.NewRecordingServer(t, filepath.Join("..", "..", "testdata", "overpass", "ride_sample_pois.json"))
+	client := &maps.OverpassClient{BaseURL: server.URL, HTTPClient: server.Client(), DisableCache: true}
+	ctx := context.Background()
+	bbox := maps.BBox{South: 48.14, West: 11.44, North: 48.17, East: 11.53}
+
+	pois, err := client.FetchPOIs(ctx, bbox, true, true)
+	if err != nil {
+		t.Fatalf("FetchPOIs error: %v", err)
+	}
+	// The bench fails the amenity filter and the bar is outside the bbox.
+	want := []struct {
+		typ  maps.FeatureType
+		name string
+		lat  float64
+	}{
+		{typ: maps.FeatureTrafficLight, lat: 48.1612977},
+		{typ: maps.FeatureTrafficLight, lat: 48.1614126},
+		{typ: maps.FeatureTrafficLight, name: "Dachauer Straße / Landshuter Allee", lat: 48.1611902},
+		{typ: maps.FeatureCafe, name: "Kaffeerösterei Moosach", lat: 48.1620311},
+		{typ: maps.FeatureRestaurant, name: "Wirtshaus am Olympiapark", lat: 48.1629874},
+	}
+	if len(pois) != len(want) {
+		t.Fatalf("expected %d pois, got %+v", len(want), pois)
+	}
+	for i, w := range want {
+		if pois[i].Type != w.typ || pois[i].Name != w.name || pois[i].Lat != w.lat {
+			t.Fatalf("poi %d: expected %+v, got %+v", i, w, pois[i])
+		}
+	}
+	if pois[3].Tags["cuisine"] != "coffee_shop" {
+		t.Fatalf("expected tags to be kept, got %v", pois[3].Tags)
+	}
+
+	signals, err := client.FetchPOIs(ctx, bbox, true, false)
+	if err != nil {
+		t.Fatalf("FetchPOIs signals error: %v", err)
+	}
+	if len(signals) != 3 {
+		t.Fatalf("expected 3 signals, got %+v", signals)
+	}
+
+	features, err := client.NearbyFeatures(ctx, 48.161334, 11.511227)
+	if err != nil {
+		t.Fatalf("NearbyFeatures error: %v", err)
+	}
+	if len(features) != 3 {
+		t.Fatalf("expected 3 signals around the first stop, got %+v", features)
+	}
+	if got := len(server.Queries()); got != 3 {
+		t.Fatalf("expected 3 queries, got %d", got)
+	}
+}
+
+func TestOverpassClient_NearbyFeaturesFromStopRecording(t *testing.T) {
+	path := filepath.Join("..", "..", "testdata", "overpass", "ride_sample.json")
+	mock, err := maps.LoadRecordingMock(path)
+	if err != nil {
+		t.Fatalf("load recording: %v", err)
+	}
+	server := mapstest.NewRecordingServer(t, path)
+	// Recorded features sit on their stop, and two stops are ~25m apart, so
+	// keep the radius tight enough to see only each stop's own features.
+	client := &maps.OverpassClient{BaseURL: server.URL, HTTPClient: server.Client(), DisableCache: true, SearchRadiusMeters: 10}
+
+	for i, stop := range mock.Stops {
+		features, err := client.NearbyFeatures(context.Background(), stop.Lat, stop.Lon)
+		if err != nil {
+			t.Fatalf("stop %d: %v", i, err)
+		}
+		if len(features) != len(stop.Features) {
+			t.Fatalf("stop %d: expected %d features, got %+v", i, len(stop.Features), features)
+		}
+	}
+}
+
+func TestOverpassClient_SignalCoordinatesPropagate(t *testing.T) {
+	server := mapstest.NewOverpassServer(t, []mapstest.Element{
+		{Type: "node", ID: 1, Lat: 52.52009, Lon: 13.405, Tags: map[string]string{"highway": "traffic_signals", "name": "Alexanderplatz"}},
+	})
+	client := &maps.OverpassClient{BaseURL: server.URL, HTTPClient: server.Client(), DisableCache: true}
+	ctx := context.Background()
+
+	features, err := client.NearbyFeatures(ctx, 52.52, 13.405)
+	if err != nil {
+		t.Fatalf("NearbyFeatures error: %v", err)
+	}
+	if len(features) != 1 || features[0].Lat != 52.52009 || features[0].Lon != 13.405 {
+		t.Fatalf("expected signal coordinates, got %+v", features)
+	}
+	if d := features[0].DistanceMeters(52.52, 13.405); d < 9 || d > 11 {
+		t.Fatalf("expected ~10m from the stop, got %.1f", d)
+	}
+
+	signals, err := client.FetchSignalsInBBox(ctx, maps.BBox{South: 52.51, West: 13.40, North: 52.53, East: 13.41})
+	if err != nil {
+		t.Fatalf("FetchSignalsInBBox error: %v", err)
+	}
+	near := client.SignalsNear(signals, 52.52, 13.405)
+	if len(near) != 1 || near[0].Lat != 52.52009 || near[0].Lon != 13.405 || !near[0].HasLocation() {
+		t.Fatalf("expected prefetched signal coordinates, got %+v", near)
+	}
+}
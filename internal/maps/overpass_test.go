@@ -1,13 +1,17 @@
 package maps
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestOverpassClient_RequestsAndParses(t *testing.T) {
@@ -36,7 +40,7 @@ func TestOverpassClient_RequestsAndParses(t *testing.T) {
 		DisableCache: true,
 	}
 
-	features, err := client.NearbyFeatures(40.0, -73.0)
+	features, err := client.NearbyFeatures(context.Background(), 40.0, -73.0)
 	if err != nil {
 		t.Fatalf("NearbyFeatures error: %v", err)
 	}
@@ -81,11 +85,48 @@ func TestOverpassClient_CustomUserAgent(t *testing.T) {
 		UserAgent:    customUserAgent,
 	}
 
-	if _, err := client.NearbyFeatures(40.0, -73.0); err != nil {
+	if _, err := client.NearbyFeatures(context.Background(), 40.0, -73.0); err != nil {
 		t.Fatalf("NearbyFeatures error: %v", err)
 	}
 }
 
+func TestOverpassClient_SearchRadius(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("data"))
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{
+		BaseURL:            server.URL,
+		HTTPClient:         server.Client(),
+		DisableCache:       true,
+		SearchRadiusMeters: 75,
+	}
+
+	if _, err := client.NearbyFeatures(context.Background(), 40.0, -73.0); err != nil {
+		t.Fatalf("NearbyFeatures error: %v", err)
+	}
+	if _, err := client.FetchNearbyFoodPOIs(context.Background(), 40.0, -73.0, 0); err != nil {
+		t.Fatalf("FetchNearbyFoodPOIs error: %v", err)
+	}
+	client.SearchRadiusMeters = -5
+	if _, err := client.NearbyFeatures(context.Background(), 40.0, -73.0); err != nil {
+		t.Fatalf("NearbyFeatures error: %v", err)
+	}
+
+	if len(queries) != 3 {
+		t.Fatalf("expected 3 queries, got %d", len(queries))
+	}
+	if !strings.Contains(queries[0], "around:75,") || !strings.Contains(queries[1], "around:75,") {
+		t.Fatalf("expected configured radius in queries, got %q and %q", queries[0], queries[1])
+	}
+	if !strings.Contains(queries[2], "around:40,") {
+		t.Fatalf("expected default radius for non-positive value, got %q", queries[2])
+	}
+}
+
 func TestOverpassClient_FetchNearbyFoodPOIs(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("data")
@@ -348,7 +389,7 @@ func TestOverpassClient_RoundRobinMirrors(t *testing.T) {
 		DisableCache: true,
 	}
 
-	features, err := client.NearbyFeatures(0, 0)
+	features, err := client.NearbyFeatures(context.Background(), 0, 0)
 	if err != nil {
 		t.Fatalf("round robin failed: %v", err)
 	}
@@ -359,3 +400,383 @@ func TestOverpassClient_RoundRobinMirrors(t *testing.T) {
 		t.Fatalf("expected 1 hit per mirror, got first=%d second=%d", firstHits, secondHits)
 	}
 }
+
+func TestOverpassClient_NearbyFeaturesHonorsCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &OverpassClient{
+		BaseURL:      server.URL,
+		HTTPClient:   server.Client(),
+		DisableCache: true,
+		Timeout:      time.Minute,
+		MaxAttempts:  1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	started := time.Now()
+	_, err := client.NearbyFeatures(ctx, 40.0, -73.0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("expected prompt return after cancel, took %s", elapsed)
+	}
+}
+
+func TestOverpassClient_CachesEmptyResults(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(`{"elements":[]}`))
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{
+		BaseURL:    server.URL,
+		HTTPClient: server.Client(),
+	}
+
+	for i := 0; i < 2; i++ {
+		features, err := client.NearbyFeatures(context.Background(), 40.0, -73.0)
+		if err != nil {
+			t.Fatalf("NearbyFeatures error: %v", err)
+		}
+		if len(features) != 0 {
+			t.Fatalf("expected no features, got %+v", features)
+		}
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected empty result to be cached after 1 request, got %d", got)
+	}
+}
+
+func TestOverpassClient_CacheNamespacesAreIndependent(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(`{"elements":[]}`))
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{
+		BaseURL:    server.URL,
+		HTTPClient: server.Client(),
+	}
+
+	for round := 0; round < 2; round++ {
+		for _, namespace := range []string{"", "dataset-a", "dataset-b"} {
+			client.CacheNamespace = namespace
+			if _, err := client.NearbyFeatures(context.Background(), 40.0, -73.0); err != nil {
+				t.Fatalf("NearbyFeatures error: %v", err)
+			}
+		}
+	}
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Fatalf("expected one request per namespace, got %d", got)
+	}
+}
+
+func TestOverpassClient_RetryBackoffJitter(t *testing.T) {
+	base := 100 * time.Millisecond
+	client := &OverpassClient{Rand: rand.New(rand.NewSource(42))}
+	var first []time.Duration
+	for attempt := 0; attempt < 4; attempt++ {
+		for i := 0; i < 50; i++ {
+			sleep := client.retryBackoff(base, attempt)
+			if sleep < 0 || sleep > base<<attempt {
+				t.Fatalf("attempt %d: sleep %s outside [0, %s]", attempt, sleep, base<<attempt)
+			}
+			first = append(first, sleep)
+		}
+	}
+
+	replay := &OverpassClient{Rand: rand.New(rand.NewSource(42))}
+	for i, want := range first {
+		attempt := i / 50
+		if got := replay.retryBackoff(base, attempt); got != want {
+			t.Fatalf("seeded source not reproducible at %d: got %s, want %s", i, got, want)
+		}
+	}
+
+	distinct := map[time.Duration]bool{}
+	for _, sleep := range first[150:] {
+		distinct[sleep] = true
+	}
+	if len(distinct) < 10 {
+		t.Fatalf("expected jittered sleeps, got %d distinct values", len(distinct))
+	}
+}
+
+func TestOverpassClient_HonorsRetryAfter(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`rate limited`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &OverpassClient{
+		BaseURL:      server.URL,
+		HTTPClient:   server.Client(),
+		MaxAttempts:  2,
+		BackoffBase:  time.Hour,
+		DisableCache: true,
+		wait: func(_ context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+	if _, err := client.NearbyFeatures(context.Background(), 0, 0); err != nil {
+		t.Fatalf("nearby features: %v", err)
+	}
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("expected 2 requests, got %d", hits)
+	}
+	if len(waits) != 1 || waits[0] != 2*time.Second {
+		t.Fatalf("expected a single 2s wait, got %v", waits)
+	}
+}
+
+func TestOverpassClient_ClampsRetryAfter(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &OverpassClient{
+		BaseURL:      server.URL,
+		HTTPClient:   server.Client(),
+		MaxAttempts:  2,
+		DisableCache: true,
+		wait: func(_ context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+	if _, err := client.NearbyFeatures(context.Background(), 0, 0); err != nil {
+		t.Fatalf("nearby features: %v", err)
+	}
+	if len(waits) != 1 || waits[0] != maxRetryAfter {
+		t.Fatalf("expected a single %s wait, got %v", maxRetryAfter, waits)
+	}
+}
+
+func TestOverpassClient_IgnoresRetryAfterWhenSwitchingMirrors(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer healthy.Close()
+
+	var waits []time.Duration
+	client := &OverpassClient{
+		MirrorURLs:   []string{limited.URL, healthy.URL},
+		HTTPClient:   limited.Client(),
+		MaxAttempts:  2,
+		BackoffBase:  10 * time.Millisecond,
+		DisableCache: true,
+		wait: func(_ context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+	if _, err := client.NearbyFeatures(context.Background(), 0, 0); err != nil {
+		t.Fatalf("nearby features: %v", err)
+	}
+	if len(waits) != 1 || waits[0] > 10*time.Millisecond {
+		t.Fatalf("expected a short backoff before trying the other mirror, got %v", waits)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "2", want: 2 * time.Second},
+		{value: " 30 ", want: 30 * time.Second},
+		{value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{value: "0", want: 0},
+		{value: "soon", want: 0},
+		{value: "", want: 0},
+	}
+	for _, tc := range cases {
+		if got := parseRetryAfter(tc.value, now); got != tc.want {
+			t.Fatalf("parseRetryAfter(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestOverpassClient_MirrorCircuitBreaker(t *testing.T) {
+	var badHits, goodHits int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badHits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodHits, 1)
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer good.Close()
+
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	client := &OverpassClient{
+		MirrorURLs:             []string{bad.URL, good.URL},
+		HTTPClient:             bad.Client(),
+		MaxAttempts:            2,
+		DisableCache:           true,
+		MirrorFailureThreshold: 2,
+		MirrorCooldown:         time.Minute,
+		wait:                   func(context.Context, time.Duration) error { return nil },
+		now:                    func() time.Time { return now },
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.NearbyFeatures(context.Background(), float64(i), 0); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(&badHits); got != 2 {
+		t.Fatalf("expected failing mirror to be skipped after 2 failures, got %d hits", got)
+	}
+	if got := atomic.LoadInt32(&goodHits); got != 5 {
+		t.Fatalf("expected 5 hits on healthy mirror, got %d", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := client.NearbyFeatures(context.Background(), 10, 0); err != nil {
+		t.Fatalf("query after cooldown: %v", err)
+	}
+	if got := atomic.LoadInt32(&badHits); got != 3 {
+		t.Fatalf("expected failing mirror back in rotation after cooldown, got %d hits", got)
+	}
+}
+
+func TestOverpassClient_BadQueryDoesNotTripMirror(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`parse error`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(overpassResponse{})
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{
+		MirrorURLs:             []string{server.URL, server.URL + "/other"},
+		HTTPClient:             server.Client(),
+		MaxAttempts:            1,
+		DisableCache:           true,
+		MirrorFailureThreshold: 1,
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.NearbyFeatures(context.Background(), float64(i), 0); err == nil {
+			t.Fatalf("query %d: expected the 400 to surface", i)
+		}
+	}
+	if healthy := client.healthyMirrors(); len(healthy) != 2 {
+		t.Fatalf("expected 400 responses to leave both mirrors healthy, got %v", healthy)
+	}
+}
+
+func TestOverpassClient_DecodesGzipResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("expected gzip in Accept-Encoding, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_ = json.NewEncoder(gz).Encode(overpassResponse{
+			Elements: []overpassElement{{Lat: 40.0, Lon: -73.0, Tags: map[string]string{"highway": "traffic_signals", "name": "Gzip"}}},
+		})
+		_ = gz.Close()
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{BaseURL: server.URL, HTTPClient: server.Client(), DisableCache: true}
+	features, err := client.NearbyFeatures(context.Background(), 40.0, -73.0)
+	if err != nil {
+		t.Fatalf("nearby features: %v", err)
+	}
+	if len(features) != 1 || features[0].Name != "Gzip" {
+		t.Fatalf("unexpected features: %+v", features)
+	}
+}
+
+func TestOverpassClient_FetchRoads(t *testing.T) {
+	var hits int32
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		gotQuery = r.URL.Query().Get("data")
+		_, _ = w.Write([]byte(`{"elements":[
+  {"type":"way","id":101,"tags":{"highway":"primary","name":"Unter den Linden"},
+   "geometry":[{"lat":52.5170,"lon":13.3880},{"lat":52.5168,"lon":13.3920},{"lat":52.5165,"lon":13.3960}]},
+  {"type":"way","id":102,"tags":{"highway":"residential"}},
+  {"type":"way","id":103,"tags":{"highway":"footway"},"geometry":[{"lat":52.5160,"lon":13.3900}]},
+  {"type":"way","id":104,"tags":{"highway":"cycleway"},"geometry":[{"lat":52.5150,"lon":13.3900},{"lat":52.5180,"lon":13.3900}]}
+]}`))
+	}))
+	defer server.Close()
+
+	client := &OverpassClient{BaseURL: server.URL, HTTPClient: server.Client()}
+	bbox := BBox{South: 52.51, West: 13.38, North: 52.52, East: 13.40}
+	roads, err := client.FetchRoads(context.Background(), bbox)
+	if err != nil {
+		t.Fatalf("fetch roads: %v", err)
+	}
+	if !strings.Contains(gotQuery, `way["highway"](`+bbox.String()+`)`) || !strings.Contains(gotQuery, "out geom") {
+		t.Fatalf("unexpected query: %s", gotQuery)
+	}
+	if len(roads) != 2 {
+		t.Fatalf("expected 2 roads with geometry, got %+v", roads)
+	}
+	if roads[0].ID != 101 || roads[0].Name != "Unter den Linden" || roads[0].Highway != "primary" || len(roads[0].Geometry) != 3 {
+		t.Fatalf("unexpected first road: %+v", roads[0])
+	}
+	if roads[0].Geometry[1] != (LatLon{Lat: 52.5168, Lon: 13.3920}) {
+		t.Fatalf("unexpected geometry: %+v", roads[0].Geometry)
+	}
+	if roads[1].ID != 104 || roads[1].Highway != "cycleway" {
+		t.Fatalf("unexpected second road: %+v", roads[1])
+	}
+
+	if _, err := client.FetchRoads(context.Background(), bbox); err != nil {
+		t.Fatalf("fetch roads again: %v", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected cached second fetch, got %d requests", got)
+	}
+}
@@ -1,6 +1,7 @@
 package maps
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"math"
@@ -39,7 +40,7 @@ func LoadRecordingMock(path string) (*RecordingMock, error) {
 }
 
 // NearbyFeatures returns features for the closest recorded stop within a tolerance.
-func (m *RecordingMock) NearbyFeatures(lat, lon float64) ([]Feature, error) {
+func (m *RecordingMock) NearbyFeatures(_ context.Context, lat, lon float64) ([]Feature, error) {
 	const tolMeters = 30.0
 	bestIdx := -1
 	bestDist := tolMeters
@@ -2,67 +2,103 @@ package processor
 
 import (
 	"context"
+	"log"
 	"math"
 	"sort"
 	"strings"
+	"sync"
 
 	"weirdstats/internal/storage"
 )
 
 const (
-	effortVersion       = 1
+	effortVersion       = 2
 	effortHRRefFallback = 120.0
 	effortHRWindow      = 50
+	effortHRHalfLife    = 10.0
 	effortHRFactorMin   = 0.6
 	effortHRFactorMax   = 2.5
 )
 
 var effortSportFactors = map[string]float64{
-	"swim":             2.2,
-	"openwaterswim":    2.2,
-	"poolswim":         2.2,
-	"run":              2.0,
-	"trailrun":         2.0,
-	"virtualrun":       2.0,
-	"treadmill":        2.0,
-	"ride":             1.6,
-	"virtualride":      1.6,
-	"mountainbikeride": 1.6,
-	"gravelride":       1.6,
-	"ebikeride":        1.5,
-	"walk":             1.0,
-	"hike":             1.8,
-	"workout":          1.7,
-	"weighttraining":   1.6,
-	"strengthtraining": 1.6,
-	"crossfit":         1.7,
-	"hiit":             1.8,
-	"rowing":           1.7,
-	"rowergometer":     1.7,
-	"kayaking":         1.5,
-	"canoeing":         1.5,
-	"alpineski":        1.6,
-	"nordicski":        1.6,
-	"backcountryski":   1.7,
-	"snowboard":        1.6,
-	"snowshoe":         1.6,
-	"yoga":             0.7,
-	"pilates":          0.7,
-	"elliptical":       1.5,
-	"stairstepper":     1.7,
-	"stairclimber":     1.7,
+	"swim":              2.2,
+	"openwaterswim":     2.2,
+	"poolswim":          2.2,
+	"run":               2.0,
+	"trailrun":          2.0,
+	"virtualrun":        2.0,
+	"treadmill":         2.0,
+	"ride":              1.6,
+	"virtualride":       1.6,
+	"mountainbikeride":  1.6,
+	"gravelride":        1.6,
+	"ebikeride":         1.5,
+	"emountainbikeride": 1.5,
+	"velomobile":        1.6,
+	"handcycle":         1.6,
+	"wheelchair":        1.6,
+	"walk":              1.0,
+	"hike":              1.8,
+	"workout":           1.7,
+	"weighttraining":    1.6,
+	"strengthtraining":  1.6,
+	"crossfit":          1.7,
+	"hiit":              1.8,
+	"rowing":            1.7,
+	"rowergometer":      1.7,
+	"kayaking":          1.5,
+	"canoeing":          1.5,
+	"alpineski":         1.6,
+	"nordicski":         1.6,
+	"backcountryski":    1.7,
+	"snowboard":         1.6,
+	"snowshoe":          1.6,
+	"yoga":              0.7,
+	"pilates":           0.7,
+	"elliptical":        1.5,
+	"stairstepper":      1.7,
+	"stairclimber":      1.7,
+	"inlineskate":       1.4,
+	"iceskate":          1.4,
+	"rollerski":         1.6,
+	"skateboard":        1.2,
+	"standuppaddling":   1.4,
+	"surfing":           1.5,
+	"windsurf":          1.4,
+	"kitesurf":          1.4,
+	"sail":              1.0,
+	"rockclimbing":      1.7,
+	"golf":              0.9,
+	"soccer":            1.9,
+	"tennis":            1.7,
+	"pickleball":        1.5,
+	"badminton":         1.6,
+	"squash":            1.9,
+	"racquetball":       1.8,
+	"tabletennis":       1.2,
 }
 
-func computeEffort(ctx context.Context, store *storage.Store, activity storage.Activity) (float64, int, error) {
+// EffortOptions tunes the heart-rate reference behind the effort score. The
+// zero value uses the median of the last effortHRWindow activities.
+type EffortOptions struct {
+	// HRWindow is how many recent activities with heart rate feed the
+	// reference. Zero means effortHRWindow.
+	HRWindow int
+	// HRRecencyWeighted replaces the median with a mean whose weights halve
+	// every effortHRHalfLife activities back, so recent rides dominate.
+	HRRecencyWeighted bool
+}
+
+func computeEffort(ctx context.Context, store *storage.Store, activity storage.Activity, opts EffortOptions) (float64, int, error) {
 	durationMinutes := float64(activity.MovingTime) / 60.0
 	if durationMinutes <= 0 {
 		return 0, effortVersion, nil
 	}
 
-	sportFactor := effortSportFactor(activity.Type)
+	sportFactor := effortSportFactor(activity.EffectiveType())
 	hrFactor := 1.0
 	if activity.AverageHeartRate > 0 {
-		hrRef, err := effortHRRef(ctx, store, activity)
+		hrRef, err := effortHRRef(ctx, store, activity, opts)
 		if err != nil {
 			return 0, effortVersion, err
 		}
@@ -76,20 +112,43 @@ func computeEffort(ctx context.Context, store *storage.Store, activity storage.A
 	return durationMinutes * sportFactor * hrFactor, effortVersion, nil
 }
 
-func effortHRRef(ctx context.Context, store *storage.Store, activity storage.Activity) (float64, error) {
-	values, err := store.ListRecentAverageHeartrates(ctx, activity.UserID, activity.StartTime, effortHRWindow)
+func effortHRRef(ctx context.Context, store *storage.Store, activity storage.Activity, opts EffortOptions) (float64, error) {
+	window := opts.HRWindow
+	if window <= 0 {
+		window = effortHRWindow
+	}
+	values, err := store.ListRecentAverageHeartrates(ctx, activity.UserID, activity.StartTime, window)
 	if err != nil {
 		return 0, err
 	}
 	if len(values) == 0 {
 		return effortHRRefFallback, nil
 	}
-	sort.Float64s(values)
-	mid := len(values) / 2
-	if len(values)%2 == 1 {
-		return values[mid], nil
+	if opts.HRRecencyWeighted {
+		return recencyWeightedHR(values), nil
+	}
+	return medianHR(values), nil
+}
+
+func medianHR(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// recencyWeightedHR expects values newest first.
+func recencyWeightedHR(values []float64) float64 {
+	var sum, weights float64
+	for i, v := range values {
+		w := math.Pow(0.5, float64(i)/effortHRHalfLife)
+		sum += v * w
+		weights += w
 	}
-	return (values[mid-1] + values[mid]) / 2, nil
+	return sum / weights
 }
 
 func effortSportFactor(activityType string) float64 {
@@ -97,9 +156,26 @@ func effortSportFactor(activityType string) float64 {
 	if factor, ok := effortSportFactors[key]; ok {
 		return factor
 	}
+	if key != "" {
+		logUnknownSportType(key)
+	}
 	return 1.0
 }
 
+// loggedUnknownSportTypes holds the unknown types already reported, so each
+// one is logged once per process rather than for every activity.
+var loggedUnknownSportTypes sync.Map
+
+// logUnknownSportType reports whether it logged key, i.e. whether this is the
+// first time the type has been seen.
+func logUnknownSportType(key string) bool {
+	if _, seen := loggedUnknownSportTypes.LoadOrStore(key, struct{}{}); seen {
+		return false
+	}
+	log.Printf("effort: no sport factor for %q, using 1.0", key)
+	return true
+}
+
 func normalizeActivityType(value string) string {
 	if value == "" {
 		return ""
@@ -45,7 +45,7 @@ func TestComputeEffortWithHeartRateReference(t *testing.T) {
 		MovingTime:       3600,
 		AverageHeartRate: 140,
 	}
-	score, version, err := computeEffort(ctx, store, target)
+	score, version, err := computeEffort(ctx, store, target, EffortOptions{})
 	if err != nil {
 		t.Fatalf("compute effort: %v", err)
 	}
@@ -56,3 +56,94 @@ func TestComputeEffortWithHeartRateReference(t *testing.T) {
 		t.Fatalf("expected effort 120, got %.4f", score)
 	}
 }
+
+func TestEffortSportFactor(t *testing.T) {
+	cases := map[string]float64{
+		"Velomobile":         1.6,
+		"Wheelchair":         1.6,
+		"InlineSkate":        1.4,
+		"RockClimbing":       1.7,
+		"Soccer":             1.9,
+		"mountain_bike_ride": 1.6,
+		"Quidditch":          1.0,
+	}
+	for activityType, want := range cases {
+		if got := effortSportFactor(activityType); got != want {
+			t.Fatalf("%s: expected factor %.1f, got %.1f", activityType, want, got)
+		}
+	}
+}
+
+func TestLogUnknownSportTypeDedupes(t *testing.T) {
+	if !logUnknownSportType("testonlysport") {
+		t.Fatalf("expected the first unknown type to be logged")
+	}
+	if logUnknownSportType("testonlysport") {
+		t.Fatalf("expected a repeated unknown type not to be logged again")
+	}
+	if !logUnknownSportType("anothertestonlysport") {
+		t.Fatalf("expected a different unknown type to be logged")
+	}
+}
+
+func TestEffortHRReferenceMedianVersusDecay(t *testing.T) {
+	// Newest first: two recent hard efforts after a run of easy ones.
+	series := []float64{160, 150, 100, 100, 100}
+	if got := medianHR(series); got != 100 {
+		t.Fatalf("expected median 100, got %.2f", got)
+	}
+	var sum, weights float64
+	for i, v := range series {
+		w := math.Pow(0.5, float64(i)/effortHRHalfLife)
+		sum += v * w
+		weights += w
+	}
+	want := sum / weights
+	got := recencyWeightedHR(series)
+	if math.Abs(got-want) > 1e-9 {
+		t.Fatalf("expected decayed reference %.4f, got %.4f", want, got)
+	}
+	if mean := 122.0; got <= mean {
+		t.Fatalf("expected decay to favor recent efforts over the plain mean %.1f, got %.2f", mean, got)
+	}
+	if series[0] != 160 || series[4] != 100 {
+		t.Fatalf("expected median not to reorder the input, got %v", series)
+	}
+
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	for i := range series {
+		// Insert oldest first so start times run in the series' reverse order.
+		hr := series[len(series)-1-i]
+		if _, err := store.InsertActivity(ctx, storage.Activity{UserID: 1, Type: "Run", Name: "Prior", StartTime: base.Add(time.Duration(i) * time.Hour), MovingTime: 1800, AverageHeartRate: hr}, nil); err != nil {
+			t.Fatalf("insert prior activity: %v", err)
+		}
+	}
+	target := storage.Activity{UserID: 1, Type: "Run", StartTime: base.Add(10 * time.Hour)}
+	cases := []struct {
+		name string
+		opts EffortOptions
+		want float64
+	}{
+		{name: "median", want: 100},
+		{name: "decay", opts: EffortOptions{HRRecencyWeighted: true}, want: want},
+		{name: "window of one", opts: EffortOptions{HRWindow: 1}, want: 160},
+	}
+	for _, tc := range cases {
+		ref, err := effortHRRef(ctx, store, target, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if math.Abs(ref-tc.want) > 1e-9 {
+			t.Fatalf("%s: expected reference %.4f, got %.4f", tc.name, tc.want, ref)
+		}
+	}
+}
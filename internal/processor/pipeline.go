@@ -2,6 +2,7 @@ package processor
 
 import (
 	"context"
+	"errors"
 
 	"weirdstats/internal/ingest"
 )
@@ -19,7 +20,8 @@ type ActivityApplier interface {
 
 func (p *PipelineProcessor) Process(ctx context.Context, activityID int64) error {
 	if p.Ingest != nil {
-		if err := p.Ingest.EnsureActivity(ctx, activityID); err != nil {
+		// GPS-less activities are still stored; stop detection just yields zero stats.
+		if err := p.Ingest.EnsureActivity(ctx, activityID); err != nil && !errors.Is(err, ingest.ErrNoGPSData) {
 			return err
 		}
 	}
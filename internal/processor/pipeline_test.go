@@ -2,7 +2,17 @@ package processor
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/ingest"
+	"weirdstats/internal/maps"
+	"weirdstats/internal/maps/mapstest"
+	"weirdstats/internal/storage"
+	"weirdstats/internal/strava"
 )
 
 type stubPipelineApplier struct {
@@ -33,3 +43,51 @@ func TestPipelineProcessorUsesApplierWhenConfigured(t *testing.T) {
 		t.Fatalf("expected activity id 42, got %d", applier.lastActivityID)
 	}
 }
+
+func TestPipelineProcessorHandlesActivityWithoutGPS(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/activities/77":
+			_, _ = w.Write([]byte(`{"id":77,"name":"Treadmill","type":"Run","start_date":"2024-01-01T10:00:00Z"}`))
+		case "/activities/77/streams":
+			_, _ = w.Write([]byte(`{"time":{"data":[0,60]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	mapStub := &mapstest.StubAPI{Features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
+	pipeline := &PipelineProcessor{
+		Ingest: &ingest.Ingestor{Store: store, Strava: &strava.Client{BaseURL: server.URL, AccessToken: "token"}},
+		Stats: &StopStatsProcessor{
+			Store:   store,
+			MapAPI:  mapStub,
+			Options: gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second},
+		},
+	}
+
+	if err := pipeline.Process(ingest.ContextWithUserID(ctx, 1), 77); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	got, err := store.GetActivityStats(ctx, 77)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.StopCount != 0 || got.StopTotalSeconds != 0 || got.TrafficLightStopCount != 0 {
+		t.Fatalf("expected zero stats, got %+v", got)
+	}
+	if mapStub.Calls() != 0 {
+		t.Fatalf("expected no map lookups, got %d", mapStub.Calls())
+	}
+}
@@ -43,28 +43,9 @@ func (p *RulesProcessor) Process(ctx context.Context, activityID int64) error {
 	if reg == nil {
 		reg = rules.DefaultRegistry()
 	}
-	startUnix := int64(0)
-	if !activity.StartTime.IsZero() {
-		startUnix = activity.StartTime.Unix()
-	}
-	ctxData := rules.Context{
-		Activity: rules.ActivitySource{
-			ID:          activity.ID,
-			Type:        activity.Type,
-			Name:        activity.Name,
-			StartUnix:   startUnix,
-			DistanceM:   activity.Distance,
-			MovingTimeS: activity.MovingTime,
-		},
-		Stats: rules.StatsSource{
-			StopCount:             stats.StopCount,
-			StopTotalSeconds:      stats.StopTotalSeconds,
-			TrafficLightStopCount: stats.TrafficLightStopCount,
-			RoadCrossingCount:     stats.RoadCrossingCount,
-		},
-	}
+	ctxData := rules.BuildRuleContext(activity, stats)
 
-	hide := false
+	var hideRuleID int64
 	for _, ruleRow := range ruleRows {
 		if !ruleRow.Enabled {
 			continue
@@ -81,16 +62,16 @@ func (p *RulesProcessor) Process(ctx context.Context, activityID int64) error {
 			continue
 		}
 		if matched && shouldHide {
-			hide = true
+			hideRuleID = ruleRow.ID
 			break
 		}
 	}
 
-	if err := p.Store.UpdateActivityHiddenByRule(ctx, activityID, hide); err != nil {
+	if err := p.Store.SetActivityHiddenByRule(ctx, activityID, hideRuleID); err != nil {
 		return err
 	}
 
-	if !hide || activity.HideFromHome {
+	if hideRuleID == 0 || activity.HideFromHome {
 		return nil
 	}
 
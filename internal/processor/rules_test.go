@@ -247,3 +247,44 @@ func TestRulesProcessorHidesSlowRunByPace(t *testing.T) {
 		t.Fatalf("expected hide_from_home to be true locally")
 	}
 }
+
+func TestRulesProcessorRecordsAndClearsHidingRule(t *testing.T) {
+	ctx := context.Background()
+	store := openRulesStore(t)
+	ruleID, err := store.CreateHideRule(ctx, storage.HideRule{
+		UserID:    1,
+		Name:      "Short commutes",
+		Enabled:   true,
+		Condition: `{"match":"all","conditions":[{"metric":"activity_type","op":"eq","values":["Ride"]}],"action":{"type":"hide"}}`,
+	})
+	if err != nil {
+		t.Fatalf("create hide rule: %v", err)
+	}
+	activityID := insertActivityForRulesTest(t, store, "Ride", true)
+	processor := &RulesProcessor{Store: store, Strava: &stubActivityUpdater{}}
+
+	if err := processor.Process(ctx, activityID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	activity, err := store.GetActivity(ctx, activityID)
+	if err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	if !activity.HiddenByRule || activity.HiddenByRuleID != ruleID {
+		t.Fatalf("expected activity hidden by rule %d, got hidden=%t rule=%d", ruleID, activity.HiddenByRule, activity.HiddenByRuleID)
+	}
+
+	if err := store.UpdateHideRuleEnabled(ctx, ruleID, false); err != nil {
+		t.Fatalf("disable rule: %v", err)
+	}
+	if err := processor.Process(ctx, activityID); err != nil {
+		t.Fatalf("reprocess: %v", err)
+	}
+	activity, err = store.GetActivity(ctx, activityID)
+	if err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	if activity.HiddenByRule || activity.HiddenByRuleID != 0 {
+		t.Fatalf("expected hiding rule cleared, got hidden=%t rule=%d", activity.HiddenByRule, activity.HiddenByRuleID)
+	}
+}
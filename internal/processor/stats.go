@@ -2,6 +2,11 @@ package processor
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
 	"time"
 
 	"weirdstats/internal/gps"
@@ -10,12 +15,45 @@ import (
 	"weirdstats/internal/storage"
 )
 
+// StatsVersion is stored with every stats row. Bump it when stop detection or
+// any other stats computation changes; activities with older rows are
+// re-enqueued at startup.
+const StatsVersion = 1
+
 type StopStatsProcessor struct {
-	Store    *storage.Store
+	Store *storage.Store
+	// MapAPI and Overpass may be nil; stops are still counted but
+	// TrafficLightStopCount and RoadCrossingCount stay 0.
 	MapAPI   maps.API
 	Overpass *maps.OverpassClient
 	Options  gps.StopOptions
 	Facts    ActivityFactPrecomputer
+	// PrefetchSignals fetches traffic signals for the whole activity bbox once
+	// and matches stops in memory instead of querying per stop. It only
+	// applies when MapAPI implements maps.SignalPrefetcher.
+	PrefetchSignals bool
+	// MaxLookupsPerActivity caps map requests per activity; stops past the
+	// budget are counted but left unclassified. Zero means unlimited.
+	MaxLookupsPerActivity int
+	// MatchRadiusMeters is how close a returned signal must be to the stop to
+	// count it as a traffic-light stop. Lookups still use the wider map search
+	// radius. Zero accepts every returned signal, as do signals without
+	// coordinates.
+	MatchRadiusMeters float64
+	// ClimbNoiseThresholdM is passed to gps.ClimbMeters.
+	ClimbNoiseThresholdM float64
+	Effort               EffortOptions
+}
+
+// OptionsHash identifies every setting that changes the stored stop stats:
+// the stop options, the traffic-light match radius and the climb noise
+// threshold. Stats stored with a different hash are stale.
+func (p *StopStatsProcessor) OptionsHash() string {
+	if p.MatchRadiusMeters <= 0 && p.ClimbNoiseThresholdM <= 0 {
+		return p.Options.Hash()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s;match=%g;climb=%g", p.Options.Hash(), p.MatchRadiusMeters, p.ClimbNoiseThresholdM)))
+	return hex.EncodeToString(sum[:8])
 }
 
 type ActivityFactPrecomputer interface {
@@ -34,8 +72,14 @@ func (p *StopStatsProcessor) Process(ctx context.Context, activityID int64) erro
 
 	stops := gps.DetectStops(points, p.Options)
 	updatedAt := time.Now()
-	stats := stats.StopStats{StopCount: len(stops), UpdatedAt: updatedAt}
-	effortScore, effortVersion, err := computeEffort(ctx, p.Store, activity)
+	stats := stats.StopStats{
+		StopCount:    len(stops),
+		ClimbMeters:  gps.ClimbMeters(points, p.ClimbNoiseThresholdM),
+		OptionsHash:  p.OptionsHash(),
+		StatsVersion: StatsVersion,
+		UpdatedAt:    updatedAt,
+	}
+	effortScore, effortVersion, err := computeEffort(ctx, p.Store, activity, p.Effort)
 	if err != nil {
 		return err
 	}
@@ -45,31 +89,66 @@ func (p *StopStatsProcessor) Process(ctx context.Context, activityID int64) erro
 	if len(points) > 0 {
 		activityStartTime = points[0].Time
 	}
+	var signals []maps.POI
+	prefetcher, _ := p.MapAPI.(maps.SignalPrefetcher)
+	prefetch := p.PrefetchSignals && prefetcher != nil && len(stops) > 0
+	if prefetch {
+		signals, err = prefetcher.FetchSignalsInBBox(ctx, pointsBBox(points))
+		if err != nil {
+			return err
+		}
+	}
+	lookups := 0
+	budgetLogged := false
+	withinBudget := func() bool {
+		if p.MaxLookupsPerActivity <= 0 || lookups < p.MaxLookupsPerActivity {
+			return true
+		}
+		if !budgetLogged {
+			log.Printf("activity %d: map lookup budget of %d reached with %d stops", activityID, p.MaxLookupsPerActivity, len(stops))
+			budgetLogged = true
+		}
+		return false
+	}
 	var stopRows []storage.ActivityStop
 	for i, stop := range stops {
 		hasLight := false
 		hasCrossing := false
 		crossingRoad := ""
+		lightUnknown := false
 
 		stats.StopTotalSeconds += int(stop.Duration.Seconds())
-		if p.MapAPI != nil {
-			features, err := p.MapAPI.NearbyFeatures(stop.Lat, stop.Lon)
+		if seconds := int(stop.Duration.Seconds()); seconds > stats.LongestStopSeconds {
+			stats.LongestStopSeconds = seconds
+		}
+		if prefetch {
+			if p.hasMatchingSignal(prefetcher.SignalsNear(signals, stop.Lat, stop.Lon), stop.Lat, stop.Lon) {
+				stats.TrafficLightStopCount++
+				hasLight = true
+			}
+		} else if p.MapAPI != nil && !withinBudget() {
+			lightUnknown = true
+		} else if p.MapAPI != nil {
+			lookups++
+			features, err := p.MapAPI.NearbyFeatures(ctx, stop.Lat, stop.Lon)
 			if err != nil {
 				return err
 			}
-			for _, feature := range features {
-				if feature.Type == maps.FeatureTrafficLight {
-					stats.TrafficLightStopCount++
-					hasLight = true
-					break
-				}
+			if p.hasMatchingSignal(features, stop.Lat, stop.Lon) {
+				stats.TrafficLightStopCount++
+				hasLight = true
 			}
 		}
 
-		if !hasLight && p.Overpass != nil {
-			stopStartSeconds := stop.StartTime.Sub(activityStartTime).Seconds()
-			stopEndIdx := gps.FindStopEndIndex(points, stopStartSeconds, p.Options.SpeedThreshold, 0)
-			if stopEndIdx >= 0 {
+		stopStartSeconds := stop.StartTime.Sub(activityStartTime).Seconds()
+		stopEndIdx := gps.FindStopEndIndex(points, stopStartSeconds, p.Options.SpeedThreshold, 0)
+		if _, turned := gps.DetectTurnAfterStop(points, stopEndIdx); turned {
+			stats.TurnAfterStopCount++
+		}
+
+		if !hasLight && !lightUnknown && p.Overpass != nil {
+			if stopEndIdx >= 0 && withinBudget() {
+				lookups++
 				roads, err := p.Overpass.FetchNearbyRoads(ctx, stop.Lat, stop.Lon, 30)
 				if err != nil {
 					return err
@@ -86,14 +165,15 @@ func (p *StopStatsProcessor) Process(ctx context.Context, activityID int64) erro
 		}
 
 		stopRows = append(stopRows, storage.ActivityStop{
-			Seq:             i,
-			Lat:             stop.Lat,
-			Lon:             stop.Lon,
-			StartSeconds:    stop.StartTime.Sub(activityStartTime).Seconds(),
-			DurationSeconds: int(stop.Duration.Seconds()),
-			HasTrafficLight: hasLight,
-			HasRoadCrossing: hasCrossing,
-			CrossingRoad:    crossingRoad,
+			Seq:                 i,
+			Lat:                 stop.Lat,
+			Lon:                 stop.Lon,
+			StartSeconds:        stop.StartTime.Sub(activityStartTime).Seconds(),
+			DurationSeconds:     int(stop.Duration.Seconds()),
+			HasTrafficLight:     hasLight,
+			HasRoadCrossing:     hasCrossing,
+			CrossingRoad:        crossingRoad,
+			TrafficLightUnknown: lightUnknown,
 		})
 	}
 
@@ -110,3 +190,26 @@ func (p *StopStatsProcessor) Process(ctx context.Context, activityID int64) erro
 	}
 	return nil
 }
+
+func pointsBBox(points []gps.Point) maps.BBox {
+	bbox := maps.BBox{South: points[0].Lat, West: points[0].Lon, North: points[0].Lat, East: points[0].Lon}
+	for _, pt := range points[1:] {
+		bbox.South = math.Min(bbox.South, pt.Lat)
+		bbox.North = math.Max(bbox.North, pt.Lat)
+		bbox.West = math.Min(bbox.West, pt.Lon)
+		bbox.East = math.Max(bbox.East, pt.Lon)
+	}
+	return bbox
+}
+
+func (p *StopStatsProcessor) hasMatchingSignal(features []maps.Feature, lat, lon float64) bool {
+	for _, feature := range features {
+		if feature.Type != maps.FeatureTrafficLight {
+			continue
+		}
+		if p.MatchRadiusMeters <= 0 || !feature.HasLocation() || feature.DistanceMeters(lat, lon) <= p.MatchRadiusMeters {
+			return true
+		}
+	}
+	return false
+}
@@ -3,30 +3,27 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"weirdstats/internal/gps"
 	"weirdstats/internal/maps"
+	"weirdstats/internal/maps/mapstest"
+	"weirdstats/internal/stats"
 	"weirdstats/internal/storage"
 	"weirdstats/internal/web"
 )
 
-type stubMapAPI struct {
-	features []maps.Feature
-	calls    int
-}
-
-func (s *stubMapAPI) NearbyFeatures(lat, lon float64) ([]maps.Feature, error) {
-	s.calls++
-	return s.features, nil
-}
-
 func TestStopStatsProcessor_ComputesStopsAndTrafficLights(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
 	store, err := storage.Open(dbPath)
@@ -62,7 +59,7 @@ func TestStopStatsProcessor_ComputesStopsAndTrafficLights(t *testing.T) {
 		t.Fatalf("insert activity: %v", err)
 	}
 
-	mapStub := &stubMapAPI{features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
+	mapStub := &mapstest.StubAPI{Features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
 	processor := &StopStatsProcessor{
 		Store:   store,
 		MapAPI:  mapStub,
@@ -90,8 +87,8 @@ func TestStopStatsProcessor_ComputesStopsAndTrafficLights(t *testing.T) {
 	if stats.RoadCrossingCount != 0 {
 		t.Fatalf("expected 0 road crossings, got %d", stats.RoadCrossingCount)
 	}
-	if mapStub.calls != 1 {
-		t.Fatalf("expected 1 map lookup, got %d", mapStub.calls)
+	if mapStub.Calls() != 1 {
+		t.Fatalf("expected 1 map lookup, got %d", mapStub.Calls())
 	}
 }
 
@@ -351,6 +348,185 @@ func loadActivityFixture(t *testing.T, path string) (storage.Activity, []gps.Poi
 	return activity, points
 }
 
+func TestStopStatsProcessor_LookupBudget(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "budget.db")
+	store, err := storage.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	var points []gps.Point
+	ts := start
+	for i := 0; i < 10; i++ {
+		lat := 40.0 + float64(i)*0.01
+		points = append(points,
+			gps.Point{Lat: lat, Lon: -73.0, Time: ts, Speed: 5},
+			gps.Point{Lat: lat, Lon: -73.0, Time: ts.Add(10 * time.Second), Speed: 0},
+			gps.Point{Lat: lat, Lon: -73.0, Time: ts.Add(50 * time.Second), Speed: 0},
+			gps.Point{Lat: lat + 0.001, Lon: -73.0, Time: ts.Add(60 * time.Second), Speed: 5},
+		)
+		ts = ts.Add(70 * time.Second)
+	}
+	activityID, err := store.InsertActivity(context.Background(), storage.Activity{
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Micro stops",
+		StartTime: start,
+	}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	mapStub := &mapstest.StubAPI{Features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
+	processor := &StopStatsProcessor{
+		Store:                 store,
+		MapAPI:                mapStub,
+		Options:               gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second},
+		MaxLookupsPerActivity: 3,
+	}
+	if err := processor.Process(context.Background(), activityID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	if mapStub.Calls() != 3 {
+		t.Fatalf("expected 3 map calls, got %d", mapStub.Calls())
+	}
+	got, err := store.GetActivityStats(context.Background(), activityID)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.StopCount != 10 {
+		t.Fatalf("expected 10 stops counted, got %d", got.StopCount)
+	}
+	if got.TrafficLightStopCount != 3 {
+		t.Fatalf("expected 3 classified traffic light stops, got %d", got.TrafficLightStopCount)
+	}
+	stops, err := store.LoadActivityStops(context.Background(), activityID)
+	if err != nil {
+		t.Fatalf("load stops: %v", err)
+	}
+	unknown := 0
+	for _, stop := range stops {
+		if stop.TrafficLightUnknown {
+			unknown++
+		}
+	}
+	if unknown != 7 {
+		t.Fatalf("expected 7 stops marked unknown, got %d", unknown)
+	}
+}
+
+func TestStopStatsProcessor_MatchRadiusFiltersDistantSignals(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.April, 2, 8, 0, 0, 0, time.UTC)
+	points := []gps.Point{
+		{Lat: 40.0, Lon: -73.0, Time: start, Speed: 5},
+		{Lat: 40.001, Lon: -73.0, Time: start.Add(10 * time.Second), Speed: 0},
+		{Lat: 40.001, Lon: -73.0, Time: start.Add(50 * time.Second), Speed: 0},
+		{Lat: 40.002, Lon: -73.0, Time: start.Add(60 * time.Second), Speed: 5},
+		{Lat: 40.01, Lon: -73.0, Time: start.Add(200 * time.Second), Speed: 0},
+		{Lat: 40.01, Lon: -73.0, Time: start.Add(240 * time.Second), Speed: 0},
+		{Lat: 40.011, Lon: -73.0, Time: start.Add(250 * time.Second), Speed: 5},
+	}
+	activityID, err := store.InsertActivity(context.Background(), storage.Activity{
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Two signals",
+		StartTime: start,
+	}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	// The first stop has a signal ~10m away, the second one ~35m away; both
+	// are inside a 40m search radius.
+	mapStub := &mapstest.StubAPI{Lookup: func(lat, lon float64) []maps.Feature {
+		offset := 0.00009
+		if lat > 40.005 {
+			offset = 0.000315
+		}
+		return []maps.Feature{{Type: maps.FeatureTrafficLight, Lat: lat + offset, Lon: lon}}
+	}}
+
+	cases := []struct {
+		radius float64
+		want   int
+	}{
+		{radius: 0, want: 2},
+		{radius: 20, want: 1},
+		{radius: 5, want: 0},
+	}
+	hashes := map[string]bool{}
+	for _, tc := range cases {
+		processor := &StopStatsProcessor{
+			Store:             store,
+			MapAPI:            mapStub,
+			Options:           gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second},
+			MatchRadiusMeters: tc.radius,
+		}
+		if err := processor.Process(context.Background(), activityID); err != nil {
+			t.Fatalf("radius %v: process: %v", tc.radius, err)
+		}
+		got, err := store.GetActivityStats(context.Background(), activityID)
+		if err != nil {
+			t.Fatalf("radius %v: get stats: %v", tc.radius, err)
+		}
+		if got.StopCount != 2 {
+			t.Fatalf("radius %v: expected 2 stops, got %d", tc.radius, got.StopCount)
+		}
+		if got.TrafficLightStopCount != tc.want {
+			t.Fatalf("radius %v: expected %d traffic light stops, got %d", tc.radius, tc.want, got.TrafficLightStopCount)
+		}
+		if got.OptionsHash != processor.OptionsHash() {
+			t.Fatalf("radius %v: expected options hash %q, got %q", tc.radius, processor.OptionsHash(), got.OptionsHash)
+		}
+		hashes[got.OptionsHash] = true
+	}
+	if len(hashes) != len(cases) {
+		t.Fatalf("expected a distinct options hash per match radius, got %v", hashes)
+	}
+}
+
+func TestStopStatsProcessor_OptionsHashCoversStoredSettings(t *testing.T) {
+	base := StopStatsProcessor{Options: gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second}}
+	if base.OptionsHash() != base.Options.Hash() {
+		t.Fatalf("expected default settings to keep the stop options hash")
+	}
+
+	variants := map[string]StopStatsProcessor{"base": base}
+	radius := base
+	radius.MatchRadiusMeters = 20
+	variants["match radius"] = radius
+	climb := base
+	climb.ClimbNoiseThresholdM = 3
+	variants["climb threshold"] = climb
+	both := radius
+	both.ClimbNoiseThresholdM = 3
+	variants["both"] = both
+
+	seen := map[string]string{}
+	for name, processor := range variants {
+		hash := processor.OptionsHash()
+		if other, ok := seen[hash]; ok {
+			t.Fatalf("%s and %s share options hash %q", name, other, hash)
+		}
+		seen[hash] = name
+	}
+}
+
 func TestStopStatsProcessor_WithSampleActivityFixture(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "fixture.db")
 	store, err := storage.Open(dbPath)
@@ -368,7 +544,7 @@ func TestStopStatsProcessor_WithSampleActivityFixture(t *testing.T) {
 		t.Fatalf("insert activity: %v", err)
 	}
 
-	mapStub := &stubMapAPI{features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
+	mapStub := &mapstest.StubAPI{Features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
 	processor := &StopStatsProcessor{
 		Store:   store,
 		MapAPI:  mapStub,
@@ -396,8 +572,8 @@ func TestStopStatsProcessor_WithSampleActivityFixture(t *testing.T) {
 	if got.RoadCrossingCount != 0 {
 		t.Fatalf("expected 0 road crossings without overpass, got %d", got.RoadCrossingCount)
 	}
-	if mapStub.calls != 5 {
-		t.Fatalf("expected 5 map lookups, got %d", mapStub.calls)
+	if mapStub.Calls() != 5 {
+		t.Fatalf("expected 5 map lookups, got %d", mapStub.Calls())
 	}
 }
 
@@ -450,6 +626,165 @@ func TestStopStatsProcessor_WithRecordedOverpassMock(t *testing.T) {
 	}
 }
 
+func TestStopStatsProcessor_PrefetchSignalsMatchesPerStopLookups(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "fixture.db")
+	store, err := storage.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	activity, points := loadActivityFixture(t, filepath.Join(repoRoot(t), "testdata", "activities", "ride_sample.json"))
+	activityID, err := store.InsertActivity(context.Background(), activity, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	signals := [][2]float64{
+		{48.161380, 11.511380},
+		{48.150000, 11.500000},
+	}
+	aroundPattern := regexp.MustCompile(`node\(around:(\d+),([-\d.]+),([-\d.]+)\)`)
+	var aroundCalls, bboxCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("data")
+		var elements []map[string]any
+		switch {
+		case strings.Contains(query, "way(around:"):
+		case aroundPattern.MatchString(query):
+			atomic.AddInt32(&aroundCalls, 1)
+			m := aroundPattern.FindStringSubmatch(query)
+			radius, _ := strconv.ParseFloat(m[1], 64)
+			lat, _ := strconv.ParseFloat(m[2], 64)
+			lon, _ := strconv.ParseFloat(m[3], 64)
+			for _, sig := range signals {
+				dLat := (sig[0] - lat) * 111320
+				dLon := (sig[1] - lon) * 111320 * math.Cos(lat*math.Pi/180)
+				if math.Hypot(dLat, dLon) <= radius {
+					elements = append(elements, map[string]any{"type": "node", "lat": sig[0], "lon": sig[1], "tags": map[string]string{"highway": "traffic_signals"}})
+				}
+			}
+		default:
+			atomic.AddInt32(&bboxCalls, 1)
+			for _, sig := range signals {
+				elements = append(elements, map[string]any{"type": "node", "lat": sig[0], "lon": sig[1], "tags": map[string]string{"highway": "traffic_signals"}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"elements": elements})
+	}))
+	defer server.Close()
+
+	client := &maps.OverpassClient{
+		BaseURL:      server.URL,
+		HTTPClient:   server.Client(),
+		DisableCache: true,
+	}
+	opts := gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second}
+
+	run := func(prefetch bool) (stats.StopStats, []storage.ActivityStop) {
+		t.Helper()
+		processor := &StopStatsProcessor{
+			Store:           store,
+			MapAPI:          client,
+			Overpass:        client,
+			Options:         opts,
+			PrefetchSignals: prefetch,
+		}
+		if err := processor.Process(context.Background(), activityID); err != nil {
+			t.Fatalf("process (prefetch=%t): %v", prefetch, err)
+		}
+		got, err := store.GetActivityStats(context.Background(), activityID)
+		if err != nil {
+			t.Fatalf("get stats: %v", err)
+		}
+		stops, err := store.LoadActivityStops(context.Background(), activityID)
+		if err != nil {
+			t.Fatalf("load stops: %v", err)
+		}
+		return got, stops
+	}
+
+	perStopStats, perStopStops := run(false)
+	if got := atomic.LoadInt32(&aroundCalls); got != 5 {
+		t.Fatalf("expected 5 per-stop lookups, got %d", got)
+	}
+	bboxStats, bboxStops := run(true)
+	if got := atomic.LoadInt32(&bboxCalls); got != 1 {
+		t.Fatalf("expected a single bbox lookup, got %d", got)
+	}
+	if got := atomic.LoadInt32(&aroundCalls); got != 5 {
+		t.Fatalf("expected no per-stop signal lookups in bbox mode, got %d", got-5)
+	}
+
+	if perStopStats.TrafficLightStopCount != 2 {
+		t.Fatalf("expected 2 traffic light stops, got %d", perStopStats.TrafficLightStopCount)
+	}
+	if bboxStats.TrafficLightStopCount != perStopStats.TrafficLightStopCount {
+		t.Fatalf("bbox mode found %d traffic light stops, per-stop found %d", bboxStats.TrafficLightStopCount, perStopStats.TrafficLightStopCount)
+	}
+	if len(bboxStops) != len(perStopStops) {
+		t.Fatalf("expected %d stops, got %d", len(perStopStops), len(bboxStops))
+	}
+	for i := range perStopStops {
+		if bboxStops[i].HasTrafficLight != perStopStops[i].HasTrafficLight {
+			t.Fatalf("stop %d: bbox mode has_traffic_light=%t, per-stop=%t", i, bboxStops[i].HasTrafficLight, perStopStops[i].HasTrafficLight)
+		}
+	}
+}
+
+func TestStopStatsProcessor_PrefetchSignalsUsesMapAPI(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	activity, points := loadActivityFixture(t, filepath.Join(repoRoot(t), "testdata", "activities", "ride_sample.json"))
+	activityID, err := store.InsertActivity(context.Background(), activity, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	var signalQueries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Query().Get("data"), "traffic_signals") {
+			atomic.AddInt32(&signalQueries, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"elements": []any{}})
+	}))
+	defer server.Close()
+
+	// A signals file (or any other non-prefetching API) stays authoritative
+	// for traffic lights even with prefetch on; Overpass only serves roads.
+	mapStub := &mapstest.StubAPI{Features: []maps.Feature{{Type: maps.FeatureTrafficLight}}}
+	processor := &StopStatsProcessor{
+		Store:           store,
+		MapAPI:          mapStub,
+		Overpass:        &maps.OverpassClient{BaseURL: server.URL, HTTPClient: server.Client(), DisableCache: true},
+		Options:         gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 30 * time.Second},
+		PrefetchSignals: true,
+	}
+	if err := processor.Process(context.Background(), activityID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if got := atomic.LoadInt32(&signalQueries); got != 0 {
+		t.Fatalf("expected no Overpass signal queries, got %d", got)
+	}
+	got, err := store.GetActivityStats(context.Background(), activityID)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if mapStub.Calls() != got.StopCount || got.TrafficLightStopCount != got.StopCount {
+		t.Fatalf("expected every stop classified by the map API, got %d calls for %+v", mapStub.Calls(), got)
+	}
+}
+
 func repoRoot(t *testing.T) string {
 	t.Helper()
 	_, currentFile, _, ok := runtime.Caller(0)
@@ -519,7 +854,7 @@ func TestRecordOverpassForFixture(t *testing.T) {
 	rec.MinDurationSeconds = int(opts.MinDuration.Seconds())
 
 	for _, stop := range stops {
-		features, err := client.NearbyFeatures(stop.Lat, stop.Lon)
+		features, err := client.NearbyFeatures(context.Background(), stop.Lat, stop.Lon)
 		if err != nil {
 			t.Fatalf("overpass query failed: %v", err)
 		}
@@ -544,3 +879,137 @@ func TestRecordOverpassForFixture(t *testing.T) {
 	}
 	t.Logf("recorded %d stops to %s", len(rec.Stops), outputPath)
 }
+
+func TestStopStatsProcessor_ComputesLongestStop(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var points []gps.Point
+	offset := 0
+	lat := 40.0
+	move := func(seconds int) {
+		for i := 0; i < seconds; i += 10 {
+			lat += 0.0001
+			points = append(points, gps.Point{Lat: lat, Lon: -73.0, Time: now.Add(time.Duration(offset) * time.Second), Speed: 3.0})
+			offset += 10
+		}
+	}
+	stop := func(seconds int) {
+		points = append(points,
+			gps.Point{Lat: lat, Lon: -73.0, Time: now.Add(time.Duration(offset) * time.Second), Speed: 0},
+			gps.Point{Lat: lat, Lon: -73.0, Time: now.Add(time.Duration(offset+seconds) * time.Second), Speed: 0},
+		)
+		offset += seconds
+	}
+	move(30)
+	stop(20)
+	move(30)
+	stop(120)
+	move(30)
+	stop(60)
+	move(30)
+
+	activityID, err := store.InsertActivity(context.Background(), storage.Activity{
+		UserID:     1,
+		Type:       "Ride",
+		Name:       "Longest Stop",
+		StartTime:  now,
+		Distance:   2000,
+		MovingTime: offset,
+	}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	processor := &StopStatsProcessor{
+		Store:   store,
+		Options: gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 10 * time.Second},
+	}
+	if err := processor.Process(context.Background(), activityID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	got, err := store.GetActivityStats(context.Background(), activityID)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.StopCount != 3 {
+		t.Fatalf("expected 3 stops, got %d", got.StopCount)
+	}
+	if got.StopTotalSeconds != 200 {
+		t.Fatalf("expected 200s stop total, got %d", got.StopTotalSeconds)
+	}
+	if got.LongestStopSeconds != 120 {
+		t.Fatalf("expected longest stop of 120s, got %d", got.LongestStopSeconds)
+	}
+}
+
+func TestStopStatsProcessor_CountsTurnsAfterStops(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var points []gps.Point
+	add := func(lat, lon, speed float64) {
+		points = append(points, gps.Point{Lat: lat, Lon: lon, Time: now.Add(time.Duration(len(points)) * 10 * time.Second), Speed: speed})
+	}
+	// Northbound, stop, then turn left (west).
+	for i := 0; i < 4; i++ {
+		add(52.5+float64(i)*0.0001, 13.4, 5)
+	}
+	add(52.5003, 13.4, 0)
+	add(52.5003, 13.4, 0)
+	add(52.5003, 13.4, 0)
+	for i := 0; i < 4; i++ {
+		add(52.5003, 13.4-float64(i)*0.00015, 5)
+	}
+	// Second stop, then straight on (west).
+	add(52.5003, 13.39955, 0)
+	add(52.5003, 13.39955, 0)
+	add(52.5003, 13.39955, 0)
+	for i := 0; i < 4; i++ {
+		add(52.5003, 13.39955-float64(i)*0.00015, 5)
+	}
+
+	activityID, err := store.InsertActivity(context.Background(), storage.Activity{
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Turns",
+		StartTime: now,
+		Distance:  1000,
+	}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	processor := &StopStatsProcessor{
+		Store:   store,
+		Options: gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 10 * time.Second},
+	}
+	if err := processor.Process(context.Background(), activityID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	got, err := store.GetActivityStats(context.Background(), activityID)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.StopCount != 2 {
+		t.Fatalf("expected 2 stops, got %d", got.StopCount)
+	}
+	if got.TurnAfterStopCount != 1 {
+		t.Fatalf("expected 1 turn after stop, got %d", got.TurnAfterStopCount)
+	}
+}
@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+)
+
+// BuildRuleContext maps a stored activity and its stop stats onto the inputs
+// rules are evaluated against.
+func BuildRuleContext(activity storage.Activity, stopStats stats.StopStats) Context {
+	startUnix := int64(0)
+	if !activity.StartTime.IsZero() {
+		startUnix = activity.StartTime.Unix()
+	}
+	return Context{
+		Activity: ActivitySource{
+			ID:          activity.ID,
+			Type:        activity.Type,
+			SportType:   activity.EffectiveType(),
+			Name:        activity.Name,
+			StartUnix:   startUnix,
+			DistanceM:   activity.Distance,
+			MovingTimeS: activity.MovingTime,
+			GearID:      activity.GearID,
+			Commute:     activity.Commute,
+			Visibility:  activity.Visibility,
+		},
+		Stats: StatsSource{
+			StopCount:             stopStats.StopCount,
+			StopTotalSeconds:      stopStats.StopTotalSeconds,
+			LongestStopSeconds:    stopStats.LongestStopSeconds,
+			TrafficLightStopCount: stopStats.TrafficLightStopCount,
+			RoadCrossingCount:     stopStats.RoadCrossingCount,
+			ClimbM:                stopStats.ClimbMeters,
+		},
+	}
+}
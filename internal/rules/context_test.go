@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+)
+
+func TestBuildRuleContext(t *testing.T) {
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	ctx := BuildRuleContext(storage.Activity{
+		ID:         42,
+		UserID:     7,
+		Type:       "Ride",
+		Name:       "Morning Ride",
+		StartTime:  start,
+		Distance:   12345,
+		MovingTime: 1800,
+		GearID:     "b123",
+		Commute:    true,
+		Visibility: "followers_only",
+	}, stats.StopStats{
+		StopCount:             5,
+		StopTotalSeconds:      240,
+		TrafficLightStopCount: 2,
+		RoadCrossingCount:     3,
+	})
+
+	want := Context{
+		Activity: ActivitySource{
+			ID:          42,
+			Type:        "Ride",
+			SportType:   "Ride",
+			Name:        "Morning Ride",
+			StartUnix:   start.Unix(),
+			DistanceM:   12345,
+			MovingTimeS: 1800,
+			GearID:      "b123",
+			Commute:     true,
+			Visibility:  "followers_only",
+		},
+		Stats: StatsSource{
+			StopCount:             5,
+			StopTotalSeconds:      240,
+			TrafficLightStopCount: 2,
+			RoadCrossingCount:     3,
+		},
+	}
+	if ctx != want {
+		t.Fatalf("unexpected context:\n got %+v\nwant %+v", ctx, want)
+	}
+
+	if got := BuildRuleContext(storage.Activity{ID: 1}, stats.StopStats{}); got.Activity.StartUnix != 0 {
+		t.Fatalf("expected zero start time to map to 0, got %d", got.Activity.StartUnix)
+	}
+	got := BuildRuleContext(storage.Activity{Type: "Ride", SportType: "MountainBikeRide"}, stats.StopStats{})
+	if got.Activity.Type != "Ride" || got.Activity.SportType != "MountainBikeRide" {
+		t.Fatalf("expected type Ride and sport type MountainBikeRide, got %q and %q", got.Activity.Type, got.Activity.SportType)
+	}
+	if got := BuildRuleContext(storage.Activity{Type: "Ride"}, stats.StopStats{}); got.Activity.SportType != "Ride" {
+		t.Fatalf("expected sport type to fall back to type, got %q", got.Activity.SportType)
+	}
+}
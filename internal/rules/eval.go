@@ -145,7 +145,7 @@ func Describe(rule Rule, reg Registry) string {
 		if operator != nil {
 			label = operator.Label
 		}
-		valueText := formatValues(metric.Type, metric.Unit, cond.Values)
+		valueText := formatValues(metric, cond.Values)
 		parts = append(parts, fmt.Sprintf("%s %s %s", metric.Label, label, valueText))
 	}
 	description := strings.Join(parts, joiner)
@@ -207,6 +207,14 @@ func validateValues(valueType ValueType, operator OperatorSpec, values []any) er
 		}
 		return nil
 	}
+	if valueType == ValueBool {
+		for _, v := range values {
+			if _, ok := toBool(v); !ok {
+				return fmt.Errorf("%w: boolean value expected", ErrInvalidRule)
+			}
+		}
+		return nil
+	}
 	return fmt.Errorf("%w: unsupported metric type", ErrInvalidRule)
 }
 
@@ -224,6 +232,12 @@ func evalCondition(valueType ValueType, op string, metricValue Value, rawValues
 			return false, err
 		}
 		return evalEnum(op, metricValue.Str, values)
+	case ValueBool:
+		values, err := parseBoolValues(rawValues)
+		if err != nil {
+			return false, err
+		}
+		return evalBool(op, metricValue.Bool, values)
 	default:
 		return false, fmt.Errorf("unsupported value type")
 	}
@@ -253,6 +267,18 @@ func parseStringValues(values []any) ([]string, error) {
 	return out, nil
 }
 
+func parseBoolValues(values []any) ([]bool, error) {
+	out := make([]bool, 0, len(values))
+	for _, v := range values {
+		b, ok := toBool(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid boolean")
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
 func toFloat(value any) (float64, bool) {
 	switch v := value.(type) {
 	case float64:
@@ -291,6 +317,23 @@ func toString(value any) (string, bool) {
 	}
 }
 
+func toBool(value any) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1":
+			return true, true
+		case "false", "no", "0":
+			return false, true
+		}
+		return false, false
+	default:
+		return false, false
+	}
+}
+
 func evalNumber(op string, metric float64, values []float64) (bool, error) {
 	switch op {
 	case "eq":
@@ -343,16 +386,35 @@ func evalEnum(op string, metric string, values []string) (bool, error) {
 	}
 }
 
-func formatValues(valueType ValueType, unit string, values []any) string {
-	switch valueType {
+func evalBool(op string, metric bool, values []bool) (bool, error) {
+	switch op {
+	case "eq":
+		return metric == values[0], nil
+	case "neq":
+		return metric != values[0], nil
+	default:
+		return false, ErrInvalidOperator
+	}
+}
+
+func formatValues(metric Metric, values []any) string {
+	switch metric.Type {
 	case ValueNumber:
 		nums, err := parseNumberValues(values)
 		if err != nil {
 			return "?"
 		}
+		unit := metric.Unit
+		factor := 1.0
+		if metric.DisplayUnit != "" {
+			unit = metric.DisplayUnit
+			if metric.DisplayFactor != 0 {
+				factor = metric.DisplayFactor
+			}
+		}
 		parts := make([]string, 0, len(nums))
 		for _, n := range nums {
-			parts = append(parts, formatNumber(n, unit))
+			parts = append(parts, formatNumber(math.Round(n*factor*100)/100, unit))
 		}
 		return strings.Join(parts, " and ")
 	case ValueEnum:
@@ -361,6 +423,20 @@ func formatValues(valueType ValueType, unit string, values []any) string {
 			return "?"
 		}
 		return strings.Join(vals, ", ")
+	case ValueBool:
+		vals, err := parseBoolValues(values)
+		if err != nil {
+			return "?"
+		}
+		parts := make([]string, 0, len(vals))
+		for _, v := range vals {
+			if v {
+				parts = append(parts, "yes")
+			} else {
+				parts = append(parts, "no")
+			}
+		}
+		return strings.Join(parts, ", ")
 	default:
 		return "?"
 	}
@@ -370,9 +446,15 @@ func formatNumber(value float64, unit string) string {
 	if unit == "sec/km" {
 		return formatPaceSecondsPerKM(value)
 	}
+	if unit == "duration" {
+		return formatDurationSeconds(value)
+	}
 	if unit == "" {
 		return trimFloat(value)
 	}
+	if strings.HasPrefix(unit, "/") {
+		return trimFloat(value) + unit
+	}
 	return fmt.Sprintf("%s %s", trimFloat(value), unit)
 }
 
@@ -386,6 +468,23 @@ func formatPaceSecondsPerKM(value float64) string {
 	return fmt.Sprintf("%d:%02d /km", minutes, seconds)
 }
 
+func formatDurationSeconds(value float64) string {
+	totalMinutes := int(math.Round(value / 60))
+	if totalMinutes < 0 {
+		totalMinutes = 0
+	}
+	hours := totalMinutes / 60
+	minutes := totalMinutes % 60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+}
+
 func trimFloat(value float64) string {
 	if value == float64(int64(value)) {
 		return fmt.Sprintf("%d", int64(value))
@@ -10,6 +10,7 @@ type MetricMeta struct {
 	Example     string    `json:"example"`
 	Type        ValueType `json:"type"`
 	Enum        []string  `json:"enum,omitempty"`
+	DisplayUnit string    `json:"display_unit,omitempty"`
 }
 
 type Metadata struct {
@@ -28,6 +29,7 @@ func BuildMetadata(reg Registry, ops map[ValueType][]OperatorSpec) Metadata {
 			Example:     metric.Example,
 			Type:        metric.Type,
 			Enum:        append([]string(nil), metric.Enum...),
+			DisplayUnit: metric.DisplayUnit,
 		})
 	}
 	sort.Slice(metrics, func(i, j int) bool {
@@ -5,12 +5,14 @@ import "time"
 func DefaultRegistry() Registry {
 	return Registry{
 		"distance_m": {
-			ID:          "distance_m",
-			Label:       "Distance",
-			Description: "Total distance in meters",
-			Unit:        "m",
-			Example:     "20000",
-			Type:        ValueNumber,
+			ID:            "distance_m",
+			Label:         "Distance",
+			Description:   "Total distance in meters",
+			Unit:          "m",
+			Example:       "20000",
+			Type:          ValueNumber,
+			DisplayUnit:   "km",
+			DisplayFactor: 0.001,
 			Resolve: func(ctx Context) (Value, error) {
 				return Value{Type: ValueNumber, Num: ctx.Activity.DistanceM}, nil
 			},
@@ -22,6 +24,7 @@ func DefaultRegistry() Registry {
 			Unit:        "s",
 			Example:     "3600",
 			Type:        ValueNumber,
+			DisplayUnit: "duration",
 			Resolve: func(ctx Context) (Value, error) {
 				return Value{Type: ValueNumber, Num: float64(ctx.Activity.MovingTimeS)}, nil
 			},
@@ -62,6 +65,72 @@ func DefaultRegistry() Registry {
 				return Value{Type: ValueEnum, Str: ctx.Activity.Type}, nil
 			},
 		},
+		"sport_type": {
+			ID:          "sport_type",
+			Label:       "Sport type",
+			Description: "Strava sport type (e.g. MountainBikeRide), falling back to the activity type",
+			Unit:        "",
+			Example:     "MountainBikeRide",
+			Type:        ValueEnum,
+			Enum: []string{
+				"Ride",
+				"MountainBikeRide",
+				"GravelRide",
+				"EBikeRide",
+				"EMountainBikeRide",
+				"VirtualRide",
+				"Run",
+				"TrailRun",
+				"VirtualRun",
+				"Walk",
+				"Hike",
+				"Swim",
+				"Workout",
+				"Rowing",
+				"NordicSki",
+			},
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueEnum, Str: ctx.Activity.SportType}, nil
+			},
+		},
+		"gear_id": {
+			ID:          "gear_id",
+			Label:       "Gear",
+			Description: "Strava gear id (bike or shoes), e.g. b1234567. Empty when no gear is set.",
+			Unit:        "",
+			Example:     "b1234567",
+			Type:        ValueEnum,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueEnum, Str: ctx.Activity.GearID}, nil
+			},
+		},
+		"is_commute": {
+			ID:          "is_commute",
+			Label:       "Commute",
+			Description: "Whether the activity is marked as a commute on Strava",
+			Unit:        "",
+			Example:     "true",
+			Type:        ValueBool,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueBool, Bool: ctx.Activity.Commute}, nil
+			},
+		},
+		"visibility": {
+			ID:          "visibility",
+			Label:       "Visibility",
+			Description: "Strava visibility of the activity",
+			Unit:        "",
+			Example:     "everyone",
+			Type:        ValueEnum,
+			Enum:        []string{"everyone", "followers_only", "only_me"},
+			Resolve: func(ctx Context) (Value, error) {
+				visibility := ctx.Activity.Visibility
+				if visibility == "" {
+					visibility = "everyone"
+				}
+				return Value{Type: ValueEnum, Str: visibility}, nil
+			},
+		},
 		"start_hour": {
 			ID:          "start_hour",
 			Label:       "Start hour",
@@ -98,6 +167,39 @@ func DefaultRegistry() Registry {
 				return Value{Type: ValueNumber, Num: float64(ctx.Stats.StopTotalSeconds)}, nil
 			},
 		},
+		"stop_total_minutes": {
+			ID:          "stop_total_minutes",
+			Label:       "Total stop time",
+			Description: "Total stop time in minutes",
+			Unit:        "min",
+			Example:     "10",
+			Type:        ValueNumber,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueNumber, Num: float64(ctx.Stats.StopTotalSeconds) / 60}, nil
+			},
+		},
+		"longest_stop_seconds": {
+			ID:          "longest_stop_seconds",
+			Label:       "Longest stop",
+			Description: "Duration of the longest single stop in seconds",
+			Unit:        "s",
+			Example:     "600",
+			Type:        ValueNumber,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueNumber, Num: float64(ctx.Stats.LongestStopSeconds)}, nil
+			},
+		},
+		"stops_per_km": {
+			ID:          "stops_per_km",
+			Label:       "Stops per km",
+			Description: "Detected stops per kilometer; 0 when the activity has no distance",
+			Unit:        "/km",
+			Example:     "1.5",
+			Type:        ValueNumber,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueNumber, Num: stopsPerKM(ctx.Stats.StopCount, ctx.Activity.DistanceM)}, nil
+			},
+		},
 		"traffic_light_stop_count": {
 			ID:          "traffic_light_stop_count",
 			Label:       "Traffic light stops",
@@ -120,6 +222,17 @@ func DefaultRegistry() Registry {
 				return Value{Type: ValueNumber, Num: float64(ctx.Stats.RoadCrossingCount)}, nil
 			},
 		},
+		"total_climb_m": {
+			ID:          "total_climb_m",
+			Label:       "Total climb",
+			Description: "Elevation gain in meters, ignoring GPS altitude noise; 0 without altitude data",
+			Unit:        "m",
+			Example:     "500",
+			Type:        ValueNumber,
+			Resolve: func(ctx Context) (Value, error) {
+				return Value{Type: ValueNumber, Num: ctx.Stats.ClimbM}, nil
+			},
+		},
 	}
 }
 
@@ -130,6 +243,13 @@ func paceSecondsPerKM(distanceM float64, movingTimeS int) float64 {
 	return float64(movingTimeS) / (distanceM / 1000)
 }
 
+func stopsPerKM(stopCount int, distanceM float64) float64 {
+	if distanceM <= 0 {
+		return 0
+	}
+	return float64(stopCount) / (distanceM / 1000)
+}
+
 func DefaultOperators() map[ValueType][]OperatorSpec {
 	return map[ValueType][]OperatorSpec{
 		ValueNumber: {
@@ -147,5 +267,9 @@ func DefaultOperators() map[ValueType][]OperatorSpec {
 			{ID: "in", Label: "in", ValueCount: -1, ValueMode: "list"},
 			{ID: "not_in", Label: "not in", ValueCount: -1, ValueMode: "list"},
 		},
+		ValueBool: {
+			{ID: "eq", Label: "is", ValueCount: 1, ValueMode: "single"},
+			{ID: "neq", Label: "is not", ValueCount: 1, ValueMode: "single"},
+		},
 	}
 }
@@ -1,6 +1,7 @@
 package rules
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -153,3 +154,224 @@ func TestDescribeRulePaceMetric(t *testing.T) {
 		t.Fatalf("expected pace text in description, got %q", description)
 	}
 }
+
+func TestEvaluateRule_WithStopTotalMinutes(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"stop_total_minutes","op":"gt","values":[10]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	cases := []struct {
+		seconds int
+		want    bool
+	}{
+		{seconds: 660, want: true},
+		{seconds: 600, want: false},
+		{seconds: 540, want: false},
+	}
+	for _, tc := range cases {
+		ctx := Context{Activity: ActivitySource{ID: 9}, Stats: StatsSource{StopTotalSeconds: tc.seconds}}
+		matched, _, err := Evaluate(parsed, reg, ctx, 4)
+		if err != nil {
+			t.Fatalf("evaluate rule: %v", err)
+		}
+		if matched != tc.want {
+			t.Fatalf("%ds: expected matched=%t, got %t", tc.seconds, tc.want, matched)
+		}
+	}
+}
+
+func TestDescribeRuleStopTotalMinutes(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"stop_total_minutes","op":"gt","values":[10]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	description := Describe(parsed, reg)
+	if !strings.Contains(description, "Total stop time > 10 min") {
+		t.Fatalf("expected minutes in description, got %q", description)
+	}
+}
+
+func TestEvaluateRule_WithStopsPerKM(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"stops_per_km","op":"gte","values":[1.5]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	cases := []struct {
+		name      string
+		stops     int
+		distanceM float64
+		want      bool
+	}{
+		{name: "city commute", stops: 12, distanceM: 6000, want: true},
+		{name: "open road", stops: 3, distanceM: 40000, want: false},
+		{name: "zero distance", stops: 5, distanceM: 0, want: false},
+	}
+	for _, tc := range cases {
+		ctx := Context{
+			Activity: ActivitySource{ID: 12, DistanceM: tc.distanceM},
+			Stats:    StatsSource{StopCount: tc.stops},
+		}
+		matched, _, err := Evaluate(parsed, reg, ctx, 6)
+		if err != nil {
+			t.Fatalf("%s: evaluate rule: %v", tc.name, err)
+		}
+		if matched != tc.want {
+			t.Fatalf("%s: expected matched=%t, got %t", tc.name, tc.want, matched)
+		}
+	}
+
+	value, err := reg["stops_per_km"].Resolve(Context{Stats: StatsSource{StopCount: 5}})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if value.Num != 0 {
+		t.Fatalf("expected 0 stops/km for zero distance, got %v", value.Num)
+	}
+	if got := Describe(parsed, reg); !strings.Contains(got, "Stops per km >= 1.50/km") {
+		t.Fatalf("expected /km unit in description, got %q", got)
+	}
+}
+
+func TestDescribeRuleDisplayUnits(t *testing.T) {
+	reg := DefaultRegistry()
+	raw := `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[20000]},{"metric":"moving_time_s","op":"between","values":[1800,5400]}],"action":{"type":"hide"}}`
+	parsed, err := ParseRuleJSON(raw)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	description := Describe(parsed, reg)
+	if !strings.Contains(description, "Distance < 20 km") {
+		t.Fatalf("expected km distance in description, got %q", description)
+	}
+	if !strings.Contains(description, "Moving time between 30m and 1h 30m") {
+		t.Fatalf("expected duration in description, got %q", description)
+	}
+	if got := parsed.Conditions[0].Values[0]; fmt.Sprint(got) != "20000" {
+		t.Fatalf("expected raw value to stay in meters, got %v", got)
+	}
+}
+
+func TestEvaluateRule_WithGearID(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"gear_id","op":"eq","values":["b1234567"]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	matched, _, err := Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 90, GearID: "b1234567"}}, 15)
+	if err != nil {
+		t.Fatalf("evaluate rule: %v", err)
+	}
+	if !matched {
+		t.Fatalf("expected gear match")
+	}
+	matched, _, err = Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 91}}, 15)
+	if err != nil {
+		t.Fatalf("evaluate rule without gear: %v", err)
+	}
+	if matched {
+		t.Fatalf("expected no match without gear")
+	}
+}
+
+func TestEvaluateRule_WithCommute(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"is_commute","op":"eq","values":[true]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	matched, hide, err := Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 92, Type: "Ride", Commute: true}}, 17)
+	if err != nil {
+		t.Fatalf("evaluate rule: %v", err)
+	}
+	if !matched || !hide {
+		t.Fatalf("expected commute to be hidden, matched=%t hide=%t", matched, hide)
+	}
+	matched, _, err = Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 93, Type: "Ride"}}, 17)
+	if err != nil {
+		t.Fatalf("evaluate rule: %v", err)
+	}
+	if matched {
+		t.Fatalf("expected non-commute not to match")
+	}
+}
+
+func TestBoolCondition_ParseValidateEvaluate(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"is_commute","op":"neq","values":[false]}]}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if got, ok := parsed.Conditions[0].Values[0].(bool); !ok || got {
+		t.Fatalf("expected parsed bool false, got %#v", parsed.Conditions[0].Values[0])
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	matched, _, err := Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 1, Commute: true}}, 1)
+	if err != nil {
+		t.Fatalf("evaluate rule: %v", err)
+	}
+	if !matched {
+		t.Fatalf("expected commute activity to match neq false")
+	}
+	if got := Describe(parsed, reg); got != "Commute is not no" {
+		t.Fatalf("unexpected description %q", got)
+	}
+
+	for _, raw := range []string{
+		`{"conditions":[{"metric":"is_commute","op":"eq","values":[1.5]}]}`,
+		`{"conditions":[{"metric":"is_commute","op":"eq","values":["maybe"]}]}`,
+		`{"conditions":[{"metric":"is_commute","op":"in","values":[true]}]}`,
+	} {
+		rule, err := ParseRuleJSON(raw)
+		if err != nil {
+			t.Fatalf("parse rule: %v", err)
+		}
+		if err := ValidateRule(rule, reg); err == nil {
+			t.Fatalf("expected validation error for %s", raw)
+		}
+	}
+}
+
+func TestEvaluateRule_WithVisibility(t *testing.T) {
+	reg := DefaultRegistry()
+	parsed, err := ParseRuleJSON(`{"match":"all","conditions":[{"metric":"visibility","op":"in","values":["only_me"]}],"action":{"type":"hide"}}`)
+	if err != nil {
+		t.Fatalf("parse rule: %v", err)
+	}
+	if err := ValidateRule(parsed, reg); err != nil {
+		t.Fatalf("validate rule: %v", err)
+	}
+	cases := []struct {
+		visibility string
+		want       bool
+	}{
+		{visibility: "only_me", want: true},
+		{visibility: "everyone", want: false},
+		{visibility: "", want: false},
+	}
+	for _, tc := range cases {
+		matched, _, err := Evaluate(parsed, reg, Context{Activity: ActivitySource{ID: 5, Visibility: tc.visibility}}, 3)
+		if err != nil {
+			t.Fatalf("evaluate rule: %v", err)
+		}
+		if matched != tc.want {
+			t.Fatalf("visibility %q: expected matched=%t, got %t", tc.visibility, tc.want, matched)
+		}
+	}
+}
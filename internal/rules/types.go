@@ -5,12 +5,14 @@ type ValueType string
 const (
 	ValueNumber ValueType = "number"
 	ValueEnum   ValueType = "enum"
+	ValueBool   ValueType = "bool"
 )
 
 type Value struct {
 	Type ValueType
 	Num  float64
 	Str  string
+	Bool bool
 }
 
 type Context struct {
@@ -21,17 +23,23 @@ type Context struct {
 type ActivitySource struct {
 	ID          int64
 	Type        string
+	SportType   string
 	Name        string
 	StartUnix   int64
 	DistanceM   float64
 	MovingTimeS int
+	GearID      string
+	Commute     bool
+	Visibility  string
 }
 
 type StatsSource struct {
 	StopCount             int
 	StopTotalSeconds      int
+	LongestStopSeconds    int
 	TrafficLightStopCount int
 	RoadCrossingCount     int
+	ClimbM                float64
 }
 
 type Metric struct {
@@ -42,7 +50,11 @@ type Metric struct {
 	Example     string
 	Type        ValueType
 	Enum        []string
-	Resolve     func(ctx Context) (Value, error)
+	// DisplayUnit and DisplayFactor only affect descriptions; rule values
+	// stay in Unit.
+	DisplayUnit   string
+	DisplayFactor float64
+	Resolve       func(ctx Context) (Value, error)
 }
 
 type Registry map[string]Metric
@@ -5,9 +5,14 @@ import "time"
 type StopStats struct {
 	StopCount             int
 	StopTotalSeconds      int
+	LongestStopSeconds    int
 	TrafficLightStopCount int
 	RoadCrossingCount     int
+	TurnAfterStopCount    int
+	ClimbMeters           float64
 	EffortScore           float64
 	EffortVersion         int
+	OptionsHash           string
+	StatsVersion          int
 	UpdatedAt             time.Time
 }
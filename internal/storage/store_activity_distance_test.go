@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+)
+
+func TestInsertActivityDistance(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.May, 4, 7, 0, 0, 0, time.UTC)
+	// Ten steps of 0.001° along a meridian; one degree of latitude is
+	// 6371km * pi / 180 on the haversine sphere.
+	var points []gps.Point
+	for i := 0; i <= 10; i++ {
+		points = append(points, gps.Point{Lat: 48 + float64(i)*0.001, Lon: 11.5, Time: start.Add(time.Duration(i) * 20 * time.Second)})
+	}
+	want := 10 * 0.001 * 6371000 * math.Pi / 180
+
+	cases := []struct {
+		name     string
+		id       int64
+		distance float64
+		want     float64
+	}{
+		{name: "computed from points", id: 1, want: want},
+		{name: "strava distance kept", id: 2, distance: 1234.5, want: 1234.5},
+	}
+	for _, tc := range cases {
+		if _, err := store.UpsertActivity(ctx, Activity{ID: tc.id, UserID: 1, Type: "Ride", Name: tc.name, StartTime: start, Distance: tc.distance}, points); err != nil {
+			t.Fatalf("%s: upsert: %v", tc.name, err)
+		}
+		activity, err := store.GetActivity(ctx, tc.id)
+		if err != nil {
+			t.Fatalf("%s: get: %v", tc.name, err)
+		}
+		if math.Abs(activity.Distance-tc.want) > 0.01 {
+			t.Fatalf("%s: expected distance %.2f, got %.2f", tc.name, tc.want, activity.Distance)
+		}
+	}
+
+	negative := Activity{ID: 3, UserID: 1, Type: "Ride", Name: "Negative", StartTime: start, Distance: -5}
+	if _, err := store.InsertActivity(ctx, negative, points); err == nil {
+		t.Fatalf("expected insert to reject a negative distance")
+	}
+	if _, err := store.UpsertActivity(ctx, negative, points); err == nil {
+		t.Fatalf("expected upsert to reject a negative distance")
+	}
+}
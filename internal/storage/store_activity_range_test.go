@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListActivitiesWithStatsInRangeBoundaries(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, time.May, 4, 0, 0, 0, 0, time.UTC)
+	seed := map[int64]time.Time{
+		1: start.Add(-time.Second),
+		2: start,
+		3: start.Add(36 * time.Hour),
+		4: end.Add(-time.Second),
+		5: end,
+	}
+	for id, startTime := range seed {
+		if _, err := store.InsertActivity(ctx, Activity{ID: id, UserID: 3, Type: "Ride", Name: "Trip", StartTime: startTime}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+	}
+
+	got, err := store.ListActivitiesWithStatsInRange(ctx, 3, start, end, 100)
+	if err != nil {
+		t.Fatalf("list range: %v", err)
+	}
+	var ids []int64
+	for _, item := range got {
+		ids = append(ids, item.ID)
+	}
+	if len(ids) != 3 || ids[0] != 4 || ids[1] != 3 || ids[2] != 2 {
+		t.Fatalf("expected activities [4 3 2] (start inclusive, end exclusive), got %v", ids)
+	}
+
+	if _, err := store.ListActivitiesWithStatsInRange(ctx, 3, end, start, 100); err == nil {
+		t.Fatalf("expected error for inverted range")
+	}
+}
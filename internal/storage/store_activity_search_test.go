@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchActivities(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	base := time.Date(2026, time.July, 1, 8, 0, 0, 0, time.UTC)
+	names := map[int64]string{
+		1: "Morning Ride to Coast",
+		2: "Evening ride",
+		3: "100% effort",
+		4: "1000 effort",
+		5: "lap_one",
+		6: "lapXone",
+	}
+	for id, name := range names {
+		if _, err := store.InsertActivity(ctx, Activity{ID: id, UserID: 4, Type: "Ride", Name: name, StartTime: base.Add(time.Duration(id) * time.Hour)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+	}
+	if _, err := store.InsertActivity(ctx, Activity{ID: 7, UserID: 5, Type: "Ride", Name: "Ride elsewhere", StartTime: base}, nil); err != nil {
+		t.Fatalf("insert other user activity: %v", err)
+	}
+
+	cases := []struct {
+		q    string
+		want []int64
+	}{
+		{q: "RIDE", want: []int64{2, 1}},
+		{q: "coast", want: []int64{1}},
+		{q: "100%", want: []int64{3}},
+		{q: "lap_", want: []int64{5}},
+		{q: "nothing here", want: nil},
+		{q: "", want: []int64{6, 5, 4, 3, 2, 1}},
+	}
+	for _, tc := range cases {
+		got, err := store.SearchActivities(ctx, 4, tc.q, 100)
+		if err != nil {
+			t.Fatalf("search %q: %v", tc.q, err)
+		}
+		var ids []int64
+		for _, item := range got {
+			ids = append(ids, item.ID)
+		}
+		if len(ids) != len(tc.want) {
+			t.Fatalf("search %q: expected %v, got %v", tc.q, tc.want, ids)
+		}
+		for i := range ids {
+			if ids[i] != tc.want[i] {
+				t.Fatalf("search %q: expected %v, got %v", tc.q, tc.want, ids)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+)
+
+func TestQueryActivitiesWithStatsSortKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	base := time.Date(2026, time.June, 1, 8, 0, 0, 0, time.UTC)
+	seed := []struct {
+		id        int64
+		start     time.Time
+		distance  float64
+		stops     int
+		stopSecs  int
+		withStats bool
+	}{
+		{id: 1, start: base, distance: 12000, stops: 3, stopSecs: 400, withStats: true},
+		{id: 2, start: base.Add(24 * time.Hour), distance: 5000, stops: 8, stopSecs: 90, withStats: true},
+		{id: 3, start: base.Add(48 * time.Hour), distance: 30000, stops: 1, stopSecs: 900, withStats: true},
+		{id: 4, start: base.Add(72 * time.Hour), distance: 800},
+	}
+	for _, a := range seed {
+		if _, err := store.InsertActivity(ctx, Activity{ID: a.id, UserID: 2, Type: "Ride", Name: "Ride", StartTime: a.start, Distance: a.distance}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", a.id, err)
+		}
+		if a.withStats {
+			if err := store.UpsertActivityStats(ctx, a.id, stats.StopStats{StopCount: a.stops, StopTotalSeconds: a.stopSecs}); err != nil {
+				t.Fatalf("upsert stats %d: %v", a.id, err)
+			}
+		}
+	}
+
+	cases := []struct {
+		sort      string
+		ascending bool
+		want      []int64
+	}{
+		{sort: "", want: []int64{4, 3, 2, 1}},
+		{sort: ActivitySortDate, want: []int64{4, 3, 2, 1}},
+		{sort: ActivitySortDate, ascending: true, want: []int64{1, 2, 3, 4}},
+		{sort: ActivitySortStops, want: []int64{2, 1, 3, 4}},
+		{sort: ActivitySortStops, ascending: true, want: []int64{4, 3, 1, 2}},
+		{sort: ActivitySortStopTime, want: []int64{3, 1, 2, 4}},
+		{sort: ActivitySortStopTime, ascending: true, want: []int64{4, 2, 1, 3}},
+		{sort: ActivitySortDistance, want: []int64{3, 1, 2, 4}},
+		{sort: ActivitySortDistance, ascending: true, want: []int64{4, 2, 1, 3}},
+	}
+	for _, tc := range cases {
+		got, err := store.QueryActivitiesWithStats(ctx, 2, ActivityListQuery{Sort: tc.sort, Ascending: tc.ascending})
+		if err != nil {
+			t.Fatalf("sort %q asc=%t: %v", tc.sort, tc.ascending, err)
+		}
+		var ids []int64
+		for _, item := range got {
+			ids = append(ids, item.ID)
+		}
+		if len(ids) != len(tc.want) {
+			t.Fatalf("sort %q asc=%t: expected %v, got %v", tc.sort, tc.ascending, tc.want, ids)
+		}
+		for i := range ids {
+			if ids[i] != tc.want[i] {
+				t.Fatalf("sort %q asc=%t: expected %v, got %v", tc.sort, tc.ascending, tc.want, ids)
+			}
+		}
+	}
+
+	if _, err := store.QueryActivitiesWithStats(ctx, 2, ActivityListQuery{Sort: "name; DROP TABLE activities"}); err == nil {
+		t.Fatalf("expected error for unknown sort key")
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+)
+
+func TestAggregateStatsByType(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	since := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if got, err := store.AggregateStatsByType(ctx, 9, since); err != nil || len(got) != 0 {
+		t.Fatalf("expected empty aggregates without data, got %v (err=%v)", got, err)
+	}
+
+	seed := []struct {
+		id        int64
+		userID    int64
+		kind      string
+		start     time.Time
+		stops     int
+		stopSecs  int
+		withStats bool
+	}{
+		{id: 1, userID: 9, kind: "Ride", start: since.Add(time.Hour), stops: 4, stopSecs: 120, withStats: true},
+		{id: 2, userID: 9, kind: "Ride", start: since.Add(48 * time.Hour), stops: 1, stopSecs: 30, withStats: true},
+		{id: 3, userID: 9, kind: "Run", start: since.Add(72 * time.Hour), stops: 2, stopSecs: 50, withStats: true},
+		{id: 4, userID: 9, kind: "Ride", start: since.Add(-time.Hour), stops: 10, stopSecs: 900, withStats: true},
+		{id: 5, userID: 9, kind: "Ride", start: since.Add(96 * time.Hour)},
+		{id: 6, userID: 10, kind: "Ride", start: since.Add(time.Hour), stops: 7, stopSecs: 70, withStats: true},
+	}
+	for _, a := range seed {
+		if _, err := store.InsertActivity(ctx, Activity{ID: a.id, UserID: a.userID, Type: a.kind, Name: a.kind, StartTime: a.start}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", a.id, err)
+		}
+		if a.withStats {
+			if err := store.UpsertActivityStats(ctx, a.id, stats.StopStats{StopCount: a.stops, StopTotalSeconds: a.stopSecs}); err != nil {
+				t.Fatalf("upsert stats %d: %v", a.id, err)
+			}
+		}
+	}
+
+	got, err := store.AggregateStatsByType(ctx, 9, since)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected Ride and Run, got %v", got)
+	}
+	ride := got["Ride"]
+	if ride.ActivityCount != 2 || ride.TotalStopCount != 5 || ride.AvgStopCount != 2.5 || ride.TotalStopSeconds != 150 || ride.AvgStopSeconds != 75 {
+		t.Fatalf("unexpected Ride aggregate: %+v", ride)
+	}
+	run := got["Run"]
+	if run.ActivityCount != 1 || run.TotalStopCount != 2 || run.AvgStopCount != 2 || run.AvgStopSeconds != 50 {
+		t.Fatalf("unexpected Run aggregate: %+v", run)
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/stats"
+)
+
+func TestDeleteActivityForUserRemovesRowsAndIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	activityID, err := store.InsertActivity(ctx, Activity{
+		ID:        99,
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Deleted Ride",
+		StartTime: start,
+	}, []gps.Point{{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6}})
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	if err := store.UpsertActivityStats(ctx, activityID, stats.StopStats{StopCount: 2}); err != nil {
+		t.Fatalf("upsert stats: %v", err)
+	}
+
+	if err := store.DeleteActivityForUser(ctx, 2, activityID); err != nil {
+		t.Fatalf("delete foreign activity: %v", err)
+	}
+	if exists, err := store.HasActivity(ctx, activityID); err != nil || !exists {
+		t.Fatalf("expected another user's delete to leave the activity, exists=%t err=%v", exists, err)
+	}
+
+	if err := store.DeleteActivityForUser(ctx, 1, activityID); err != nil {
+		t.Fatalf("delete activity: %v", err)
+	}
+	if exists, err := store.HasActivity(ctx, activityID); err != nil || exists {
+		t.Fatalf("expected activity to be gone, exists=%t err=%v", exists, err)
+	}
+	if count, err := store.CountActivityPoints(ctx, activityID); err != nil || count != 0 {
+		t.Fatalf("expected no points, count=%d err=%v", count, err)
+	}
+	if _, err := store.GetActivityStats(ctx, activityID); err == nil {
+		t.Fatalf("expected stats to be deleted")
+	}
+
+	if err := store.DeleteActivityForUser(ctx, 1, activityID); err != nil {
+		t.Fatalf("delete missing activity: %v", err)
+	}
+}
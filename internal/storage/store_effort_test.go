@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+)
+
+func TestEffortPercentile(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	if got, err := store.EffortPercentile(ctx, 1, 50); err != nil || got != 0 {
+		t.Fatalf("expected 0 with no scored activities, got %.1f (%v)", got, err)
+	}
+
+	base := time.Date(2026, time.February, 1, 7, 0, 0, 0, time.UTC)
+	// User 1 has scores 10, 20, ..., 100; user 2 has one huge score and an
+	// unscored activity, neither of which may affect user 1.
+	seed := []struct {
+		id      int64
+		userID  int64
+		score   float64
+		version int
+	}{
+		{id: 1, userID: 1, score: 10, version: 1},
+		{id: 2, userID: 1, score: 20, version: 1},
+		{id: 3, userID: 1, score: 30, version: 1},
+		{id: 4, userID: 1, score: 40, version: 1},
+		{id: 5, userID: 1, score: 50, version: 1},
+		{id: 6, userID: 1, score: 60, version: 1},
+		{id: 7, userID: 1, score: 70, version: 1},
+		{id: 8, userID: 1, score: 80, version: 1},
+		{id: 9, userID: 1, score: 90, version: 1},
+		{id: 10, userID: 1, score: 100, version: 1},
+		{id: 11, userID: 1, score: 5},
+		{id: 12, userID: 2, score: 1000, version: 1},
+	}
+	for i, a := range seed {
+		if _, err := store.InsertActivity(ctx, Activity{ID: a.id, UserID: a.userID, Type: "Ride", Name: "Ride", StartTime: base.Add(time.Duration(i) * time.Hour)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", a.id, err)
+		}
+		if err := store.UpsertActivityStats(ctx, a.id, stats.StopStats{EffortScore: a.score, EffortVersion: a.version}); err != nil {
+			t.Fatalf("upsert stats %d: %v", a.id, err)
+		}
+	}
+
+	cases := []struct {
+		score float64
+		want  float64
+	}{
+		{score: 5, want: 0},
+		{score: 10, want: 10},
+		{score: 55, want: 50},
+		{score: 90, want: 90},
+		{score: 100, want: 100},
+		{score: 500, want: 100},
+	}
+	for _, tc := range cases {
+		got, err := store.EffortPercentile(ctx, 1, tc.score)
+		if err != nil {
+			t.Fatalf("score %.0f: %v", tc.score, err)
+		}
+		if got != tc.want {
+			t.Fatalf("score %.0f: expected percentile %.0f, got %.1f", tc.score, tc.want, got)
+		}
+	}
+	if got, _ := store.EffortPercentile(ctx, 2, 1000); got != 100 {
+		t.Fatalf("expected user 2's only score to be their 100th percentile, got %.1f", got)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/stats"
+)
+
+func TestDeletingActivityCascadesToChildRows(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	activityID, err := store.InsertActivity(ctx, Activity{
+		ID:        7,
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Cascade",
+		StartTime: start,
+	}, []gps.Point{
+		{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6},
+		{Lat: 52.53, Lon: 13.406, Time: start.Add(time.Minute), Speed: 6},
+	})
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	if err := store.UpsertActivityStats(ctx, activityID, stats.StopStats{StopCount: 1}); err != nil {
+		t.Fatalf("upsert stats: %v", err)
+	}
+
+	if _, err := store.db.ExecContext(ctx, `DELETE FROM activities WHERE id = ?`, activityID); err != nil {
+		t.Fatalf("delete activity row: %v", err)
+	}
+	for _, table := range []string{"activity_points", "activity_stats"} {
+		var count int
+		if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE activity_id = ?`, activityID).Scan(&count); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if count != 0 {
+			t.Fatalf("expected %s rows to cascade, got %d", table, count)
+		}
+	}
+
+	if err := store.UpsertActivityStats(ctx, 12345, stats.StopStats{}); err == nil {
+		t.Fatalf("expected stats for a missing activity to violate the foreign key")
+	}
+}
+
+func TestInitSchemaAddsForeignKeysToLegacyTables(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	for _, stmt := range []string{
+		`CREATE TABLE activities (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL, type TEXT NOT NULL, name TEXT NOT NULL, start_time INTEGER NOT NULL, description TEXT NOT NULL, updated_at INTEGER NOT NULL)`,
+		`CREATE TABLE activity_stats (activity_id INTEGER PRIMARY KEY, stop_count INTEGER NOT NULL, stop_total_seconds INTEGER NOT NULL, traffic_light_stop_count INTEGER NOT NULL, updated_at INTEGER NOT NULL)`,
+		`INSERT INTO activities (id, user_id, type, name, start_time, description, updated_at) VALUES (1, 1, 'Ride', 'Kept', 0, '', 0)`,
+		`INSERT INTO activity_stats (activity_id, stop_count, stop_total_seconds, traffic_light_stop_count, updated_at) VALUES (1, 3, 90, 1, 0)`,
+		`INSERT INTO activity_stats (activity_id, stop_count, stop_total_seconds, traffic_light_stop_count, updated_at) VALUES (2, 5, 10, 0, 0)`,
+	} {
+		if _, err := store.db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("seed legacy schema: %v", err)
+		}
+	}
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	var fkCount int
+	if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_foreign_key_list('activity_stats')`).Scan(&fkCount); err != nil {
+		t.Fatalf("foreign key list: %v", err)
+	}
+	if fkCount != 1 {
+		t.Fatalf("expected activity_stats to gain a foreign key, got %d", fkCount)
+	}
+	got, err := store.GetActivityStats(ctx, 1)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.StopCount != 3 || got.StopTotalSeconds != 90 {
+		t.Fatalf("expected stats to survive the rebuild, got %+v", got)
+	}
+	if _, err := store.GetActivityStats(ctx, 2); err == nil {
+		t.Fatalf("expected orphaned stats to be dropped")
+	}
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema again: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpdateHideRuleKeepsCreatedAtAndOrder(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	created := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	firstID, err := store.CreateHideRule(ctx, HideRule{UserID: 4, Name: "first", Condition: `{}`, Enabled: true, CreatedAt: created, UpdatedAt: created})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if _, err := store.CreateHideRule(ctx, HideRule{UserID: 4, Name: "second", Condition: `{}`, CreatedAt: created.Add(time.Hour), UpdatedAt: created.Add(time.Hour)}); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+
+	if err := store.UpdateHideRuleForUser(ctx, 5, firstID, "hijacked", `{"x":1}`); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows updating another user's rule, got %v", err)
+	}
+	if err := store.UpdateHideRuleForUser(ctx, 4, firstID, "renamed", `{"match":"any"}`); err != nil {
+		t.Fatalf("update rule: %v", err)
+	}
+
+	list, err := store.ListHideRules(ctx, 4)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	if len(list) != 2 || list[1].ID != firstID {
+		t.Fatalf("expected edited rule to keep its position, got %+v", list)
+	}
+	edited := list[1]
+	if edited.Name != "renamed" || edited.Condition != `{"match":"any"}` || !edited.Enabled {
+		t.Fatalf("unexpected edited rule: %+v", edited)
+	}
+	if !edited.CreatedAt.Equal(created) {
+		t.Fatalf("expected created_at preserved, got %s", edited.CreatedAt)
+	}
+	if !edited.UpdatedAt.After(created) {
+		t.Fatalf("expected updated_at refreshed, got %s", edited.UpdatedAt)
+	}
+}
+
+func TestQueryActivitiesWithStatsHiddenOnly(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Date(2026, time.February, 1, 9, 0, 0, 0, time.UTC)
+	ruleID, err := store.CreateHideRule(ctx, HideRule{UserID: 6, Name: "Short commutes", Condition: `{}`, Enabled: true, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	for id := int64(1); id <= 2; id++ {
+		if _, err := store.InsertActivity(ctx, Activity{ID: id, UserID: 6, Type: "Ride", Name: "Ride", StartTime: now.Add(time.Duration(id) * time.Hour)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+	}
+	if err := store.SetActivityHiddenByRule(ctx, 2, ruleID); err != nil {
+		t.Fatalf("set hidden: %v", err)
+	}
+
+	hidden, err := store.QueryActivitiesWithStats(ctx, 6, ActivityListQuery{HiddenOnly: true})
+	if err != nil {
+		t.Fatalf("list hidden: %v", err)
+	}
+	if len(hidden) != 1 || hidden[0].ID != 2 {
+		t.Fatalf("expected only activity 2, got %+v", hidden)
+	}
+	if !hidden[0].HiddenByRule || hidden[0].HiddenByRuleID != ruleID || hidden[0].HiddenByRuleName != "Short commutes" {
+		t.Fatalf("expected rule details on hidden activity, got %+v", hidden[0])
+	}
+
+	if err := store.SetActivityHiddenByRule(ctx, 2, 0); err != nil {
+		t.Fatalf("clear hidden: %v", err)
+	}
+	hidden, err = store.QueryActivitiesWithStats(ctx, 6, ActivityListQuery{HiddenOnly: true})
+	if err != nil {
+		t.Fatalf("list hidden after clear: %v", err)
+	}
+	if len(hidden) != 0 {
+		t.Fatalf("expected no hidden activities after clearing, got %+v", hidden)
+	}
+}
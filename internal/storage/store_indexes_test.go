@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInitSchemaCreatesIndexes(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	cases := map[string]string{
+		"activities":     "idx_activities_user_start",
+		"activity_queue": "idx_activity_queue_processed_at",
+		"jobs":           "idx_jobs_status_updated_at",
+		"webhook_events": "idx_webhook_events_object",
+	}
+	for table, index := range cases {
+		var count int
+		if err := store.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_index_list(?) WHERE name = ?`, table, index).Scan(&count); err != nil {
+			t.Fatalf("index list for %s: %v", table, err)
+		}
+		if count != 1 {
+			t.Fatalf("expected index %s on %s", index, table)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApplySQLiteDefaultsPragmas(t *testing.T) {
+	dsn, err := applySQLiteDefaults("weirdstats.db", []string{"busy_timeout(10000)"})
+	if err != nil {
+		t.Fatalf("apply defaults: %v", err)
+	}
+	if !strings.Contains(dsn, "busy_timeout%2810000%29") || strings.Contains(dsn, "busy_timeout%285000%29") {
+		t.Fatalf("expected configured busy_timeout to replace the default, got %q", dsn)
+	}
+	for _, want := range []string{"journal_mode%28WAL%29", "foreign_keys%28on%29"} {
+		if !strings.Contains(dsn, want) {
+			t.Fatalf("expected %s in dsn %q", want, dsn)
+		}
+	}
+}
+
+func TestConcurrentWritersDoNotLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "concurrent.db")
+
+	first, err := Open(path)
+	if err != nil {
+		t.Fatalf("open first: %v", err)
+	}
+	defer first.Close()
+	if err := first.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	second, err := Open(path)
+	if err != nil {
+		t.Fatalf("open second: %v", err)
+	}
+	defer second.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for idx, store := range []*Store{first, second} {
+		wg.Add(1)
+		go func(offset int64, store *Store) {
+			defer wg.Done()
+			for i := int64(0); i < 25; i++ {
+				_, err := store.InsertActivity(ctx, Activity{
+					ID:        offset*1000 + i + 1,
+					UserID:    1,
+					Type:      "Ride",
+					Name:      fmt.Sprintf("ride %d", i),
+					StartTime: time.Unix(1700000000+i, 0),
+				}, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(int64(idx), store)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent insert: %v", err)
+	}
+
+	ids, err := first.ListActivityIDs(ctx, 1)
+	if err != nil {
+		t.Fatalf("list ids: %v", err)
+	}
+	if len(ids) != 50 {
+		t.Fatalf("expected 50 activities, got %d", len(ids))
+	}
+}
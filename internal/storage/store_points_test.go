@@ -27,7 +27,7 @@ func TestActivityPointsRoundTrip_WithOptionalStreams(t *testing.T) {
 		Name:      "Point Round Trip",
 		StartTime: start,
 	}, []gps.Point{
-		{Lat: 52.52, Lon: 13.405, Time: start, Speed: 5, Power: 210, HasPower: true, Grade: -6.5, HasGrade: true, HeartRate: 132, HasHeartRate: true},
+		{Lat: 52.52, Lon: 13.405, Time: start, Speed: 5, Power: 210, HasPower: true, Grade: -6.5, HasGrade: true, HeartRate: 132, HasHeartRate: true, Altitude: 34.5, HasAltitude: true},
 		{Lat: 52.53, Lon: 13.406, Time: start.Add(30 * time.Second), Speed: 8},
 	})
 	if err != nil {
@@ -50,7 +50,75 @@ func TestActivityPointsRoundTrip_WithOptionalStreams(t *testing.T) {
 	if !points[0].HasHeartRate || points[0].HeartRate != 132 {
 		t.Fatalf("expected first point heartrate to round-trip, got %+v", points[0])
 	}
-	if points[1].HasPower || points[1].HasGrade || points[1].HasHeartRate {
+	if !points[0].HasAltitude || points[0].Altitude != 34.5 {
+		t.Fatalf("expected first point altitude to round-trip, got %+v", points[0])
+	}
+	if points[1].HasPower || points[1].HasGrade || points[1].HasHeartRate || points[1].HasAltitude {
 		t.Fatalf("expected second point to have no optional streams, got %+v", points[1])
 	}
 }
+
+func TestInsertActivityPoints_BatchesKeepCountAndOrder(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.March, 24, 8, 0, 0, 0, time.UTC)
+	points := make([]gps.Point, 2000)
+	for i := range points {
+		points[i] = gps.Point{Lat: 52 + float64(i)*0.0001, Lon: 13, Time: start.Add(time.Duration(i) * time.Second), Speed: float64(i % 7)}
+	}
+	activityID, err := store.InsertActivity(ctx, Activity{
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Long Ride",
+		StartTime: start,
+	}, points)
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	loaded, err := store.LoadActivityPoints(ctx, activityID)
+	if err != nil {
+		t.Fatalf("load points: %v", err)
+	}
+	if len(loaded) != len(points) {
+		t.Fatalf("expected %d points, got %d", len(points), len(loaded))
+	}
+	for i, p := range loaded {
+		if !p.Time.Equal(points[i].Time) || p.Lat != points[i].Lat || p.Speed != points[i].Speed {
+			t.Fatalf("point %d out of order or altered: got %+v want %+v", i, p, points[i])
+		}
+	}
+}
+
+func BenchmarkInsertActivityPoints(b *testing.B) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		b.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		b.Fatalf("init schema: %v", err)
+	}
+
+	start := time.Date(2026, time.March, 24, 8, 0, 0, 0, time.UTC)
+	points := make([]gps.Point, 5000)
+	for i := range points {
+		points[i] = gps.Point{Lat: 52, Lon: 13, Time: start.Add(time.Duration(i) * time.Second), Speed: 5}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.UpsertActivity(ctx, Activity{ID: 1, UserID: 1, Type: "Ride", Name: "Bench", StartTime: start}, points); err != nil {
+			b.Fatalf("upsert: %v", err)
+		}
+	}
+}
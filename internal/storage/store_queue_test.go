@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnqueueActivityIsIdempotentWhilePending(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := store.EnqueueActivity(ctx, 42, 7); err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+	}
+	count, err := store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 queued job for the same activity, got %d", count)
+	}
+
+	if err := store.EnqueueActivity(ctx, 43, 7); err != nil {
+		t.Fatalf("enqueue other activity: %v", err)
+	}
+	count, err = store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected a separate job for another activity, got %d", count)
+	}
+}
+
+func TestPurgeFinishedJobs(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour).Unix()
+	recent := now.Add(-time.Hour).Unix()
+	rows := []struct {
+		payload   string
+		status    string
+		updatedAt int64
+	}{
+		{payload: "old-completed", status: "completed", updatedAt: old},
+		{payload: "old-failed", status: "failed", updatedAt: old},
+		{payload: "old-queued", status: "queued", updatedAt: old},
+		{payload: "old-retry", status: "retry", updatedAt: old},
+		{payload: "old-running", status: "running", updatedAt: old},
+		{payload: "recent-completed", status: "completed", updatedAt: recent},
+		{payload: "recent-queued", status: "queued", updatedAt: recent},
+	}
+	for _, row := range rows {
+		if _, err := store.db.ExecContext(ctx, `
+INSERT INTO jobs (type, status, payload, cursor, attempts, max_attempts, last_error, next_run_at, created_at, updated_at)
+VALUES ('process_activity', ?, ?, '{}', 0, 10, '', ?, ?, ?)
+`, row.status, row.payload, row.updatedAt, row.updatedAt, row.updatedAt); err != nil {
+			t.Fatalf("insert job %s: %v", row.payload, err)
+		}
+	}
+
+	purged, err := store.PurgeFinishedJobs(ctx, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected 2 purged jobs, got %d", purged)
+	}
+
+	var remaining []string
+	result, err := store.db.QueryContext(ctx, `SELECT payload FROM jobs ORDER BY id`)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	defer result.Close()
+	for result.Next() {
+		var payload string
+		if err := result.Scan(&payload); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		remaining = append(remaining, payload)
+	}
+	want := []string{"old-queued", "old-retry", "old-running", "recent-completed", "recent-queued"}
+	if len(remaining) != len(want) {
+		t.Fatalf("expected %v to remain, got %v", want, remaining)
+	}
+	for i := range want {
+		if remaining[i] != want[i] {
+			t.Fatalf("expected %v to remain, got %v", want, remaining)
+		}
+	}
+}
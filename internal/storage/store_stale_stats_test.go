@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/stats"
+)
+
+func TestListStaleStatsActivities(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	current := gps.StopOptions{SpeedThreshold: 0.5, MinDuration: 3 * time.Second, GlitchTolerance: 10 * time.Second}
+	changed := current
+	changed.SpeedThreshold = 0.8
+	if current.Hash() == changed.Hash() {
+		t.Fatalf("expected different options to hash differently")
+	}
+
+	base := time.Date(2026, time.March, 1, 7, 0, 0, 0, time.UTC)
+	for i, id := range []int64{1, 2, 3, 4} {
+		if _, err := store.InsertActivity(ctx, Activity{ID: id, UserID: 10 + id, Type: "Ride", Name: "Ride", StartTime: base.Add(time.Duration(i) * time.Hour)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if err := store.UpsertActivityStats(ctx, id, stats.StopStats{StopCount: 1, OptionsHash: current.Hash()}); err != nil {
+			t.Fatalf("upsert stats %d: %v", id, err)
+		}
+	}
+	// Activity 4 has no stats yet; a legacy row without a hash is ignored.
+	if err := store.UpsertActivityStats(ctx, 4, stats.StopStats{}); err != nil {
+		t.Fatalf("upsert legacy stats: %v", err)
+	}
+
+	stale, err := store.ListStaleStatsActivities(ctx, current.Hash())
+	if err != nil {
+		t.Fatalf("list stale: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Fatalf("expected no stale stats under the same options, got %+v", stale)
+	}
+
+	stale, err = store.ListStaleStatsActivities(ctx, changed.Hash())
+	if err != nil {
+		t.Fatalf("list stale: %v", err)
+	}
+	want := []StaleStatsActivity{{ActivityID: 3, UserID: 13}, {ActivityID: 2, UserID: 12}, {ActivityID: 1, UserID: 11}}
+	if len(stale) != len(want) {
+		t.Fatalf("expected %v, got %v", want, stale)
+	}
+	for i := range want {
+		if stale[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, stale)
+		}
+	}
+
+	got, err := store.GetActivityStats(ctx, 1)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if got.OptionsHash != current.Hash() {
+		t.Fatalf("expected options hash to round-trip, got %q", got.OptionsHash)
+	}
+}
+
+func TestListActivitiesBelowStatsVersion(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	base := time.Date(2026, time.March, 1, 7, 0, 0, 0, time.UTC)
+	// Activity 4 has no stats and counts as version 0.
+	versions := map[int64]int{1: 0, 2: 1, 3: 2}
+	for i, id := range []int64{1, 2, 3, 4} {
+		if _, err := store.InsertActivity(ctx, Activity{ID: id, UserID: 7, Type: "Ride", Name: "Ride", StartTime: base.Add(time.Duration(i) * time.Hour)}, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", id, err)
+		}
+		if version, ok := versions[id]; ok {
+			if err := store.UpsertActivityStats(ctx, id, stats.StopStats{StatsVersion: version}); err != nil {
+				t.Fatalf("upsert stats %d: %v", id, err)
+			}
+		}
+	}
+
+	cases := []struct {
+		version int
+		limit   int
+		want    []int64
+	}{
+		{version: 1, limit: 10, want: []int64{4, 1}},
+		{version: 2, limit: 10, want: []int64{4, 2, 1}},
+		{version: 3, limit: 2, want: []int64{4, 3}},
+		{version: 0, limit: 10, want: nil},
+		{version: 3, limit: 0, want: nil},
+	}
+	for _, tc := range cases {
+		got, err := store.ListActivitiesBelowStatsVersion(ctx, tc.version, tc.limit)
+		if err != nil {
+			t.Fatalf("version %d: %v", tc.version, err)
+		}
+		var ids []int64
+		for _, item := range got {
+			if item.UserID != 7 {
+				t.Fatalf("version %d: unexpected user %d", tc.version, item.UserID)
+			}
+			ids = append(ids, item.ActivityID)
+		}
+		if len(ids) != len(tc.want) {
+			t.Fatalf("version %d limit %d: expected %v, got %v", tc.version, tc.limit, tc.want, ids)
+		}
+		for i := range ids {
+			if ids[i] != tc.want[i] {
+				t.Fatalf("version %d limit %d: expected %v, got %v", tc.version, tc.limit, tc.want, ids)
+			}
+		}
+	}
+
+	got, err := store.GetActivityStats(ctx, 3)
+	if err != nil || got.StatsVersion != 2 {
+		t.Fatalf("expected stats version to round-trip, got %d (%v)", got.StatsVersion, err)
+	}
+}
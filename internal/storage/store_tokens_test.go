@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestUserIDForAthlete(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	// An env-seeded token has no athlete id yet and must not claim events
+	// from arbitrary athletes.
+	if err := store.UpsertStravaToken(ctx, StravaToken{UserID: 1, AccessToken: "seeded"}); err != nil {
+		t.Fatalf("upsert seeded token: %v", err)
+	}
+	if _, err := store.UserIDForAthlete(ctx, 555); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected lone seeded token not to match athlete 555, got %v", err)
+	}
+	// A refresh that learns the athlete id backfills it.
+	if err := store.UpsertStravaToken(ctx, StravaToken{UserID: 1, AccessToken: "refreshed", AthleteID: 555}); err != nil {
+		t.Fatalf("backfill athlete id: %v", err)
+	}
+	if userID, err := store.UserIDForAthlete(ctx, 555); err != nil || userID != 1 {
+		t.Fatalf("expected backfilled user 1, got %d (err=%v)", userID, err)
+	}
+
+	if err := store.UpsertStravaToken(ctx, StravaToken{UserID: 2, AccessToken: "oauth", AthleteID: 200}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	if userID, err := store.UserIDForAthlete(ctx, 200); err != nil || userID != 2 {
+		t.Fatalf("expected user 2, got %d (err=%v)", userID, err)
+	}
+	if _, err := store.UserIDForAthlete(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected unknown athlete, got %v", err)
+	}
+}
+
+func TestCountUsers(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	if count, err := store.CountUsers(ctx); err != nil || count != 0 {
+		t.Fatalf("expected no users, got %d (err=%v)", count, err)
+	}
+	for _, token := range []StravaToken{
+		{UserID: 2, AccessToken: "a", AthleteID: 200},
+		{UserID: 3, AccessToken: "b", AthleteID: 300},
+		// Refreshing a token must not count the user twice.
+		{UserID: 2, AccessToken: "a2", AthleteID: 200},
+	} {
+		if err := store.UpsertStravaToken(ctx, token); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+	if count, err := store.CountUsers(ctx); err != nil || count != 2 {
+		t.Fatalf("expected 2 users, got %d (err=%v)", count, err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUserSettingsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	if got, err := store.GetUserSetting(ctx, 3, "description_template"); err != nil || got != "" {
+		t.Fatalf("expected empty unset setting, got %q (err=%v)", got, err)
+	}
+	if err := store.SetUserSetting(ctx, 3, "description_template", "{{.Facts}}"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := store.SetUserSetting(ctx, 3, "description_template", "{{.StopCount}} stops"); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if got, _ := store.GetUserSetting(ctx, 3, "description_template"); got != "{{.StopCount}} stops" {
+		t.Fatalf("expected overwritten value, got %q", got)
+	}
+	if got, _ := store.GetUserSetting(ctx, 4, "description_template"); got != "" {
+		t.Fatalf("expected settings scoped per user, got %q", got)
+	}
+
+	if err := store.SetUserSetting(ctx, 3, "description_template", ""); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if got, _ := store.GetUserSetting(ctx, 3, "description_template"); got != "" {
+		t.Fatalf("expected cleared setting, got %q", got)
+	}
+}
+
+func TestClaimUserSettingCooldown(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	var claims atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := store.ClaimUserSettingCooldown(ctx, 3, "reprocess_all_at", now, 10*time.Minute)
+			if err != nil {
+				t.Errorf("claim: %v", err)
+			}
+			if claimed {
+				claims.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := claims.Load(); got != 1 {
+		t.Fatalf("expected exactly one concurrent claim, got %d", got)
+	}
+	if got, _ := store.GetUserSetting(ctx, 3, "reprocess_all_at"); got != strconv.FormatInt(now.Unix(), 10) {
+		t.Fatalf("expected claim time stored, got %q", got)
+	}
+
+	if claimed, err := store.ClaimUserSettingCooldown(ctx, 3, "reprocess_all_at", now.Add(9*time.Minute), 10*time.Minute); err != nil || claimed {
+		t.Fatalf("expected claim inside cooldown to fail, got %v (err=%v)", claimed, err)
+	}
+	if claimed, err := store.ClaimUserSettingCooldown(ctx, 3, "reprocess_all_at", now.Add(10*time.Minute), 10*time.Minute); err != nil || !claimed {
+		t.Fatalf("expected claim after cooldown to succeed, got %v (err=%v)", claimed, err)
+	}
+	if err := store.SetUserSetting(ctx, 4, "reprocess_all_at", "garbage"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if claimed, err := store.ClaimUserSettingCooldown(ctx, 4, "reprocess_all_at", now, 10*time.Minute); err != nil || !claimed {
+		t.Fatalf("expected unparsable value to count as expired, got %v (err=%v)", claimed, err)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCountWebhookEventsForObject(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	events := []WebhookEvent{
+		{ObjectID: 42, ObjectType: "activity", AspectType: "create", OwnerID: 7},
+		{ObjectID: 42, ObjectType: "activity", AspectType: "update", OwnerID: 7},
+		{ObjectID: 42, ObjectType: "activity", AspectType: "update", OwnerID: 7},
+		{ObjectID: 43, ObjectType: "activity", AspectType: "create", OwnerID: 7},
+		{ObjectID: 42, ObjectType: "athlete", AspectType: "update", OwnerID: 42},
+	}
+	for _, event := range events {
+		if _, err := store.InsertWebhookEvent(ctx, event); err != nil {
+			t.Fatalf("insert webhook event: %v", err)
+		}
+	}
+
+	cases := map[int64]int{42: 3, 43: 1, 44: 0}
+	for objectID, want := range cases {
+		got, err := store.CountWebhookEventsForObject(ctx, "activity", objectID)
+		if err != nil {
+			t.Fatalf("count events for %d: %v", objectID, err)
+		}
+		if got != want {
+			t.Fatalf("object %d: expected %d events, got %d", objectID, want, got)
+		}
+	}
+	athleteEvents, err := store.CountWebhookEventsForObject(ctx, "athlete", 42)
+	if err != nil {
+		t.Fatalf("count athlete events: %v", err)
+	}
+	if athleteEvents != 1 {
+		t.Fatalf("expected 1 athlete event for 42, got %d", athleteEvents)
+	}
+}
+
+func TestInsertWebhookEventDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	cases := []struct {
+		event WebhookEvent
+		want  bool
+	}{
+		{WebhookEvent{ObjectID: 42, ObjectType: "activity", AspectType: "update", OwnerID: 7, EventTime: 1700000000}, true},
+		{WebhookEvent{ObjectID: 42, ObjectType: "activity", AspectType: "update", OwnerID: 7, EventTime: 1700000000}, false},
+		{WebhookEvent{ObjectID: 42, ObjectType: "athlete", AspectType: "update", OwnerID: 42, EventTime: 1700000000}, true},
+		{WebhookEvent{ObjectID: 42, ObjectType: "activity", AspectType: "update", OwnerID: 7, EventTime: 1700000001}, true},
+		// Events without event_time cannot be told apart and are always kept.
+		{WebhookEvent{ObjectID: 42, ObjectType: "activity", AspectType: "create", OwnerID: 7}, true},
+		{WebhookEvent{ObjectID: 42, ObjectType: "activity", AspectType: "create", OwnerID: 7}, true},
+	}
+	for i, tc := range cases {
+		got, err := store.InsertWebhookEvent(ctx, tc.event)
+		if err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+		if got != tc.want {
+			t.Fatalf("insert %d: expected inserted=%v, got %v", i, tc.want, got)
+		}
+	}
+	count, err := store.CountWebhookEvents(ctx)
+	if err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if count != 5 {
+		t.Fatalf("expected 5 stored events, got %d", count)
+	}
+}
+
+func TestPurgeWebhookEvents(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+
+	now := time.Now()
+	for i, receivedAt := range []time.Time{now.Add(-40 * 24 * time.Hour), now.Add(-31 * 24 * time.Hour), now.Add(-time.Hour)} {
+		if _, err := store.InsertWebhookEvent(ctx, WebhookEvent{ObjectID: int64(i + 1), ObjectType: "activity", AspectType: "create", OwnerID: 7, ReceivedAt: receivedAt}); err != nil {
+			t.Fatalf("insert webhook event: %v", err)
+		}
+	}
+
+	purged, err := store.PurgeWebhookEvents(ctx, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected 2 purged events, got %d", purged)
+	}
+	events, err := store.ListWebhookEvents(ctx, 7, time.Unix(0, 0), 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].ObjectID != 3 {
+		t.Fatalf("expected only the recent event to remain, got %+v", events)
+	}
+}
@@ -12,11 +12,18 @@ import (
 	"time"
 )
 
+// DefaultUserAgent identifies weirdstats to Strava when no UserAgent is set.
+const DefaultUserAgent = "weirdstats/1.0 (+https://github.com/ptmt/weirdstats)"
+
 type Client struct {
 	BaseURL     string
 	AccessToken string
 	TokenSource TokenSource
 	HTTPClient  *http.Client
+	RateLimits  *RateLimitTracker
+	UserAgent   string
+
+	rateLimits RateLimitTracker
 }
 
 type APIError struct {
@@ -180,23 +187,32 @@ type Activity struct {
 	ID               int64
 	Name             string
 	Type             string
+	SportType        string
 	StartDate        time.Time
 	Description      string
 	Distance         float64
 	MovingTime       int
+	ElapsedTime      int
 	AveragePower     float64
 	AverageHeartRate float64
 	Visibility       string
 	Private          bool
 	HideFromHome     bool
 	PhotoURL         string
+	GearID           string
+	Commute          bool
+	SummaryPolyline  string
 }
 
 type ActivitySummary struct {
-	ID        int64
-	Name      string
-	Type      string
-	StartDate time.Time
+	ID         int64
+	Name       string
+	Type       string
+	SportType  string
+	StartDate  time.Time
+	Distance   float64
+	MovingTime int
+	Commute    bool
 }
 
 type StreamSet struct {
@@ -206,6 +222,7 @@ type StreamSet struct {
 	Watts          []float64
 	GradeSmooth    []float64
 	Heartrate      []float64
+	Altitude       []float64
 }
 
 type UpdateActivityRequest struct {
@@ -218,16 +235,23 @@ func (c *Client) GetActivity(ctx context.Context, id int64) (Activity, error) {
 		ID               int64    `json:"id"`
 		Name             string   `json:"name"`
 		Type             string   `json:"type"`
+		SportType        string   `json:"sport_type"`
 		StartDate        string   `json:"start_date"`
 		Description      string   `json:"description"`
 		Distance         float64  `json:"distance"`
 		MovingTime       int      `json:"moving_time"`
+		ElapsedTime      int      `json:"elapsed_time"`
 		AverageWatts     float64  `json:"average_watts"`
 		AverageHeartrate *float64 `json:"average_heartrate"`
 		Visibility       string   `json:"visibility"`
 		Private          bool     `json:"private"`
 		HideFromHome     bool     `json:"hide_from_home"`
-		Photos           *struct {
+		GearID           *string  `json:"gear_id"`
+		Commute          bool     `json:"commute"`
+		Map              *struct {
+			SummaryPolyline string `json:"summary_polyline"`
+		} `json:"map"`
+		Photos *struct {
 			Primary *struct {
 				URLs map[string]string `json:"urls"`
 			} `json:"primary"`
@@ -248,6 +272,16 @@ func (c *Client) GetActivity(ctx context.Context, id int64) (Activity, error) {
 		avgHR = *payload.AverageHeartrate
 	}
 
+	gearID := ""
+	if payload.GearID != nil {
+		gearID = strings.TrimSpace(*payload.GearID)
+	}
+
+	var summaryPolyline string
+	if payload.Map != nil {
+		summaryPolyline = payload.Map.SummaryPolyline
+	}
+
 	var photoURL string
 	if payload.Photos != nil && payload.Photos.Primary != nil {
 		for _, size := range []string{"600", "400", "200", "100"} {
@@ -268,19 +302,32 @@ func (c *Client) GetActivity(ctx context.Context, id int64) (Activity, error) {
 		ID:               payload.ID,
 		Name:             payload.Name,
 		Type:             payload.Type,
+		SportType:        payload.SportType,
 		StartDate:        start,
 		Description:      payload.Description,
 		Distance:         payload.Distance,
 		MovingTime:       payload.MovingTime,
+		ElapsedTime:      payload.ElapsedTime,
 		AveragePower:     payload.AverageWatts,
 		AverageHeartRate: avgHR,
 		Visibility:       payload.Visibility,
 		Private:          payload.Private,
 		HideFromHome:     payload.HideFromHome,
 		PhotoURL:         photoURL,
+		GearID:           gearID,
+		Commute:          payload.Commute,
+		SummaryPolyline:  summaryPolyline,
 	}, nil
 }
 
+// UpdateActivityDescription replaces the activity's Strava description. It
+// needs a token granted the activity:write scope.
+func (c *Client) UpdateActivityDescription(ctx context.Context, id int64, description string) (Activity, error) {
+	return c.UpdateActivity(ctx, id, UpdateActivityRequest{Description: &description})
+}
+
+// UpdateActivity PUTs the fields set in update to /activities/{id}. It needs
+// a token granted the activity:write scope.
 func (c *Client) UpdateActivity(ctx context.Context, id int64, update UpdateActivityRequest) (Activity, error) {
 	if id == 0 {
 		return Activity{}, fmt.Errorf("activity id required")
@@ -305,12 +352,10 @@ func (c *Client) UpdateActivity(ctx context.Context, id int64, update UpdateActi
 		return Activity{}, err
 	}
 
-	logRequest(http.MethodPut, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, strings.NewReader(form.Encode()))
+	req, err := newRequest(ctx, http.MethodPut, endpoint, form, c.UserAgent)
 	if err != nil {
 		return Activity{}, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	token := c.AccessToken
 	if token == "" && c.TokenSource != nil {
@@ -333,6 +378,7 @@ func (c *Client) UpdateActivity(ctx context.Context, id int64, update UpdateActi
 		return Activity{}, err
 	}
 	defer resp.Body.Close()
+	c.observeRateLimit(resp.Header)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
@@ -361,7 +407,7 @@ func (c *Client) UpdateActivity(ctx context.Context, id int64, update UpdateActi
 
 func (c *Client) GetStreams(ctx context.Context, id int64) (StreamSet, error) {
 	params := url.Values{}
-	params.Set("keys", "latlng,time,velocity_smooth,watts,grade_smooth,heartrate")
+	params.Set("keys", "latlng,time,velocity_smooth,watts,grade_smooth,heartrate,altitude")
 	params.Set("key_by_type", "true")
 
 	var payload map[string]struct {
@@ -424,10 +470,33 @@ func (c *Client) GetStreams(ctx context.Context, id int64) (StreamSet, error) {
 		streams.Heartrate = append(streams.Heartrate, v)
 	}
 
+	for _, entry := range payload["altitude"].Data {
+		var v float64
+		if err := json.Unmarshal(entry, &v); err != nil {
+			return StreamSet{}, fmt.Errorf("parse altitude: %w", err)
+		}
+		streams.Altitude = append(streams.Altitude, v)
+	}
+
 	return streams, nil
 }
 
+// ActivityPage is one page of /athlete/activities. HasMore is set when the
+// page came back full, which is the only signal Strava gives for pagination.
+type ActivityPage struct {
+	Activities []ActivitySummary
+	HasMore    bool
+}
+
 func (c *Client) ListActivities(ctx context.Context, after, before time.Time, page, perPage int) ([]ActivitySummary, error) {
+	result, err := c.ListActivitiesPage(ctx, after, before, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return result.Activities, nil
+}
+
+func (c *Client) ListActivitiesPage(ctx context.Context, after, before time.Time, page, perPage int) (ActivityPage, error) {
 	params := url.Values{}
 	if !after.IsZero() {
 		params.Set("after", fmt.Sprintf("%d", after.Unix()))
@@ -443,31 +512,42 @@ func (c *Client) ListActivities(ctx context.Context, after, before time.Time, pa
 	}
 
 	var payload []struct {
-		ID        int64  `json:"id"`
-		Name      string `json:"name"`
-		Type      string `json:"type"`
-		StartDate string `json:"start_date"`
+		ID         int64   `json:"id"`
+		Name       string  `json:"name"`
+		Type       string  `json:"type"`
+		SportType  string  `json:"sport_type"`
+		StartDate  string  `json:"start_date"`
+		Distance   float64 `json:"distance"`
+		MovingTime int     `json:"moving_time"`
+		Commute    bool    `json:"commute"`
 	}
 
 	if err := c.getJSON(ctx, "/athlete/activities", params, &payload); err != nil {
-		return nil, err
+		return ActivityPage{}, err
 	}
 
 	activities := make([]ActivitySummary, 0, len(payload))
 	for _, p := range payload {
 		start, err := time.Parse(time.RFC3339, p.StartDate)
 		if err != nil {
-			return nil, fmt.Errorf("parse start_date: %w", err)
+			return ActivityPage{}, fmt.Errorf("parse start_date: %w", err)
 		}
 		activities = append(activities, ActivitySummary{
-			ID:        p.ID,
-			Name:      p.Name,
-			Type:      p.Type,
-			StartDate: start,
+			ID:         p.ID,
+			Name:       p.Name,
+			Type:       p.Type,
+			SportType:  p.SportType,
+			StartDate:  start,
+			Distance:   p.Distance,
+			MovingTime: p.MovingTime,
+			Commute:    p.Commute,
 		})
 	}
 
-	return activities, nil
+	return ActivityPage{
+		Activities: activities,
+		HasMore:    perPage > 0 && len(payload) >= perPage,
+	}, nil
 }
 
 func (c *Client) getJSON(ctx context.Context, path string, params url.Values, target interface{}) error {
@@ -489,8 +569,7 @@ func (c *Client) getJSON(ctx context.Context, path string, params url.Values, ta
 		u.RawQuery = params.Encode()
 	}
 
-	logRequest(http.MethodGet, u.String())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := newRequest(ctx, http.MethodGet, u.String(), nil, c.UserAgent)
 	if err != nil {
 		return err
 	}
@@ -515,6 +594,7 @@ func (c *Client) getJSON(ctx context.Context, path string, params url.Values, ta
 		return err
 	}
 	defer resp.Body.Close()
+	c.observeRateLimit(resp.Header)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
@@ -527,3 +607,30 @@ func (c *Client) getJSON(ctx context.Context, path string, params url.Values, ta
 
 	return nil
 }
+
+// newRequest builds and logs a Strava request with the User-Agent set, so
+// every endpoint identifies weirdstats the same way. A non-nil form is sent
+// as an urlencoded body.
+func newRequest(ctx context.Context, method, endpoint string, form url.Values, userAgent string) (*http.Request, error) {
+	logRequest(method, endpoint)
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	setUserAgent(req, userAgent)
+	return req, nil
+}
+
+func setUserAgent(req *http.Request, userAgent string) {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+}
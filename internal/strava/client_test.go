@@ -2,9 +2,12 @@ package strava
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestClientGetsActivityAndStreams(t *testing.T) {
@@ -57,3 +60,294 @@ func TestClientGetsActivityAndStreams(t *testing.T) {
 		t.Fatalf("unexpected heartrate stream: %#v", streams.Heartrate)
 	}
 }
+
+func TestClientTracksRateLimitUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		w.Header().Set("X-RateLimit-Usage", "170,900")
+		_, _ = w.Write([]byte(`{"id":123,"name":"Test Ride","type":"Ride","start_date":"2024-01-01T10:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	tracker := &RateLimitTracker{}
+	client := &Client{BaseURL: server.URL, AccessToken: "token", RateLimits: tracker}
+	if _, ok := client.RateLimitUsage(); ok {
+		t.Fatalf("expected no usage before the first request")
+	}
+	if _, err := client.GetActivity(context.Background(), 123); err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+
+	info, ok := client.RateLimitUsage()
+	if !ok {
+		t.Fatalf("expected rate limit usage after request")
+	}
+	if info.UsageShort != 170 || info.LimitShort != 200 || info.UsageLong != 900 || info.LimitLong != 2000 {
+		t.Fatalf("unexpected rate limit info: %+v", info)
+	}
+	if ratio, ok := info.ShortUsageRatio(); !ok || ratio != 0.85 {
+		t.Fatalf("expected short usage ratio 0.85, got %v (%t)", ratio, ok)
+	}
+	if shared, ok := tracker.Latest(); !ok || shared.UsageShort != 170 {
+		t.Fatalf("expected shared tracker to be updated, got %+v", shared)
+	}
+}
+
+func TestRateLimitTrackerExpiresAtWindowBoundary(t *testing.T) {
+	now := time.Date(2026, time.May, 1, 10, 14, 0, 0, time.UTC)
+	tracker := &RateLimitTracker{now: func() time.Time { return now }}
+	tracker.Observe(RateLimitInfo{UsageShort: 190, LimitShort: 200, UsageLong: 900, LimitLong: 2000})
+
+	now = now.Add(50 * time.Second)
+	if info, ok := tracker.Latest(); !ok || info.UsageShort != 190 {
+		t.Fatalf("expected reading within the same window, got %+v (%t)", info, ok)
+	}
+
+	now = now.Add(20 * time.Second)
+	if info, ok := tracker.Latest(); ok {
+		t.Fatalf("expected reading from the previous window to be ignored, got %+v", info)
+	}
+}
+
+func TestClientReturnsRateLimitedAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "200,2000")
+		w.Header().Set("X-RateLimit-Usage", "201,950")
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"Rate Limit Exceeded"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	_, err := client.GetActivity(context.Background(), 123)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", apiErr.StatusCode)
+	}
+	if apiErr.RateLimit.UsageShort != 201 || apiErr.RateLimit.LimitShort != 200 {
+		t.Fatalf("unexpected rate limit info: %+v", apiErr.RateLimit)
+	}
+	if !IsRateLimited(err) {
+		t.Fatalf("expected IsRateLimited to be true")
+	}
+	backoff, ok := RateLimitBackoff(err)
+	if !ok || backoff != 2*time.Minute {
+		t.Fatalf("expected 2m backoff, got %s (%t)", backoff, ok)
+	}
+}
+
+func TestClientListActivitiesPageReportsHasMore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("per_page") != "2" {
+			t.Fatalf("unexpected per_page: %q", r.URL.Query().Get("per_page"))
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			_, _ = w.Write([]byte(`[{"id":1,"name":"A","type":"Ride","start_date":"2024-01-01T10:00:00Z"},{"id":2,"name":"B","type":"Ride","start_date":"2024-01-02T10:00:00Z"}]`))
+		default:
+			_, _ = w.Write([]byte(`[{"id":3,"name":"C","type":"Run","start_date":"2024-01-03T10:00:00Z"}]`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	first, err := client.ListActivitiesPage(context.Background(), time.Time{}, time.Time{}, 1, 2)
+	if err != nil {
+		t.Fatalf("list page 1: %v", err)
+	}
+	if len(first.Activities) != 2 || !first.HasMore {
+		t.Fatalf("expected full first page with more, got %d activities has_more=%t", len(first.Activities), first.HasMore)
+	}
+	second, err := client.ListActivitiesPage(context.Background(), time.Time{}, time.Time{}, 2, 2)
+	if err != nil {
+		t.Fatalf("list page 2: %v", err)
+	}
+	if len(second.Activities) != 1 || second.HasMore {
+		t.Fatalf("expected short last page, got %d activities has_more=%t", len(second.Activities), second.HasMore)
+	}
+}
+
+func TestClientListActivitiesParsesSummaryDetail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":7,"name":"Commute","type":"Ride","start_date":"2024-01-01T08:00:00Z","distance":8421.5,"moving_time":1260,"commute":true},{"id":8,"name":"Walk","type":"Walk","start_date":"2024-01-01T18:00:00Z"}]`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	activities, err := client.ListActivities(context.Background(), time.Time{}, time.Time{}, 1, 10)
+	if err != nil {
+		t.Fatalf("list activities: %v", err)
+	}
+	if len(activities) != 2 {
+		t.Fatalf("expected 2 activities, got %d", len(activities))
+	}
+	if got := activities[0]; got.Distance != 8421.5 || got.MovingTime != 1260 || !got.Commute {
+		t.Fatalf("unexpected summary detail: %+v", got)
+	}
+	if got := activities[1]; got.Distance != 0 || got.MovingTime != 0 || got.Commute {
+		t.Fatalf("expected zero detail when fields are missing, got %+v", got)
+	}
+}
+
+func TestClientParsesSportType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/activities/9":
+			_, _ = w.Write([]byte(`{"id":9,"name":"Trails","type":"Ride","sport_type":"MountainBikeRide","start_date":"2024-01-01T08:00:00Z"}`))
+		case "/athlete/activities":
+			_, _ = w.Write([]byte(`[{"id":9,"name":"Trails","type":"Ride","sport_type":"MountainBikeRide","start_date":"2024-01-01T08:00:00Z"},{"id":10,"name":"Old","type":"Run","start_date":"2024-01-02T08:00:00Z"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	activity, err := client.GetActivity(context.Background(), 9)
+	if err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	if activity.Type != "Ride" || activity.SportType != "MountainBikeRide" {
+		t.Fatalf("expected type Ride and sport type MountainBikeRide, got %q/%q", activity.Type, activity.SportType)
+	}
+
+	summaries, err := client.ListActivities(context.Background(), time.Time{}, time.Time{}, 1, 10)
+	if err != nil {
+		t.Fatalf("list activities: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].SportType != "MountainBikeRide" || summaries[1].SportType != "" || summaries[1].Type != "Run" {
+		t.Fatalf("unexpected summaries: %+v", summaries)
+	}
+}
+
+func TestClientUpdateActivitySendsOnlySetFields(t *testing.T) {
+	var gotMethod, gotContentType, gotDescription string
+	var gotFields int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/activities/77" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotMethod = r.Method
+		gotContentType = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		gotFields = len(r.PostForm)
+		gotDescription = r.PostForm.Get("description")
+		_, _ = w.Write([]byte(`{"id":77,"name":"Ride","type":"Ride","start_date":"2024-01-01T10:00:00Z","description":"Lunch loop\n\n3 stops"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL + "/api", AccessToken: "token"}
+	description := "Lunch loop\n\n3 stops"
+	activity, err := client.UpdateActivity(context.Background(), 77, UpdateActivityRequest{Description: &description})
+	if err != nil {
+		t.Fatalf("update description: %v", err)
+	}
+	if gotMethod != http.MethodPut {
+		t.Fatalf("expected PUT, got %s", gotMethod)
+	}
+	if gotContentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("unexpected content type %q", gotContentType)
+	}
+	if gotFields != 1 || gotDescription != "Lunch loop\n\n3 stops" {
+		t.Fatalf("expected only the description in the PUT body, got %d fields, description %q", gotFields, gotDescription)
+	}
+	if activity.Description != "Lunch loop\n\n3 stops" {
+		t.Fatalf("unexpected returned description %q", activity.Description)
+	}
+}
+
+func TestClientUpdateActivityDescriptionSendsPut(t *testing.T) {
+	var gotMethod string
+	var gotForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		gotForm = r.PostForm
+		_, _ = w.Write([]byte(`{"id":77,"name":"Ride","type":"Ride","start_date":"2024-01-01T10:00:00Z","description":"3 stops"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	activity, err := client.UpdateActivityDescription(context.Background(), 77, "3 stops")
+	if err != nil {
+		t.Fatalf("update description: %v", err)
+	}
+	if gotMethod != http.MethodPut || len(gotForm) != 1 || gotForm.Get("description") != "3 stops" {
+		t.Fatalf("expected a PUT with only the description, got %s %v", gotMethod, gotForm)
+	}
+	if activity.Description != "3 stops" {
+		t.Fatalf("unexpected returned description %q", activity.Description)
+	}
+}
+
+func TestClientSendsUserAgent(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte(`{"id":77,"name":"Ride","type":"Ride","start_date":"2024-01-01T10:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, AccessToken: "token"}
+	if _, err := client.GetActivity(context.Background(), 77); err != nil {
+		t.Fatalf("get activity: %v", err)
+	}
+	client.UserAgent = "weirdstats-test/2.0"
+	description := "3 stops"
+	if _, err := client.UpdateActivity(context.Background(), 77, UpdateActivityRequest{Description: &description}); err != nil {
+		t.Fatalf("update description: %v", err)
+	}
+	if len(agents) != 2 || agents[0] != DefaultUserAgent || agents[1] != "weirdstats-test/2.0" {
+		t.Fatalf("unexpected user agents %q", agents)
+	}
+}
+
+func TestOAuthAndWebhookRequestsSendUserAgent(t *testing.T) {
+	agents := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents[r.Method+" "+r.URL.Path] = r.Header.Get("User-Agent")
+		switch {
+		case r.URL.Path == "/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token":"a","refresh_token":"r","expires_at":1}`))
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`[]`))
+		case r.Method == http.MethodPost:
+			_, _ = w.Write([]byte(`{"id":9}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	if _, err := ExchangeAuthorizationCode(ctx, server.URL, "id", "secret", "code", "weirdstats-test/3.0", nil); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	webhooks := &WebhookClient{BaseURL: server.URL, ClientID: "id", ClientSecret: "secret", UserAgent: "weirdstats-test/3.0"}
+	if _, err := webhooks.ListSubscriptions(ctx); err != nil {
+		t.Fatalf("list subscriptions: %v", err)
+	}
+	if _, err := webhooks.CreateSubscription(ctx, "https://example.com/webhook", "verify"); err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	if err := webhooks.DeleteSubscription(ctx, 9); err != nil {
+		t.Fatalf("delete subscription: %v", err)
+	}
+
+	for _, key := range []string{"POST /oauth/token", "GET /push_subscriptions", "POST /push_subscriptions", "DELETE /push_subscriptions/9"} {
+		if got := agents[key]; got != "weirdstats-test/3.0" {
+			t.Fatalf("%s: expected custom user agent, got %q", key, got)
+		}
+	}
+}
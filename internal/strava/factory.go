@@ -15,6 +15,8 @@ type ClientFactory struct {
 	ClientID     string
 	ClientSecret string
 	HTTPClient   *http.Client
+	RateLimits   *RateLimitTracker
+	UserAgent    string
 }
 
 func (f *ClientFactory) ClientForUser(ctx context.Context, userID int64) (*Client, error) {
@@ -36,6 +38,8 @@ func (f *ClientFactory) ClientForUser(ctx context.Context, userID int64) (*Clien
 	client := &Client{
 		BaseURL:    f.BaseURL,
 		HTTPClient: f.HTTPClient,
+		RateLimits: f.RateLimits,
+		UserAgent:  f.UserAgent,
 	}
 	if f.ClientID != "" && f.ClientSecret != "" && token.RefreshToken != "" {
 		client.TokenSource = &RefreshTokenSource{
@@ -44,7 +48,9 @@ func (f *ClientFactory) ClientForUser(ctx context.Context, userID int64) (*Clien
 			ClientID:     f.ClientID,
 			ClientSecret: f.ClientSecret,
 			BaseURL:      f.AuthBaseURL,
+			APIBaseURL:   f.BaseURL,
 			HTTPClient:   f.HTTPClient,
+			UserAgent:    f.UserAgent,
 		}
 		return client, nil
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -24,6 +25,10 @@ type RefreshTokenSource struct {
 	ClientSecret string
 	BaseURL      string
 	HTTPClient   *http.Client
+	UserAgent    string
+	// APIBaseURL is used to look up the athlete for tokens stored without an
+	// athlete ID. Defaults to the public Strava API.
+	APIBaseURL string
 }
 
 type Athlete struct {
@@ -45,7 +50,7 @@ type refreshResponse struct {
 	ExpiresAt    int64  `json:"expires_at"`
 }
 
-func ExchangeAuthorizationCode(ctx context.Context, baseURL, clientID, clientSecret, code string, httpClient *http.Client) (TokenResponse, error) {
+func ExchangeAuthorizationCode(ctx context.Context, baseURL, clientID, clientSecret, code, userAgent string, httpClient *http.Client) (TokenResponse, error) {
 	if clientID == "" || clientSecret == "" {
 		return TokenResponse{}, fmt.Errorf("missing strava client credentials")
 	}
@@ -69,12 +74,10 @@ func ExchangeAuthorizationCode(ctx context.Context, baseURL, clientID, clientSec
 	form.Set("grant_type", "authorization_code")
 	form.Set("code", code)
 
-	logRequest(http.MethodPost, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	req, err := newRequest(ctx, http.MethodPost, endpoint, form, userAgent)
 	if err != nil {
 		return TokenResponse{}, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	client := httpClient
 	if client == nil {
@@ -134,18 +137,47 @@ func (s *RefreshTokenSource) GetAccessToken(ctx context.Context) (string, error)
 		updated.RefreshToken = token.RefreshToken
 	}
 
-	if err := s.Store.UpsertStravaToken(ctx, storage.StravaToken{
+	refreshed := storage.StravaToken{
 		UserID:       token.UserID,
 		AccessToken:  updated.AccessToken,
 		RefreshToken: updated.RefreshToken,
 		ExpiresAt:    time.Unix(updated.ExpiresAt, 0),
-	}); err != nil {
+	}
+	if token.AthleteID == 0 {
+		// Tokens seeded from env never saw an OAuth exchange, so webhooks
+		// cannot be routed to them until the athlete is known.
+		athlete, err := s.fetchAthlete(ctx, updated.AccessToken)
+		if err != nil {
+			log.Printf("strava: athlete lookup for user %d failed: %v", token.UserID, err)
+		} else {
+			refreshed.AthleteID = athlete.ID
+			refreshed.AthleteName = strings.TrimSpace(athlete.FirstName + " " + athlete.LastName)
+		}
+	}
+	if err := s.Store.UpsertStravaToken(ctx, refreshed); err != nil {
 		return "", err
 	}
 
 	return updated.AccessToken, nil
 }
 
+func (s *RefreshTokenSource) fetchAthlete(ctx context.Context, accessToken string) (Athlete, error) {
+	client := &Client{
+		BaseURL:     s.APIBaseURL,
+		AccessToken: accessToken,
+		HTTPClient:  s.HTTPClient,
+		UserAgent:   s.UserAgent,
+	}
+	var athlete Athlete
+	if err := client.getJSON(ctx, "/athlete", nil, &athlete); err != nil {
+		return Athlete{}, err
+	}
+	if athlete.ID == 0 {
+		return Athlete{}, fmt.Errorf("athlete response missing id")
+	}
+	return athlete, nil
+}
+
 func (s *RefreshTokenSource) refresh(ctx context.Context, refreshToken string) (refreshResponse, error) {
 	if s.ClientID == "" || s.ClientSecret == "" {
 		return refreshResponse{}, fmt.Errorf("missing strava client credentials")
@@ -167,12 +199,10 @@ func (s *RefreshTokenSource) refresh(ctx context.Context, refreshToken string) (
 	form.Set("grant_type", "refresh_token")
 	form.Set("refresh_token", refreshToken)
 
-	logRequest(http.MethodPost, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	req, err := newRequest(ctx, http.MethodPost, endpoint, form, s.UserAgent)
 	if err != nil {
 		return refreshResponse{}, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	client := s.HTTPClient
 	if client == nil {
@@ -64,3 +64,52 @@ func TestRefreshTokenSource(t *testing.T) {
 		t.Fatalf("unexpected stored tokens: %+v", stored)
 	}
 }
+
+func TestRefreshTokenSourceBackfillsAthleteID(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{
+		UserID:       1,
+		RefreshToken: "refresh-1",
+		ExpiresAt:    time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("seed token: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","expires_at":4102444800}`))
+		case "/api/v3/athlete":
+			if got := r.Header.Get("Authorization"); got != "Bearer access-2" {
+				t.Errorf("unexpected authorization %q", got)
+			}
+			_, _ = w.Write([]byte(`{"id":555,"firstname":"Sam","lastname":"Rider"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &RefreshTokenSource{
+		Store:        store,
+		UserID:       1,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		BaseURL:      server.URL,
+		APIBaseURL:   server.URL + "/api/v3",
+	}
+	if _, err := source.GetAccessToken(ctx); err != nil {
+		t.Fatalf("get access token: %v", err)
+	}
+	if userID, err := store.UserIDForAthlete(ctx, 555); err != nil || userID != 1 {
+		t.Fatalf("expected athlete 555 to map to user 1, got %d (err=%v)", userID, err)
+	}
+}
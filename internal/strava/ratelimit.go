@@ -0,0 +1,79 @@
+package strava
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow is Strava's short rate-limit window. Windows start on the
+// quarter hour, and usage resets at each boundary.
+const rateLimitWindow = 15 * time.Minute
+
+// RateLimitTracker keeps the most recent rate-limit headers seen by Strava
+// clients. Strava limits are per application, so one tracker is usually
+// shared by every client the process creates.
+type RateLimitTracker struct {
+	mu         sync.Mutex
+	latest     RateLimitInfo
+	observedAt time.Time
+	seen       bool
+	now        func() time.Time
+}
+
+func (t *RateLimitTracker) Observe(info RateLimitInfo) {
+	if t == nil || !info.HasData() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.latest = info
+	t.observedAt = t.clock()
+	t.seen = true
+}
+
+// Latest returns the most recent reading, or false if none was observed in
+// the current 15-minute window: an older reading says nothing about usage
+// after Strava reset the counter.
+func (t *RateLimitTracker) Latest() (RateLimitInfo, bool) {
+	if t == nil {
+		return RateLimitInfo{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.seen || t.observedAt.Before(t.clock().Truncate(rateLimitWindow)) {
+		return RateLimitInfo{}, false
+	}
+	return t.latest, true
+}
+
+func (t *RateLimitTracker) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// ShortUsageRatio returns usage/limit for the 15-minute window.
+func (r RateLimitInfo) ShortUsageRatio() (float64, bool) {
+	if r.UsageShort < 0 || r.LimitShort <= 0 {
+		return 0, false
+	}
+	return float64(r.UsageShort) / float64(r.LimitShort), true
+}
+
+// RateLimitUsage returns the rate-limit headers from the latest Strava response.
+func (c *Client) RateLimitUsage() (RateLimitInfo, bool) {
+	return c.rateLimitTracker().Latest()
+}
+
+func (c *Client) observeRateLimit(headers http.Header) {
+	c.rateLimitTracker().Observe(parseRateLimitInfo(headers))
+}
+
+func (c *Client) rateLimitTracker() *RateLimitTracker {
+	if c.RateLimits != nil {
+		return c.RateLimits
+	}
+	return &c.rateLimits
+}
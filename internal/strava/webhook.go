@@ -36,6 +36,7 @@ type WebhookClient struct {
 	ClientID     string
 	ClientSecret string
 	HTTPClient   *http.Client
+	UserAgent    string
 }
 
 func (c *WebhookClient) EnsureSubscription(ctx context.Context, callbackURL, verifyToken string, replace bool) (SubscriptionAction, *Subscription, error) {
@@ -94,8 +95,7 @@ func (c *WebhookClient) ListSubscriptions(ctx context.Context) ([]Subscription,
 		return nil, err
 	}
 
-	logRequest(http.MethodGet, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	req, err := newRequest(ctx, http.MethodGet, endpoint, nil, c.UserAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -139,12 +139,10 @@ func (c *WebhookClient) CreateSubscription(ctx context.Context, callbackURL, ver
 	form.Set("callback_url", callbackURL)
 	form.Set("verify_token", verifyToken)
 
-	logRequest(http.MethodPost, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	req, err := newRequest(ctx, http.MethodPost, endpoint, form, c.UserAgent)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := c.httpClient().Do(req)
 	if err != nil {
@@ -183,8 +181,7 @@ func (c *WebhookClient) DeleteSubscription(ctx context.Context, id int64) error
 		return err
 	}
 
-	logRequest(http.MethodDelete, endpoint)
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	req, err := newRequest(ctx, http.MethodDelete, endpoint, nil, c.UserAgent)
 	if err != nil {
 		return err
 	}
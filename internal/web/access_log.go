@@ -0,0 +1,83 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Access log verbosity levels accepted by AccessLog.
+const (
+	AccessLogOff    = "off"
+	AccessLogErrors = "errors"
+	AccessLogAll    = "all"
+)
+
+// AccessLog logs method, path, status and duration for each request. With
+// level "errors" only responses with status >= 400 are logged. Health probes
+// are never logged.
+func AccessLog(next http.Handler, level string, logf func(format string, args ...interface{})) http.Handler {
+	if level == AccessLogOff {
+		return next
+	}
+	if logf == nil {
+		logf = log.Printf
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		status := rec.statusCode()
+		if level == AccessLogErrors && status < http.StatusBadRequest {
+			return
+		}
+		logf("%s", formatAccessLog(r.Method, r.URL.Path, status, time.Since(start)))
+	})
+}
+
+func formatAccessLog(method, path string, status int, duration time.Duration) string {
+	return strings.Join([]string{
+		"[http]",
+		fmt.Sprintf("method=%s", method),
+		fmt.Sprintf("path=%s", path),
+		fmt.Sprintf("status=%d", status),
+		fmt.Sprintf("duration=%s", duration.Round(time.Microsecond)),
+	}, " ")
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog_RecordsHandlerStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/implicit", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	var lines []string
+	logf := func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	tests := []struct {
+		level string
+		path  string
+		want  string
+	}{
+		{level: AccessLogAll, path: "/created", want: "method=POST path=/created status=201"},
+		{level: AccessLogAll, path: "/implicit", want: "method=POST path=/implicit status=200"},
+		{level: AccessLogAll, path: "/missing", want: "method=POST path=/missing status=404"},
+		{level: AccessLogAll, path: "/healthz", want: ""},
+		{level: AccessLogErrors, path: "/created", want: ""},
+		{level: AccessLogErrors, path: "/missing", want: "method=POST path=/missing status=404"},
+		{level: AccessLogOff, path: "/missing", want: ""},
+	}
+	for _, tt := range tests {
+		lines = nil
+		rec := httptest.NewRecorder()
+		AccessLog(mux, tt.level, logf).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+		if tt.want == "" {
+			if len(lines) != 0 {
+				t.Fatalf("%s %s: expected no log, got %q", tt.level, tt.path, lines)
+			}
+			continue
+		}
+		if len(lines) != 1 || !strings.Contains(lines[0], tt.want) {
+			t.Fatalf("%s %s: expected log containing %q, got %q", tt.level, tt.path, tt.want, lines)
+		}
+		if !strings.Contains(lines[0], fmt.Sprintf("status=%d", rec.Code)) {
+			t.Fatalf("logged status %q does not match response code %d", lines[0], rec.Code)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"weirdstats/internal/storage"
+)
+
+func csrfSettingsPost(t *testing.T, server *Server, userID int64, token func(*http.Request) string) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionRec := httptest.NewRecorder()
+	if err := server.setSession(sessionRec, httptest.NewRequest(http.MethodGet, "/", nil), userID); err != nil {
+		t.Fatalf("set session: %v", err)
+	}
+	probe := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range sessionRec.Result().Cookies() {
+		probe.AddCookie(cookie)
+	}
+
+	form := url.Values{}
+	form.Set("action", "delete-account")
+	form.Set("confirm", "delete")
+	form.Set(csrfFieldName, token(probe))
+	req := httptest.NewRequest(http.MethodPost, "/activities/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range sessionRec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.Settings(rec, req)
+	return rec
+}
+
+func TestSettingsPost_RequiresCSRFToken(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "csrf-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 11, AccessToken: "token", AthleteID: 11}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	other, _ := newSessionTestServer(t, "other-secret", "")
+	for name, token := range map[string]func(*http.Request) string{
+		"missing":      func(*http.Request) string { return "" },
+		"wrong secret": other.csrfToken,
+		"garbage":      func(*http.Request) string { return "not-a-token" },
+	} {
+		rec := csrfSettingsPost(t, server, 11, token)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s token: expected 403, got %d", name, rec.Code)
+		}
+	}
+	if _, err := store.GetStravaToken(ctx, 11); err != nil {
+		t.Fatalf("expected account to survive rejected posts: %v", err)
+	}
+
+	rec := csrfSettingsPost(t, server, 11, server.csrfToken)
+	if got := rec.Header().Get("Location"); got != "/?msg=account+deleted" {
+		t.Fatalf("expected account deletion with valid token, got %d %q", rec.Code, got)
+	}
+}
+
+func TestAdminPost_RejectsInvalidCSRFToken(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "csrf-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 12, AccessToken: "token", AthleteID: 12}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/", strings.NewReader("action=sync-latest&csrf_token=forged"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session := sessionRequest(t, server, 12)
+	for _, cookie := range session.Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.Admin(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/", strings.NewReader("action=sync-latest"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range session.Cookies() {
+		req.AddCookie(cookie)
+	}
+	req.Header.Set(csrfHeaderName, server.csrfToken(req))
+	rec = httptest.NewRecorder()
+	server.Admin(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect with valid token, got %d", rec.Code)
+	}
+}
+
+func TestSettingsPage_EmbedsCSRFToken(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "csrf-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 13, AccessToken: "token", AthleteID: 13}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	req := sessionRequest(t, server, 13)
+	req.URL.Path = "/activities/settings"
+	rec := httptest.NewRecorder()
+	server.Settings(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if want := `name="csrf_token" value="` + server.csrfToken(req) + `"`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected settings forms to carry the csrf token")
+	}
+}
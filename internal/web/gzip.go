@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipMinSize is the smallest body worth compressing; below it the gzip
+// header and CPU cost outweigh the savings.
+const gzipMinSize = 1024
+
+// Gzip compresses text and JSON responses for clients that accept gzip.
+// Bodies shorter than gzipMinSize are sent as-is.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+func compressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/javascript", mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of the body until it knows whether
+// the response is large and compressible enough, then either streams through
+// a gzip.Writer or passes bytes along untouched.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if g.decided {
+		return g.write(p)
+	}
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (g *gzipResponseWriter) write(p []byte) (int, error) {
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// decide picks compression based on what has been buffered, sends the
+// headers and flushes the buffer.
+func (g *gzipResponseWriter) decide() error {
+	g.decided = true
+	header := g.ResponseWriter.Header()
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if header.Get("Content-Type") == "" && len(g.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if len(g.buf) >= gzipMinSize &&
+		header.Get("Content-Encoding") == "" &&
+		g.status != http.StatusNoContent && g.status != http.StatusNotModified &&
+		compressibleContentType(header.Get("Content-Type")) {
+		// The handler's length, if any, described the uncompressed body.
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	if len(g.buf) == 0 {
+		return nil
+	}
+	buf := g.buf
+	g.buf = nil
+	_, err := g.write(buf)
+	return err
+}
+
+// Close flushes anything still buffered and finishes the gzip stream.
+func (g *gzipResponseWriter) Close() error {
+	if !g.decided {
+		if g.status == 0 && len(g.buf) == 0 {
+			return nil
+		}
+		if err := g.decide(); err != nil {
+			return err
+		}
+	}
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	return nil
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		_ = g.decide()
+	}
+	if g.gz != nil {
+		_ = g.gz.Flush()
+	}
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := g.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGzipCompressesLargeJSON(t *testing.T) {
+	body := `{"activities":[` + strings.Repeat(`{"name":"Morning Ride","stops":3},`, 100) + `{}]}`
+	handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = io.WriteString(w, body[:10])
+		_, _ = io.WriteString(w, body[10:])
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/summary", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Fatalf("expected the uncompressed Content-Length to be dropped, got %q", got)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Fatalf("expected compressed body smaller than %d bytes, got %d", len(body), rec.Body.Len())
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(decoded) != body {
+		t.Fatalf("decoded body does not match the original")
+	}
+}
+
+func TestGzipSkipsSmallAndBinaryResponses(t *testing.T) {
+	large := strings.Repeat("a", 4*gzipMinSize)
+	cases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+	}{
+		{name: "small body", acceptEncoding: "gzip", contentType: "text/html; charset=utf-8", body: "<p>hi</p>"},
+		{name: "no gzip support", acceptEncoding: "", contentType: "text/html; charset=utf-8", body: large},
+		{name: "gzip refused", acceptEncoding: "gzip;q=0", contentType: "text/html; charset=utf-8", body: large},
+		{name: "binary body", acceptEncoding: "gzip", contentType: "image/png", body: large},
+	}
+	for _, tc := range cases {
+		handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			_, _ = io.WriteString(w, tc.body)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("%s: expected no encoding, got %q", tc.name, got)
+		}
+		if rec.Body.String() != tc.body {
+			t.Fatalf("%s: body changed", tc.name)
+		}
+	}
+}
+
+func TestGzipPassesThroughEmptyStatusResponses(t *testing.T) {
+	handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"abc"`)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/rules/metadata", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected an empty, unencoded 304")
+	}
+}
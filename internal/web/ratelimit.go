@@ -0,0 +1,159 @@
+package web
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimitBuckets bounds memory; once exceeded, buckets that have
+// refilled completely are dropped since they carry no state, and if that is
+// not enough the least recently used bucket is evicted.
+const maxRateLimitBuckets = 10000
+
+// RateLimiter is an in-memory token bucket per client IP.
+type RateLimiter struct {
+	perSecond float64
+	burst     float64
+	now       func() time.Time
+
+	// TrustedProxies lists the reverse proxies whose X-Forwarded-For header
+	// is believed. Requests from anywhere else are keyed on RemoteAddr.
+	TrustedProxies []netip.Prefix
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows perMinute requests per client with bursts of up to
+// burst requests. It returns nil when perMinute <= 0; a nil limiter lets
+// everything through.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(burst),
+		now:       time.Now,
+		buckets:   make(map[string]*rateBucket),
+	}
+}
+
+// Allow takes a token for key. When the bucket is empty it returns false and
+// how long until the next token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.pruneLocked(now)
+		}
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.evictOldestLocked()
+		}
+		bucket = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.perSecond)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.perSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *RateLimiter) evictOldestLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for key, bucket := range l.buckets {
+		if oldestKey == "" || bucket.last.Before(oldest) {
+			oldestKey, oldest = key, bucket.last
+		}
+	}
+	delete(l.buckets, oldestKey)
+}
+
+// Middleware rejects requests over the limit with 429 Too Many Requests.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Allow(l.clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address the limit applies to. X-Forwarded-For is only
+// honoured when the request comes from a trusted proxy, and then the
+// right-most hop that is not itself a trusted proxy is used: everything left
+// of it was written by the client and can be forged.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !l.trusted(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !l.trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+func (l *RateLimiter) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterMiddleware_RejectsOverThreshold(t *testing.T) {
+	limiter := NewRateLimiter(6, 3)
+	now := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	calls := 0
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	do := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/connect/strava", nil)
+		req.RemoteAddr = ip + ":5555"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := do("10.0.0.1"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 within burst, got %d", i, rec.Code)
+		}
+	}
+	rec := do("10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over threshold, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Fatalf("expected Retry-After 10, got %q", got)
+	}
+	if rec := do("10.0.0.2"); rec.Code != http.StatusOK {
+		t.Fatalf("expected other clients unaffected, got %d", rec.Code)
+	}
+
+	now = now.Add(10 * time.Second)
+	if rec := do("10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("expected a refilled token after 10s, got %d", rec.Code)
+	}
+	if rec := do("10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after spending the refilled token, got %d", rec.Code)
+	}
+	if calls != 5 {
+		t.Fatalf("expected 5 requests to reach the handler, got %d", calls)
+	}
+}
+
+func TestRateLimiter_KeysOnForwardedForFromTrustedProxy(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	limiter.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32"), netip.MustParsePrefix("10.0.0.0/8")}
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/connect/strava/callback", nil)
+		req.RemoteAddr = "127.0.0.1:5555"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := do("203.0.113.7, 10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := do("203.0.113.7"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for same forwarded client, got %d", code)
+	}
+	// Spoofed hops left of the real client must not open a new bucket.
+	if code := do("198.51.100.1, 203.0.113.7"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 when the client prepends a spoofed hop, got %d", code)
+	}
+	if code := do("203.0.113.8"); code != http.StatusOK {
+		t.Fatalf("expected 200 for another forwarded client, got %d", code)
+	}
+}
+
+func TestRateLimiter_IgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, forwarded := range []string{"203.0.113.7", "203.0.113.8"} {
+		req := httptest.NewRequest(http.MethodGet, "/connect/strava/callback", nil)
+		req.RemoteAddr = "192.0.2.10:5555"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("request %d with X-Forwarded-For %s: expected %d, got %d", i, forwarded, want, rec.Code)
+		}
+	}
+}
+
+func TestRateLimiter_BoundsBuckets(t *testing.T) {
+	limiter := NewRateLimiter(1, 5)
+	for i := 0; i < maxRateLimitBuckets+50; i++ {
+		limiter.Allow("client-" + strconv.Itoa(i))
+	}
+	if got := len(limiter.buckets); got > maxRateLimitBuckets {
+		t.Fatalf("expected at most %d buckets, got %d", maxRateLimitBuckets, got)
+	}
+}
+
+func TestNewRateLimiter_DisabledPassesThrough(t *testing.T) {
+	limiter := NewRateLimiter(0, 0)
+	if limiter != nil {
+		t.Fatalf("expected nil limiter when disabled")
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("x"); !ok {
+			t.Fatalf("expected disabled limiter to allow everything")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Readiness tracks whether the process can take traffic: the schema has been
+// migrated and every background loop has run at least once.
+type Readiness struct {
+	schemaReady    atomic.Bool
+	pendingWorkers atomic.Int32
+}
+
+func NewReadiness(workers int) *Readiness {
+	r := &Readiness{}
+	r.pendingWorkers.Store(int32(workers))
+	return r
+}
+
+func (r *Readiness) MarkSchemaReady() {
+	r.schemaReady.Store(true)
+}
+
+// WorkerStarted returns a func for one worker to call after each loop; only
+// the first call counts.
+func (r *Readiness) WorkerStarted() func() {
+	var done atomic.Bool
+	return func() {
+		if done.CompareAndSwap(false, true) {
+			r.pendingWorkers.Add(-1)
+		}
+	}
+}
+
+func (r *Readiness) Ready() bool {
+	return r.schemaReady.Load() && r.pendingWorkers.Load() <= 0
+}
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !r.Ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	readiness := NewReadiness(2)
+	workerA := readiness.WorkerStarted()
+	workerB := readiness.WorkerStarted()
+
+	check := func(want int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != want {
+			t.Fatalf("expected %d, got %d", want, rec.Code)
+		}
+	}
+
+	check(http.StatusServiceUnavailable)
+	readiness.MarkSchemaReady()
+	check(http.StatusServiceUnavailable)
+	workerA()
+	workerA()
+	check(http.StatusServiceUnavailable)
+	workerB()
+	check(http.StatusOK)
+}
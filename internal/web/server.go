@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"weirdstats/internal/gps"
@@ -27,6 +28,7 @@ import (
 	"weirdstats/internal/rules"
 	"weirdstats/internal/storage"
 	"weirdstats/internal/strava"
+	"weirdstats/internal/webhook"
 )
 
 //go:embed templates/*.html
@@ -46,6 +48,8 @@ const (
 	sessionDuration      = 30 * 24 * time.Hour
 	bearerTokenDuration  = 30 * 24 * time.Hour
 	mobileGrantDuration  = 5 * time.Minute
+	webhookReplayWindow  = 7 * 24 * time.Hour
+	webhookReplayLimit   = 1000
 )
 
 type Server struct {
@@ -57,6 +61,21 @@ type Server struct {
 	templates     map[string]*template.Template
 	strava        StravaConfig
 	sessionSecret []byte
+	background    sync.WaitGroup
+	recomputer    StatsRecomputer
+}
+
+// StatsRecomputer recomputes and stores stats for one activity. main wires
+// in the same stats processor the queue uses, built from the map API and
+// stop options the server was created with.
+type StatsRecomputer interface {
+	Process(ctx context.Context, activityID int64) error
+}
+
+// SetStatsRecomputer enables the synchronous recompute action on the
+// activity page.
+func (s *Server) SetStatsRecomputer(recomputer StatsRecomputer) {
+	s.recomputer = recomputer
 }
 
 type ActivityView struct {
@@ -84,9 +103,11 @@ type ActivityView struct {
 	LightStops        int
 	DetectedFactCount int
 	RoadCrossings     int
+	EffortPercentile  string
 	RecalculatedAt    string
 	FetchedAt         string
 	IsHidden          bool
+	HiddenByRule      string
 	FeedMuted         bool
 	PhotoURL          string
 	HasRoutePreview   bool
@@ -99,14 +120,15 @@ type ActivityView struct {
 }
 
 type StopView struct {
-	Lat             float64 `json:"lat"`
-	Lon             float64 `json:"lon"`
-	StartSeconds    float64 `json:"start_seconds"`
-	Duration        string  `json:"duration"`
-	DurationSeconds int     `json:"duration_seconds"`
-	HasTrafficLight bool    `json:"has_traffic_light"`
-	HasRoadCrossing bool    `json:"has_road_crossing"`
-	CrossingRoad    string  `json:"crossing_road,omitempty"`
+	Lat                 float64 `json:"lat"`
+	Lon                 float64 `json:"lon"`
+	StartSeconds        float64 `json:"start_seconds"`
+	Duration            string  `json:"duration"`
+	DurationSeconds     int     `json:"duration_seconds"`
+	HasTrafficLight     bool    `json:"has_traffic_light"`
+	HasRoadCrossing     bool    `json:"has_road_crossing"`
+	CrossingRoad        string  `json:"crossing_road,omitempty"`
+	TrafficLightUnknown bool    `json:"traffic_light_unknown,omitempty"`
 }
 
 type ActivityFactPoint struct {
@@ -168,6 +190,7 @@ type PageData struct {
 	FooterText string
 	Strava     StravaInfo
 	UserCount  int
+	CSRFToken  string
 }
 
 type LandingPageData struct {
@@ -177,26 +200,44 @@ type LandingPageData struct {
 
 type ProfilePageData struct {
 	PageData
-	Activities       []ActivityView
-	Contributions    []ContributionData
-	DayFilterActive  bool
-	SelectedDay      string
-	SelectedDayLabel string
+	Activities        []ActivityView
+	Contributions     []ContributionData
+	DayFilterActive   bool
+	SelectedDay       string
+	SelectedDayLabel  string
+	RangeFilterActive bool
+	RangeLabel        string
+	Sort              string
+	SortOptions       []SortOptionView
+	FilterDay         string
+	FilterFrom        string
+	FilterTo          string
+	Search            string
+	HiddenOnly        bool
+}
+
+type SortOptionView struct {
+	Value    string
+	Label    string
+	Selected bool
 }
 
 type SettingsRule struct {
 	ID          int64
 	Name        string
 	Description string
+	Condition   string
 	Enabled     bool
 	IsLegacy    bool
 }
 
 type SettingsPageData struct {
 	PageData
-	Facts         []SettingsFact
-	Rules         []SettingsRule
-	RulesMetaJSON template.JS
+	Facts               []SettingsFact
+	Rules               []SettingsRule
+	RulesMetaJSON       template.JS
+	DescriptionTemplate string
+	TemplatePresets     []descriptionTemplatePreset
 }
 
 type AdminPageData struct {
@@ -258,6 +299,12 @@ type StravaConfig struct {
 	InitialSyncDays      int
 	Clients              *strava.ClientFactory
 	SessionSecret        string
+	// UserAgent is sent with the OAuth code exchange; empty uses
+	// strava.DefaultUserAgent.
+	UserAgent string
+	// DisableDescriptionWrite stops the apply step from editing Strava
+	// descriptions; hide-from-home updates still go through.
+	DisableDescriptionWrite bool
 }
 
 // StaticHandler serves embedded static assets (leaflet, chart.js).
@@ -338,6 +385,15 @@ func NewServer(store *storage.Store, ingestor *ingest.Ingestor, mapAPI maps.API,
 	if err != nil {
 		return nil, err
 	}
+	activityMap, err := template.New("base").Funcs(funcs).ParseFS(
+		templatesFS,
+		"templates/base.html",
+		"templates/footer.html",
+		"templates/map.html",
+	)
+	if err != nil {
+		return nil, err
+	}
 	poster, err := template.New("poster").Funcs(funcs).ParseFS(
 		templatesFS,
 		"templates/poster.html",
@@ -359,6 +415,7 @@ func NewServer(store *storage.Store, ingestor *ingest.Ingestor, mapAPI maps.API,
 			"settings": settings,
 			"admin":    admin,
 			"activity": activity,
+			"map":      activityMap,
 			"poster":   poster,
 		},
 	}, nil
@@ -420,39 +477,14 @@ func (s *Server) currentUserID(ctx context.Context, r *http.Request) (int64, boo
 	if userID, ok := s.currentBearerUserID(ctx, r); ok {
 		return userID, true
 	}
-	cookie, err := r.Cookie(sessionCookieName)
-	if err != nil || cookie.Value == "" {
-		return 0, false
-	}
-	parts := strings.Split(cookie.Value, ".")
-	if len(parts) != 2 {
-		return 0, false
-	}
-	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
-	if err != nil {
-		return 0, false
-	}
-	sigBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
-	if err != nil {
-		return 0, false
-	}
-	if !hmac.Equal(sigBytes, s.sign(payloadBytes)) {
-		return 0, false
-	}
-	var payload struct {
-		UserID  int64 `json:"user_id"`
-		Expires int64 `json:"expires"`
-	}
-	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
-		return 0, false
-	}
-	if payload.UserID == 0 || payload.Expires <= time.Now().Unix() {
+	userID, ok := s.userFromRequest(r)
+	if !ok {
 		return 0, false
 	}
-	if _, err := s.store.GetStravaToken(ctx, payload.UserID); err != nil {
+	if _, err := s.store.GetStravaToken(ctx, userID); err != nil {
 		return 0, false
 	}
-	return payload.UserID, true
+	return userID, true
 }
 
 func (s *Server) currentBearerUserID(ctx context.Context, r *http.Request) (int64, bool) {
@@ -474,24 +506,8 @@ func (s *Server) currentBearerUserID(ctx context.Context, r *http.Request) (int6
 	return payload.UserID, true
 }
 
-func (s *Server) setSession(w http.ResponseWriter, r *http.Request, userID int64) error {
-	payload, err := json.Marshal(struct {
-		UserID  int64 `json:"user_id"`
-		Expires int64 `json:"expires"`
-	}{
-		UserID:  userID,
-		Expires: time.Now().Add(sessionDuration).Unix(),
-	})
-	if err != nil {
-		return err
-	}
-	value := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
-	setCookie(w, r, sessionCookieName, value, int(sessionDuration.Seconds()))
-	return nil
-}
-
 type signedAuthPayload struct {
-	Kind    string `json:"kind"`
+	Kind    string `json:"kind,omitempty"`
 	UserID  int64  `json:"user_id"`
 	Expires int64  `json:"expires"`
 }
@@ -557,10 +573,6 @@ func (s *Server) parseSignedAuthToken(value, kind string) (signedAuthPayload, bo
 	return payload, true
 }
 
-func (s *Server) clearSession(w http.ResponseWriter, r *http.Request) {
-	setCookie(w, r, sessionCookieName, "", -1)
-}
-
 func (s *Server) sign(payload []byte) []byte {
 	mac := hmac.New(sha256.New, s.sessionSecret)
 	_, _ = mac.Write(payload)
@@ -697,14 +709,30 @@ func (s *Server) UsersCount(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to count users", http.StatusInternalServerError)
 		return
 	}
+	// Only the total is public; never add names or athlete ids here.
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(struct {
+		Count int `json:"count"`
+		// Users predates Count and is kept for existing callers.
 		Users int `json:"users"`
 	}{
+		Count: count,
 		Users: count,
 	})
 }
 
+func (s *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := s.store.Ping(ctx); err != nil {
+		log.Printf("healthz: store ping failed: %v", err)
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
 func (s *Server) RulesMetadata(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/api/rules/metadata" {
 		http.NotFound(w, r)
@@ -719,10 +747,7 @@ func (s *Server) RulesMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 	registry := rules.DefaultRegistry()
 	meta := rules.BuildMetadata(registry, rules.DefaultOperators())
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(meta); err != nil {
-		http.Error(w, "failed to encode metadata", http.StatusInternalServerError)
-	}
+	writeJSONWithETag(w, r, meta)
 }
 
 func (s *Server) Settings(w http.ResponseWriter, r *http.Request) {
@@ -763,10 +788,19 @@ func (s *Server) Settings(w http.ResponseWriter, r *http.Request) {
 			ID:          ruleRow.ID,
 			Name:        ruleRow.Name,
 			Description: description,
+			Condition:   ruleRow.Condition,
 			Enabled:     ruleRow.Enabled,
 			IsLegacy:    isLegacy,
 		})
 	}
+	descriptionTemplate, err := s.store.GetUserSetting(r.Context(), userID, descriptionTemplateSettingKey)
+	if err != nil {
+		http.Error(w, "failed to load description template", http.StatusInternalServerError)
+		return
+	}
+	if descriptionTemplate == "" {
+		descriptionTemplate = defaultDescriptionTemplate
+	}
 	meta := rules.BuildMetadata(registry, rules.DefaultOperators())
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
@@ -781,10 +815,13 @@ func (s *Server) Settings(w http.ResponseWriter, r *http.Request) {
 			FooterText: "Rules and fact preferences are stored locally and applied when Weirdstats updates activities.",
 			Strava:     s.getStravaInfo(r.Context(), userID),
 			UserCount:  s.userCount(r.Context()),
+			CSRFToken:  s.csrfToken(r),
 		},
-		Facts:         buildSettingsFacts(factSettings),
-		Rules:         viewRules,
-		RulesMetaJSON: template.JS(string(metaJSON)),
+		Facts:               buildSettingsFacts(factSettings),
+		Rules:               viewRules,
+		RulesMetaJSON:       template.JS(string(metaJSON)),
+		DescriptionTemplate: descriptionTemplate,
+		TemplatePresets:     descriptionTemplatePresets,
 	}
 	if err := s.templates["settings"].ExecuteTemplate(w, "base", data); err != nil {
 		http.Error(w, "template render failed", http.StatusInternalServerError)
@@ -821,6 +858,7 @@ func (s *Server) Admin(w http.ResponseWriter, r *http.Request) {
 			FooterText: "Admin actions are logged and may take time to complete.",
 			Strava:     s.getStravaInfo(r.Context(), userID),
 			UserCount:  s.userCount(r.Context()),
+			CSRFToken:  s.csrfToken(r),
 		},
 		QueueCount:   queueCount,
 		Jobs:         jobsView,
@@ -836,6 +874,10 @@ func (s *Server) handleAdminPost(w http.ResponseWriter, r *http.Request, userID
 		http.Redirect(w, r, "/admin/?msg=invalid+form", http.StatusFound)
 		return
 	}
+	if !s.validCSRF(r) {
+		http.Error(w, "invalid csrf token", http.StatusForbidden)
+		return
+	}
 	action := strings.TrimSpace(r.FormValue("action"))
 	switch action {
 	case "sync-latest":
@@ -880,6 +922,29 @@ func (s *Server) handleAdminPost(w http.ResponseWriter, r *http.Request, userID
 			return
 		}
 		http.Redirect(w, r, "/admin/?msg=sync+queued+all", http.StatusFound)
+	case "sync-since":
+		if s.ingestor == nil {
+			http.Redirect(w, r, "/admin/?msg=sync+not+configured", http.StatusFound)
+			return
+		}
+		since, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(r.FormValue("since")), time.Local)
+		if err != nil || since.After(time.Now()) {
+			http.Redirect(w, r, "/admin/?msg=invalid+sync+date", http.StatusFound)
+			return
+		}
+		payload := jobs.SyncSincePayload{
+			UserID:         userID,
+			AfterUnix:      since.Unix(),
+			PerPage:        100,
+			WindowDays:     30,
+			OldestSyncUnix: since.Unix(),
+			Force:          r.FormValue("force") == "on",
+		}
+		if err := s.enqueueSyncPayload(r.Context(), payload); err != nil {
+			http.Redirect(w, r, "/admin/?msg=sync+enqueue+failed", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/admin/?msg=sync+queued+since+"+since.Format("2006-01-02"), http.StatusFound)
 	case "test-overpass":
 		if s.overpass == nil {
 			http.Redirect(w, r, "/admin/?msg=overpass+client+not+configured", http.StatusFound)
@@ -901,6 +966,14 @@ func (s *Server) handleAdminPost(w http.ResponseWriter, r *http.Request, userID
 		}
 		msg := fmt.Sprintf("overpass ok: %d features in test bbox", len(pois))
 		http.Redirect(w, r, "/admin/?msg="+url.QueryEscape(msg), http.StatusFound)
+	case "replay-webhooks":
+		enqueued, err := webhook.Replay(r.Context(), s.store, userID, time.Now().Add(-webhookReplayWindow), webhookReplayLimit)
+		if err != nil {
+			http.Redirect(w, r, "/admin/?msg="+url.QueryEscape("webhook replay failed: "+err.Error()), http.StatusFound)
+			return
+		}
+		msg := fmt.Sprintf("webhook replay queued %d activities", enqueued)
+		http.Redirect(w, r, "/admin/?msg="+url.QueryEscape(msg), http.StatusFound)
 	case "clear-jobs":
 		http.Redirect(w, r, "/admin/?msg=job+clearing+disabled+for+multi-user+safety", http.StatusFound)
 	default:
@@ -982,7 +1055,7 @@ func (s *Server) StravaCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	code := r.URL.Query().Get("code")
-	userID, err := s.connectStravaUser(r.Context(), code)
+	userID, err := s.connectStravaUser(r.Context(), code, r.URL.Query().Get("scope"))
 	if err != nil {
 		http.Redirect(w, r, appendMessage("/", err.Error()), http.StatusFound)
 		return
@@ -994,13 +1067,14 @@ func (s *Server) StravaCallback(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, next, http.StatusFound)
 }
 
-func (s *Server) connectStravaUser(ctx context.Context, code string) (int64, error) {
+func (s *Server) connectStravaUser(ctx context.Context, code, scope string) (int64, error) {
 	token, err := strava.ExchangeAuthorizationCode(
 		ctx,
 		s.strava.AuthBaseURL,
 		s.strava.ClientID,
 		s.strava.ClientSecret,
 		code,
+		s.strava.UserAgent,
 		nil,
 	)
 	if err != nil {
@@ -1041,6 +1115,7 @@ func (s *Server) connectStravaUser(ctx context.Context, code string) (int64, err
 		ExpiresAt:    time.Unix(token.ExpiresAt, 0),
 		AthleteID:    token.Athlete.ID,
 		AthleteName:  athleteName,
+		Scope:        scope,
 	}); err != nil {
 		log.Printf("strava token save failed: %v", err)
 		return 0, fmt.Errorf("strava token save failed")
@@ -1092,11 +1167,32 @@ func compactForLog(raw string, max int) string {
 	return msg
 }
 
+// normalizeRuleCondition parses and validates rule JSON, returning it
+// re-encoded along with a user-facing message when it is rejected.
+func normalizeRuleCondition(condition string) (string, string, error) {
+	parsedRule, err := rules.ParseRuleJSON(condition)
+	if err != nil {
+		return "", "invalid rule json: " + compactErrMessage(err), err
+	}
+	if err := rules.ValidateRule(parsedRule, rules.DefaultRegistry()); err != nil {
+		return "", "invalid rule definition: " + compactErrMessage(err), err
+	}
+	normalized, err := json.Marshal(parsedRule)
+	if err != nil {
+		return "", "rule save failed", err
+	}
+	return string(normalized), "", nil
+}
+
 func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, userID int64) {
 	if err := r.ParseForm(); err != nil {
 		http.Redirect(w, r, "/activities/settings?msg=invalid+form", http.StatusFound)
 		return
 	}
+	if !s.validCSRF(r) {
+		http.Error(w, "invalid csrf token", http.StatusForbidden)
+		return
+	}
 	action := strings.TrimSpace(r.FormValue("action"))
 	switch action {
 	case "update-facts":
@@ -1112,6 +1208,22 @@ func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, user
 			return
 		}
 		http.Redirect(w, r, "/activities/settings?msg=facts+updated", http.StatusFound)
+	case "update-description-template":
+		templateText := strings.TrimSpace(r.FormValue("description_template"))
+		if templateText == defaultDescriptionTemplate {
+			templateText = ""
+		}
+		if templateText != "" {
+			if _, err := parseDescriptionTemplate(templateText); err != nil {
+				http.Redirect(w, r, appendMessage("/activities/settings", "invalid description template: "+err.Error()), http.StatusFound)
+				return
+			}
+		}
+		if err := s.store.SetUserSetting(r.Context(), userID, descriptionTemplateSettingKey, templateText); err != nil {
+			http.Redirect(w, r, "/activities/settings?msg=description+template+update+failed", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/activities/settings?msg=description+template+updated", http.StatusFound)
 	case "add-rule":
 		name := strings.TrimSpace(r.FormValue("name"))
 		condition := strings.TrimSpace(r.FormValue("condition"))
@@ -1120,26 +1232,13 @@ func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, user
 			http.Redirect(w, r, "/activities/settings?msg=missing+rule+fields", http.StatusFound)
 			return
 		}
-		parsedRule, err := rules.ParseRuleJSON(condition)
+		normalized, msg, err := normalizeRuleCondition(condition)
 		if err != nil {
-			detail := compactErrMessage(err)
-			log.Printf("settings add-rule parse failed: name=%q enabled=%t err=%v json=%q", name, enabled, err, compactForLog(condition, 500))
-			http.Redirect(w, r, "/activities/settings?msg="+url.QueryEscape("invalid rule json: "+detail), http.StatusFound)
-			return
-		}
-		if err := rules.ValidateRule(parsedRule, rules.DefaultRegistry()); err != nil {
-			detail := compactErrMessage(err)
-			log.Printf("settings add-rule validation failed: name=%q enabled=%t err=%v json=%q", name, enabled, err, compactForLog(condition, 500))
-			http.Redirect(w, r, "/activities/settings?msg="+url.QueryEscape("invalid rule definition: "+detail), http.StatusFound)
+			log.Printf("settings add-rule failed: name=%q enabled=%t err=%v json=%q", name, enabled, err, compactForLog(condition, 500))
+			http.Redirect(w, r, "/activities/settings?msg="+url.QueryEscape(msg), http.StatusFound)
 			return
 		}
-		normalized, err := json.Marshal(parsedRule)
-		if err != nil {
-			log.Printf("settings add-rule normalize failed: name=%q enabled=%t err=%v", name, enabled, err)
-			http.Redirect(w, r, "/activities/settings?msg=rule+save+failed", http.StatusFound)
-			return
-		}
-		condition = string(normalized)
+		condition = normalized
 		if _, err := s.store.CreateHideRule(r.Context(), storage.HideRule{
 			UserID:    userID,
 			Name:      name,
@@ -1150,7 +1249,37 @@ func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, user
 			http.Redirect(w, r, "/activities/settings?msg=rule+save+failed", http.StatusFound)
 			return
 		}
+		s.reapplyHideRulesAsync(userID)
 		http.Redirect(w, r, "/activities/settings?msg=rule+added", http.StatusFound)
+	case "edit-rule":
+		ruleID, err := strconv.ParseInt(r.FormValue("rule_id"), 10, 64)
+		if err != nil || ruleID == 0 {
+			http.Redirect(w, r, "/activities/settings?msg=invalid+rule", http.StatusFound)
+			return
+		}
+		name := strings.TrimSpace(r.FormValue("name"))
+		condition := strings.TrimSpace(r.FormValue("condition"))
+		if name == "" || condition == "" {
+			http.Redirect(w, r, "/activities/settings?msg=missing+rule+fields", http.StatusFound)
+			return
+		}
+		normalized, msg, err := normalizeRuleCondition(condition)
+		if err != nil {
+			log.Printf("settings edit-rule failed: rule=%d err=%v json=%q", ruleID, err, compactForLog(condition, 500))
+			http.Redirect(w, r, "/activities/settings?msg="+url.QueryEscape(msg), http.StatusFound)
+			return
+		}
+		if err := s.store.UpdateHideRuleForUser(r.Context(), userID, ruleID, name, normalized); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Printf("settings edit-rule store failed: rule=%d err=%v", ruleID, err)
+			http.Redirect(w, r, "/activities/settings?msg=rule+update+failed", http.StatusFound)
+			return
+		}
+		s.reapplyHideRulesAsync(userID)
+		http.Redirect(w, r, "/activities/settings?msg=rule+updated", http.StatusFound)
 	case "toggle-rule":
 		idValue := r.FormValue("rule_id")
 		enabled := r.FormValue("enabled") == "on"
@@ -1163,6 +1292,7 @@ func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, user
 			http.Redirect(w, r, "/activities/settings?msg=rule+update+failed", http.StatusFound)
 			return
 		}
+		s.reapplyHideRulesAsync(userID)
 		http.Redirect(w, r, "/activities/settings?msg=rule+updated", http.StatusFound)
 	case "delete-rule":
 		idValue := r.FormValue("rule_id")
@@ -1175,7 +1305,20 @@ func (s *Server) handleSettingsPost(w http.ResponseWriter, r *http.Request, user
 			http.Redirect(w, r, "/activities/settings?msg=rule+delete+failed", http.StatusFound)
 			return
 		}
+		s.reapplyHideRulesAsync(userID)
 		http.Redirect(w, r, "/activities/settings?msg=rule+deleted", http.StatusFound)
+	case "reprocess-all":
+		queued, err := s.reprocessAllActivities(r.Context(), userID, time.Now())
+		if errors.Is(err, errReprocessAllTooSoon) {
+			http.Redirect(w, r, appendMessage("/activities/settings", "reprocess already requested, try again later"), http.StatusFound)
+			return
+		}
+		if err != nil {
+			log.Printf("settings reprocess-all failed for user %d after %d activities: %v", userID, queued, err)
+			http.Redirect(w, r, "/activities/settings?msg=reprocess+failed", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, appendMessage("/activities/settings", fmt.Sprintf("queued %d activities for reprocessing", queued)), http.StatusFound)
 	case "log-out":
 		s.clearSession(w, r)
 		http.Redirect(w, r, "/?msg=signed+out", http.StatusFound)
@@ -1213,11 +1356,17 @@ func (s *Server) enqueueSyncJobWindow(ctx context.Context, userID int64, after t
 	if windowDays <= 0 {
 		windowDays = 1
 	}
-	payload := jobs.SyncSincePayload{
+	return s.enqueueSyncPayload(ctx, jobs.SyncSincePayload{
 		UserID:     userID,
 		AfterUnix:  after.Unix(),
 		PerPage:    100,
 		WindowDays: windowDays,
+	})
+}
+
+func (s *Server) enqueueSyncPayload(ctx context.Context, payload jobs.SyncSincePayload) error {
+	if s.store == nil {
+		return fmt.Errorf("store not configured")
 	}
 	cursor := jobs.SyncSinceCursor{Page: 1}
 	payloadJSON, err := json.Marshal(payload)
@@ -46,14 +46,51 @@ func (s *Server) Activities(w http.ResponseWriter, r *http.Request) {
 	if dayFilterActive {
 		trace.AddField("day_filter", selectedDay)
 	}
+	message := r.URL.Query().Get("msg")
+	var rangeFilter activityRangeFilter
+	if !dayFilterActive {
+		rangeFilter, err = parseActivityRangeFilter(r)
+		if err != nil {
+			trace.AddField("invalid_range_filter", true)
+			if message == "" {
+				message = "Invalid date range; showing latest activities."
+			}
+		} else if rangeFilter.Active {
+			trace.AddField("range_filter", rangeFilter.Label)
+		}
+	}
 
-	stepStart := time.Now()
-	var activities []storage.ActivityWithStats
+	sort := parseActivitySort(r)
+	if sort.Value != defaultActivitySort {
+		trace.AddField("sort", sort.Value)
+	}
+
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	if search != "" {
+		trace.AddField("search", true)
+	}
+
+	hiddenOnly := r.URL.Query().Get("hidden") == "1"
+	if hiddenOnly {
+		trace.AddField("hidden_only", true)
+	}
+
+	listQuery := storage.ActivityListQuery{
+		Search:     search,
+		HiddenOnly: hiddenOnly,
+		Sort:       sort.Key,
+		Ascending:  sort.Ascending,
+		Limit:      100,
+	}
 	if dayFilterActive {
-		activities, err = s.store.ListActivitiesWithStatsInRange(r.Context(), userID, selectedDayDate, selectedDayDate.AddDate(0, 0, 1), 100)
-	} else {
-		activities, err = s.store.ListActivitiesWithStats(r.Context(), userID, 100)
+		listQuery.Start = selectedDayDate
+		listQuery.End = selectedDayDate.AddDate(0, 0, 1)
+	} else if rangeFilter.Active {
+		listQuery.Start = rangeFilter.Start
+		listQuery.End = rangeFilter.End
 	}
+	stepStart := time.Now()
+	activities, err := s.store.QueryActivitiesWithStats(r.Context(), userID, listQuery)
 	trace.AddStep("list_activities", stepStart)
 	if err != nil {
 		trace.AddField("error", "list_activities")
@@ -102,6 +139,12 @@ func (s *Server) Activities(w http.ResponseWriter, r *http.Request) {
 			PhotoURL:          activity.PhotoURL,
 		}
 		enrichActivityView(&view, activity.Activity)
+		if activity.HiddenByRule {
+			view.HiddenByRule = activity.HiddenByRuleName
+			if view.HiddenByRule == "" {
+				view.HiddenByRule = "a hide rule"
+			}
+		}
 		routePoints := routePointsByActivity[activity.ID]
 		if len(routePoints) > 0 {
 			previewPoints := make([]routePreviewPoint, 0, len(routePoints))
@@ -164,16 +207,25 @@ func (s *Server) Activities(w http.ResponseWriter, r *http.Request) {
 		PageData: PageData{
 			Title:      "Activities",
 			Page:       "activities",
-			Message:    r.URL.Query().Get("msg"),
+			Message:    message,
 			FooterText: "Tip: the worker runs in the background and fills in stats after ingest.",
 			Strava:     s.getStravaInfo(r.Context(), userID),
 			UserCount:  s.userCount(r.Context()),
 		},
-		Activities:       views,
-		Contributions:    contribs,
-		DayFilterActive:  dayFilterActive,
-		SelectedDay:      selectedDay,
-		SelectedDayLabel: selectedDayLabel,
+		Activities:        views,
+		Contributions:     contribs,
+		DayFilterActive:   dayFilterActive,
+		SelectedDay:       selectedDay,
+		SelectedDayLabel:  selectedDayLabel,
+		RangeFilterActive: rangeFilter.Active,
+		RangeLabel:        rangeFilter.Label,
+		Sort:              sort.Value,
+		SortOptions:       activitySortOptionViews(sort.Value),
+		FilterDay:         selectedDay,
+		FilterFrom:        strings.TrimSpace(r.URL.Query().Get("from")),
+		FilterTo:          strings.TrimSpace(r.URL.Query().Get("to")),
+		Search:            search,
+		HiddenOnly:        hiddenOnly,
 	}
 	stepStart = time.Now()
 	if err := s.templates["profile"].ExecuteTemplate(w, "base", data); err != nil {
@@ -197,6 +249,106 @@ func parseActivityDayFilter(r *http.Request) (time.Time, string, error) {
 	return day, day.Format(activityDayLayout), nil
 }
 
+type activityRangeFilter struct {
+	Active bool
+	Start  time.Time
+	End    time.Time
+	Label  string
+}
+
+// parseActivityRangeFilter reads ?from=YYYY-MM-DD&to=YYYY-MM-DD. Both bounds
+// are inclusive days; either may be omitted to leave that side open.
+func parseActivityRangeFilter(r *http.Request) (activityRangeFilter, error) {
+	fromParam := strings.TrimSpace(r.URL.Query().Get("from"))
+	toParam := strings.TrimSpace(r.URL.Query().Get("to"))
+	if fromParam == "" && toParam == "" {
+		return activityRangeFilter{}, nil
+	}
+	filter := activityRangeFilter{
+		Active: true,
+		Start:  time.Unix(0, 0),
+		End:    time.Now().AddDate(0, 0, 1),
+	}
+	fromLabel, toLabel := "", ""
+	if fromParam != "" {
+		from, err := time.ParseInLocation(activityDayLayout, fromParam, time.Local)
+		if err != nil {
+			return activityRangeFilter{}, fmt.Errorf("parse activity range from %q: %w", fromParam, err)
+		}
+		filter.Start = from
+		fromLabel = from.Format("Jan 2, 2006")
+	}
+	if toParam != "" {
+		to, err := time.ParseInLocation(activityDayLayout, toParam, time.Local)
+		if err != nil {
+			return activityRangeFilter{}, fmt.Errorf("parse activity range to %q: %w", toParam, err)
+		}
+		filter.End = to.AddDate(0, 0, 1)
+		toLabel = to.Format("Jan 2, 2006")
+	}
+	if !filter.End.After(filter.Start) {
+		return activityRangeFilter{}, fmt.Errorf("activity range ends before it starts")
+	}
+	switch {
+	case fromLabel != "" && toLabel != "":
+		filter.Label = fromLabel + " – " + toLabel
+	case fromLabel != "":
+		filter.Label = "Since " + fromLabel
+	default:
+		filter.Label = "Until " + toLabel
+	}
+	return filter, nil
+}
+
+const defaultActivitySort = "date_desc"
+
+type activitySortOption struct {
+	Value     string
+	Label     string
+	Key       string
+	Ascending bool
+}
+
+// activitySortOptions is the full whitelist for ?sort=. Anything else falls
+// back to the default newest-first order.
+var activitySortOptions = []activitySortOption{
+	{Value: "date_desc", Label: "Newest first", Key: storage.ActivitySortDate},
+	{Value: "date_asc", Label: "Oldest first", Key: storage.ActivitySortDate, Ascending: true},
+	{Value: "stops_desc", Label: "Most stops", Key: storage.ActivitySortStops},
+	{Value: "stops_asc", Label: "Fewest stops", Key: storage.ActivitySortStops, Ascending: true},
+	{Value: "stop_time_desc", Label: "Longest stopped", Key: storage.ActivitySortStopTime},
+	{Value: "stop_time_asc", Label: "Shortest stopped", Key: storage.ActivitySortStopTime, Ascending: true},
+	{Value: "distance_desc", Label: "Longest distance", Key: storage.ActivitySortDistance},
+	{Value: "distance_asc", Label: "Shortest distance", Key: storage.ActivitySortDistance, Ascending: true},
+}
+
+// parseActivitySort accepts ?sort=key, key_desc or key_asc; a bare key sorts
+// descending.
+func parseActivitySort(r *http.Request) activitySortOption {
+	value := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("sort")))
+	if value != "" && !strings.HasSuffix(value, "_asc") && !strings.HasSuffix(value, "_desc") {
+		value += "_desc"
+	}
+	for _, option := range activitySortOptions {
+		if option.Value == value {
+			return option
+		}
+	}
+	return activitySortOptions[0]
+}
+
+func activitySortOptionViews(selected string) []SortOptionView {
+	views := make([]SortOptionView, 0, len(activitySortOptions))
+	for _, option := range activitySortOptions {
+		views = append(views, SortOptionView{
+			Value:    option.Value,
+			Label:    option.Label,
+			Selected: option.Value == selected,
+		})
+	}
+	return views
+}
+
 func (s *Server) ActivityDetail(w http.ResponseWriter, r *http.Request) {
 	userID, ok := s.requireUserID(w, r)
 	if !ok {
@@ -370,6 +522,16 @@ func (s *Server) ActivityDetail(w http.ResponseWriter, r *http.Request) {
 		FetchedAt:         formatTimestamp(activity.UpdatedAt),
 	}
 	enrichActivityView(&view, activity)
+	if statsPresent && statsSnapshot.EffortVersion > 0 && statsSnapshot.EffortScore > 0 {
+		stepStart = time.Now()
+		percentile, err := s.store.EffortPercentile(r.Context(), activity.UserID, statsSnapshot.EffortScore)
+		trace.AddStep("effort_percentile", stepStart)
+		if err != nil {
+			log.Printf("effort percentile failed for activity %d: %v", activityID, err)
+		} else {
+			view.EffortPercentile = formatPercentile(percentile)
+		}
+	}
 
 	stepStart = time.Now()
 	dataItems := buildActivityDataItems(
@@ -396,6 +558,14 @@ func (s *Server) ActivityDetail(w http.ResponseWriter, r *http.Request) {
 	if view.FetchedAt != "" {
 		footerText += " · Last fetch: " + view.FetchedAt
 	}
+	stepStart = time.Now()
+	webhookEvents, err := s.store.CountWebhookEventsForObject(r.Context(), "activity", activityID)
+	trace.AddStep("count_webhook_events", stepStart)
+	if err != nil {
+		log.Printf("webhook event count failed for activity %d: %v", activityID, err)
+	} else if webhookEvents > 0 {
+		footerText += fmt.Sprintf(" · Webhook events: %d", webhookEvents)
+	}
 
 	data := ActivityDetailData{
 		PageData: PageData{
@@ -452,6 +622,18 @@ func (s *Server) Activity(w http.ResponseWriter, r *http.Request) {
 		s.ApplyActivityRules(w, r)
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/recompute") {
+		s.RecomputeActivity(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/map") {
+		s.ActivityMap(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/geojson") {
+		s.ActivityGeoJSON(w, r)
+		return
+	}
 	s.ActivityDetail(w, r)
 }
 
@@ -543,3 +725,39 @@ func (s *Server) DownloadActivity(w http.ResponseWriter, r *http.Request) {
 		log.Printf("failed to encode activity download: %v", err)
 	}
 }
+
+// RecomputeActivity recomputes stats for one activity in the request instead
+// of queueing it, so threshold changes can be checked right away.
+func (s *Server) RecomputeActivity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := strings.TrimPrefix(r.URL.Path, "/activity/")
+	idStr = strings.TrimSuffix(idStr, "/recompute")
+	activityID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || activityID == 0 {
+		http.Error(w, "invalid activity id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.store.GetActivityForUser(r.Context(), userID, activityID); err != nil {
+		http.Error(w, "activity not found", http.StatusNotFound)
+		return
+	}
+	if s.recomputer == nil {
+		http.Error(w, "stats recompute not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := s.recomputer.Process(r.Context(), activityID); err != nil {
+		log.Printf("recompute stats failed for activity %d: %v", activityID, err)
+		http.Error(w, "failed to recompute stats", http.StatusInternalServerError)
+		return
+	}
+	s.redirectBack(w, r, activityID, "stats recomputed")
+}
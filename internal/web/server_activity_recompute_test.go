@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+)
+
+type recordingRecomputer struct {
+	store *storage.Store
+	calls []int64
+}
+
+func (r *recordingRecomputer) Process(ctx context.Context, activityID int64) error {
+	r.calls = append(r.calls, activityID)
+	return r.store.UpsertActivityStats(ctx, activityID, stats.StopStats{StopCount: 4, StopTotalSeconds: 95})
+}
+
+func TestActivityDetailRecomputesStatsOnDemand(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "session-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 7}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.May, 3, 9, 0, 0, 0, time.UTC)
+	if _, err := store.InsertActivity(ctx, storage.Activity{ID: 42, UserID: 7, Type: "Ride", Name: "Threshold tuning", StartTime: start, Distance: 5000}, []gps.Point{
+		{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6},
+		{Lat: 52.521, Lon: 13.406, Time: start.Add(10 * time.Second), Speed: 6},
+	}); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	recomputer := &recordingRecomputer{store: store}
+	server.SetStatsRecomputer(recomputer)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for _, cookie := range sessionRequest(t, server, 7).Cookies() {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		server.Activity(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "/activity/42")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Threshold tuning") || !strings.Contains(body, `action="/activity/42/recompute"`) {
+		t.Fatalf("expected activity page with a recompute action")
+	}
+
+	rec = request(http.MethodPost, "/activity/42/recompute")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect after recompute, got %d", rec.Code)
+	}
+	if len(recomputer.calls) != 1 || recomputer.calls[0] != 42 {
+		t.Fatalf("expected one recompute for activity 42, got %v", recomputer.calls)
+	}
+	snapshot, err := store.GetActivityStats(ctx, 42)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if snapshot.StopCount != 4 {
+		t.Fatalf("expected recomputed stats to be stored, got %+v", snapshot)
+	}
+
+	if rec := request(http.MethodGet, "/activity/99"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown activity, got %d", rec.Code)
+	}
+	if rec := request(http.MethodPost, "/activity/99/recompute"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 recomputing unknown activity, got %d", rec.Code)
+	}
+	if len(recomputer.calls) != 1 {
+		t.Fatalf("expected no recompute for unknown activity, got %v", recomputer.calls)
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"weirdstats/internal/ingest"
+	"weirdstats/internal/jobs"
+	"weirdstats/internal/storage"
+)
+
+func TestAdmin_SyncSinceForwardsForce(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "admin-secret", "")
+	server.ingestor = &ingest.Ingestor{Store: store}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 31, AccessToken: "token", AthleteID: 31}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	for _, force := range []bool{false, true} {
+		form := url.Values{}
+		form.Set("action", "sync-since")
+		form.Set("since", "2026-01-01")
+		if force {
+			form.Set("force", "on")
+		}
+		req := httptest.NewRequest(http.MethodPost, "/admin/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, cookie := range sessionRequest(t, server, 31).Cookies() {
+			req.AddCookie(cookie)
+		}
+		req.Header.Set(csrfHeaderName, server.csrfToken(req))
+		rec := httptest.NewRecorder()
+		server.Admin(rec, req)
+		if got := rec.Header().Get("Location"); got != "/admin/?msg=sync+queued+since+2026-01-01" {
+			t.Fatalf("force=%v: unexpected redirect %d %q", force, rec.Code, got)
+		}
+	}
+
+	queued, err := store.ListJobsByType(ctx, jobs.JobTypeSyncActivitiesSince, 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("expected 2 sync jobs, got %d", len(queued))
+	}
+	forced := map[bool]int{}
+	for _, job := range queued {
+		var payload jobs.SyncSincePayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if payload.UserID != 31 {
+			t.Fatalf("expected job for user 31, got %+v", payload)
+		}
+		forced[payload.Force]++
+	}
+	if forced[true] != 1 || forced[false] != 1 {
+		t.Fatalf("expected one forced and one regular sync, got %v", forced)
+	}
+}
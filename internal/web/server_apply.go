@@ -61,11 +61,11 @@ func (s *Server) applyActivityRules(ctx context.Context, activityID int64) error
 		return err
 	}
 
-	hide, statsSnapshot, err := s.evaluateHideRules(ctx, activity)
+	hideRuleID, statsSnapshot, err := s.evaluateHideRules(ctx, activity)
 	if err != nil {
 		return err
 	}
-	if err := s.store.UpdateActivityHiddenByRule(ctx, activityID, hide); err != nil {
+	if err := s.store.SetActivityHiddenByRule(ctx, activityID, hideRuleID); err != nil {
 		return err
 	}
 
@@ -212,14 +212,15 @@ func (s *Server) applyActivityRules(ctx context.Context, activityID int64) error
 			}
 		}
 		descriptionLine = buildStravaWeirdStatsLineWithHeartRate(filteredSnapshot, rideFact, speedFacts, heartRateFact, coffeeFact, routeFact, roadFact, factSettings, histories)
+		descriptionLine = s.renderUserDescriptionLine(ctx, activity.UserID, descriptionLine, filteredSnapshot)
 	}
 	newDesc, descChanged := applyWeirdStatsDescriptionLine(baseDescription, descriptionLine)
-	if descChanged {
+	if descChanged && s.canWriteStravaDescription(ctx, activity.UserID) {
 		descPtr = &newDesc
 	}
 
 	var hidePtr *bool
-	if hide && !baseHideFromHome {
+	if hideRuleID != 0 && !baseHideFromHome {
 		val := true
 		hidePtr = &val
 	}
@@ -260,6 +261,22 @@ func (s *Server) applyActivityRules(ctx context.Context, activityID int64) error
 	return nil
 }
 
+// canWriteStravaDescription reports whether the apply step may edit the
+// user's Strava descriptions: the feature must be on and the token must carry
+// the activity:write scope. Without a stored token the client comes from static
+// config, so the scope can't be checked and the write is attempted.
+func (s *Server) canWriteStravaDescription(ctx context.Context, userID int64) bool {
+	if s.strava.DisableDescriptionWrite {
+		return false
+	}
+	token, err := s.store.GetStravaToken(ctx, userID)
+	if err == nil && !token.HasScope("activity:write") {
+		log.Printf("strava description write skipped for user %d: activity:write scope not granted", userID)
+		return false
+	}
+	return true
+}
+
 func totalStopSeconds(stops []StopView) int {
 	total := 0
 	for _, s := range stops {
@@ -272,14 +289,15 @@ func buildStopViews(storedStops []storage.ActivityStop) []StopView {
 	stopViews := make([]StopView, 0, len(storedStops))
 	for _, stop := range storedStops {
 		stopViews = append(stopViews, StopView{
-			Lat:             stop.Lat,
-			Lon:             stop.Lon,
-			StartSeconds:    stop.StartSeconds,
-			Duration:        formatDuration(stop.DurationSeconds),
-			DurationSeconds: stop.DurationSeconds,
-			HasTrafficLight: stop.HasTrafficLight,
-			HasRoadCrossing: stop.HasRoadCrossing,
-			CrossingRoad:    stop.CrossingRoad,
+			Lat:                 stop.Lat,
+			Lon:                 stop.Lon,
+			StartSeconds:        stop.StartSeconds,
+			Duration:            formatDuration(stop.DurationSeconds),
+			DurationSeconds:     stop.DurationSeconds,
+			HasTrafficLight:     stop.HasTrafficLight,
+			HasRoadCrossing:     stop.HasRoadCrossing,
+			CrossingRoad:        stop.CrossingRoad,
+			TrafficLightUnknown: stop.TrafficLightUnknown,
 		})
 	}
 	return stopViews
@@ -381,39 +399,60 @@ func (s *Server) updateActivityDetectedFactsCache(
 	}
 }
 
-func (s *Server) evaluateHideRules(ctx context.Context, activity storage.Activity) (bool, stats.StopStats, error) {
+// reapplyHideRulesAsync re-evaluates hide rules for every stored activity of
+// the user in the background. Only local rule state is touched; activities are
+// not re-fetched from Strava and stats are not recomputed.
+func (s *Server) reapplyHideRulesAsync(userID int64) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := s.reapplyHideRules(ctx, userID); err != nil {
+			log.Printf("hide rules reapply failed for user %d: %v", userID, err)
+		}
+	}()
+}
+
+func (s *Server) reapplyHideRules(ctx context.Context, userID int64) error {
+	activityIDs, err := s.store.ListActivityIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, activityID := range activityIDs {
+		activity, err := s.store.GetActivity(ctx, activityID)
+		if err != nil {
+			return err
+		}
+		hideRuleID, _, err := s.evaluateHideRules(ctx, activity)
+		if err != nil {
+			return err
+		}
+		if hideRuleID == activity.HiddenByRuleID && (hideRuleID != 0) == activity.HiddenByRule {
+			continue
+		}
+		if err := s.store.SetActivityHiddenByRule(ctx, activityID, hideRuleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateHideRules returns the id of the first enabled rule that hides the
+// activity, or 0 when none does.
+func (s *Server) evaluateHideRules(ctx context.Context, activity storage.Activity) (int64, stats.StopStats, error) {
 	statsSnapshot, err := s.loadStatsSnapshot(ctx, activity.ID)
 	if err != nil {
-		return false, stats.StopStats{}, err
+		return 0, stats.StopStats{}, err
 	}
 	ruleRows, err := s.store.ListHideRules(ctx, activity.UserID)
 	if err != nil {
-		return false, stats.StopStats{}, err
+		return 0, stats.StopStats{}, err
 	}
 
 	reg := rules.DefaultRegistry()
-	startUnix := int64(0)
-	if !activity.StartTime.IsZero() {
-		startUnix = activity.StartTime.Unix()
-	}
-	ctxData := rules.Context{
-		Activity: rules.ActivitySource{
-			ID:          activity.ID,
-			Type:        activity.Type,
-			Name:        activity.Name,
-			StartUnix:   startUnix,
-			DistanceM:   activity.Distance,
-			MovingTimeS: activity.MovingTime,
-		},
-		Stats: rules.StatsSource{
-			StopCount:             statsSnapshot.StopCount,
-			StopTotalSeconds:      statsSnapshot.StopTotalSeconds,
-			TrafficLightStopCount: statsSnapshot.TrafficLightStopCount,
-			RoadCrossingCount:     statsSnapshot.RoadCrossingCount,
-		},
-	}
-
-	hide := false
+	ctxData := rules.BuildRuleContext(activity, statsSnapshot)
+
 	for _, ruleRow := range ruleRows {
 		if !ruleRow.Enabled {
 			continue
@@ -430,12 +469,11 @@ func (s *Server) evaluateHideRules(ctx context.Context, activity storage.Activit
 			continue
 		}
 		if matched && shouldHide {
-			hide = true
-			break
+			return ruleRow.ID, statsSnapshot, nil
 		}
 	}
 
-	return hide, statsSnapshot, nil
+	return 0, statsSnapshot, nil
 }
 
 func (s *Server) loadStatsSnapshot(ctx context.Context, activityID int64) (stats.StopStats, error) {
@@ -460,6 +498,9 @@ func stopStatsFromStops(stops []storage.ActivityStop) stats.StopStats {
 	}
 	for _, stop := range stops {
 		snapshot.StopTotalSeconds += stop.DurationSeconds
+		if stop.DurationSeconds > snapshot.LongestStopSeconds {
+			snapshot.LongestStopSeconds = stop.DurationSeconds
+		}
 		if stop.HasTrafficLight {
 			snapshot.TrafficLightStopCount++
 		}
@@ -473,6 +514,14 @@ func stopStatsFromStops(stops []storage.ActivityStop) stats.StopStats {
 const weirdStatsPrefix = "Weirdstats:"
 const weirdstatsTag = "#weirdstats"
 
+// Earlier releases wrapped the managed line in HTML comment markers, which
+// Strava shows verbatim. They are only read now, and unwrapped on the next
+// sync.
+const (
+	legacyWeirdstatsBlockStart = "<!--weirdstats-->"
+	legacyWeirdstatsBlockEnd   = "<!--/weirdstats-->"
+)
+
 func applyWeirdStatsDescription(existing string, statsSnapshot stats.StopStats, rideFact rideSegmentFact, speedFacts []speedMilestoneFact, coffeeFact coffeeStopFact, routeFact routeHighlightFact, roadFact roadCrossingFact) (string, bool) {
 	return applyWeirdStatsDescriptionWithHeartRate(existing, statsSnapshot, rideFact, speedFacts, heartRateChangeFact{}, coffeeFact, routeFact, roadFact)
 }
@@ -482,34 +531,65 @@ func applyWeirdStatsDescriptionWithHeartRate(existing string, statsSnapshot stat
 	return applyWeirdStatsDescriptionLine(existing, line)
 }
 
+// applyWeirdStatsDescriptionLine writes line into the description as a plain
+// "... #weirdstats" line. Strava descriptions are plain text, so our line is
+// found by its content: the first managed line is replaced in place, keeping
+// the text around it, blank lines included, exactly as the user left it.
+// Further managed lines are leftovers from older syncs and are dropped.
 func applyWeirdStatsDescriptionLine(existing, line string) (string, bool) {
-	normalized := strings.ReplaceAll(existing, "\r\n", "\n")
-	lines := strings.Split(normalized, "\n")
-	filtered := make([]string, 0, len(lines))
-	hadManagedLine := false
+	text := stripLegacyWeirdstatsMarkers(existing)
+	lines := strings.SplitAfter(text, "\n")
+	kept := make([]string, 0, len(lines))
+	managed := -1
 	for _, l := range lines {
 		if isWeirdstatsManagedLine(l) {
-			hadManagedLine = true
+			if managed < 0 {
+				managed = len(kept)
+				kept = append(kept, l)
+			}
 			continue
 		}
-		filtered = append(filtered, l)
-	}
-
-	base := strings.TrimRight(strings.Join(filtered, "\n"), "\n")
-	if line == "" {
-		if !hadManagedLine {
-			return existing, false
+		kept = append(kept, l)
+	}
+
+	var updated string
+	switch {
+	case managed >= 0 && line != "":
+		kept[managed] = appendWeirdstatsTag(line) + lineEnding(kept[managed])
+		updated = strings.Join(kept, "")
+	case managed >= 0:
+		before, after := strings.Join(kept[:managed], ""), strings.Join(kept[managed+1:], "")
+		if strings.TrimSpace(after) == "" {
+			updated = strings.TrimRight(before, " \t\r\n")
+		} else {
+			updated = before + after
+		}
+	case line == "":
+		updated = text
+	default:
+		updated = appendWeirdstatsTag(line)
+		if base := strings.TrimRight(text, " \t\r\n"); strings.TrimSpace(base) != "" {
+			updated = base + "\n\n" + updated
 		}
-		return base, base != existing
 	}
+	return updated, updated != existing
+}
 
-	updated := line
-	if strings.TrimSpace(base) != "" {
-		updated = base + "\n\n" + line
-	}
-	updated = appendWeirdstatsTag(updated)
+// stripLegacyWeirdstatsMarkers removes the comment markers, leaving the line
+// they wrapped in place.
+func stripLegacyWeirdstatsMarkers(text string) string {
+	text = strings.ReplaceAll(text, legacyWeirdstatsBlockStart, "")
+	return strings.ReplaceAll(text, legacyWeirdstatsBlockEnd, "")
+}
 
-	return updated, updated != existing
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
 }
 
 func buildWeirdStatsLine(statsSnapshot stats.StopStats, rideFact rideSegmentFact, speedFacts []speedMilestoneFact, coffeeFact coffeeStopFact, routeFact routeHighlightFact, roadFact roadCrossingFact) string {
@@ -646,10 +726,10 @@ func appendWeirdstatsTag(text string) string {
 }
 
 func splitStoredActivityDescription(description string) (string, int) {
-	normalized := strings.ReplaceAll(description, "\r\n", "\n")
+	detectedFactCount := 0
+	normalized := strings.ReplaceAll(stripLegacyWeirdstatsMarkers(description), "\r\n", "\n")
 	lines := strings.Split(normalized, "\n")
 	baseLines := make([]string, 0, len(lines))
-	detectedFactCount := 0
 	for _, line := range lines {
 		if isWeirdstatsManagedLine(line) {
 			detectedFactCount += countDetectedFactsInLine(line)
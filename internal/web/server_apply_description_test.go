@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+	"weirdstats/internal/strava"
+)
+
+func TestApply_WritesStravaDescriptionOnlyWhenAllowed(t *testing.T) {
+	cases := []struct {
+		name      string
+		scope     string
+		disabled  bool
+		wantWrite bool
+	}{
+		{name: "enabled with write scope", scope: "read,activity:read_all,activity:write", wantWrite: true},
+		{name: "legacy token without recorded scope", wantWrite: true},
+		{name: "missing write scope", scope: "read,activity:read_all"},
+		{name: "feature flag off", scope: "read,activity:read_all,activity:write", disabled: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.Open(":memory:")
+			if err != nil {
+				t.Fatalf("open store: %v", err)
+			}
+			defer store.Close()
+			if err := store.InitSchema(ctx); err != nil {
+				t.Fatalf("init schema: %v", err)
+			}
+			if err := store.UpsertStravaToken(ctx, storage.StravaToken{
+				UserID:      1,
+				AccessToken: "token",
+				ExpiresAt:   time.Now().Add(time.Hour),
+				Scope:       tc.scope,
+			}); err != nil {
+				t.Fatalf("upsert token: %v", err)
+			}
+
+			start := time.Date(2026, time.March, 23, 8, 0, 0, 0, time.UTC)
+			activityID, err := store.InsertActivity(ctx, storage.Activity{
+				UserID:    1,
+				Type:      "Run",
+				Name:      "Morning Run",
+				StartTime: start,
+				Distance:  5000,
+			}, nil)
+			if err != nil {
+				t.Fatalf("insert activity: %v", err)
+			}
+			if err := store.UpsertActivityStats(ctx, activityID, stats.StopStats{StopCount: 3, StopTotalSeconds: 120, UpdatedAt: time.Now()}); err != nil {
+				t.Fatalf("upsert stats: %v", err)
+			}
+
+			var puts []string
+			stravaAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != fmt.Sprintf("/api/activities/%d", activityID) {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if r.Method == http.MethodPut {
+					if err := r.ParseForm(); err != nil {
+						t.Errorf("parse form: %v", err)
+					}
+					puts = append(puts, r.PostForm.Get("description"))
+				}
+				_, _ = fmt.Fprintf(w, `{"id":%d,"name":"Morning Run","type":"Run","start_date":"2026-03-23T08:00:00Z","distance":5000,"description":"Easy pace"}`, activityID)
+			}))
+			defer stravaAPI.Close()
+
+			server, err := NewServer(store, nil, nil, nil, gps.StopOptions{}, StravaConfig{
+				Clients:                 &strava.ClientFactory{Store: store, BaseURL: stravaAPI.URL + "/api"},
+				DisableDescriptionWrite: tc.disabled,
+			})
+			if err != nil {
+				t.Fatalf("new server: %v", err)
+			}
+			if err := server.Apply(ctx, activityID); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+
+			if !tc.wantWrite {
+				if len(puts) != 0 {
+					t.Fatalf("expected no Strava description write, got %q", puts)
+				}
+				return
+			}
+			if len(puts) != 1 {
+				t.Fatalf("expected one Strava description write, got %d", len(puts))
+			}
+			if !strings.HasPrefix(puts[0], "Easy pace\n\n") || !strings.HasSuffix(puts[0], "3 stops (2m 0s total) #weirdstats") {
+				t.Fatalf("expected weirdstats line appended to description, got %q", puts[0])
+			}
+		})
+	}
+}
@@ -186,6 +186,153 @@ func TestActivities_FiltersFeedByDay(t *testing.T) {
 	}
 }
 
+func TestActivities_FiltersFeedByDateRange(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "range-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 313, AccessToken: "token", AthleteID: 313}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	oldLocal := time.Local
+	time.Local = time.UTC
+	defer func() {
+		time.Local = oldLocal
+	}()
+
+	day := time.Date(2026, time.April, 10, 0, 0, 0, 0, time.UTC)
+	for _, activity := range []storage.Activity{
+		{UserID: 313, Type: "Ride", Name: "Before Trip", StartTime: day.Add(-time.Hour)},
+		{UserID: 313, Type: "Ride", Name: "Trip Day One", StartTime: day.Add(9 * time.Hour)},
+		{UserID: 313, Type: "Ride", Name: "Trip Last Evening", StartTime: day.AddDate(0, 0, 2).Add(23 * time.Hour)},
+		{UserID: 313, Type: "Ride", Name: "After Trip", StartTime: day.AddDate(0, 0, 3).Add(time.Hour)},
+	} {
+		if _, err := store.InsertActivity(ctx, activity, nil); err != nil {
+			t.Fatalf("insert activity %q: %v", activity.Name, err)
+		}
+	}
+
+	render := func(query string) string {
+		req := sessionRequest(t, server, 313)
+		req.URL, _ = req.URL.Parse("/activities/" + query)
+		rec := httptest.NewRecorder()
+		server.Activities(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := render("?from=2026-04-10&to=2026-04-12")
+	for _, want := range []string{"Trip Day One", "Trip Last Evening", "Apr 10, 2026 – Apr 12, 2026"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in range view", want)
+		}
+	}
+	for _, unwanted := range []string{"Before Trip", "After Trip"} {
+		if strings.Contains(body, unwanted) {
+			t.Fatalf("did not expect %q in range view", unwanted)
+		}
+	}
+
+	body = render("?from=2026-04-12&to=2026-04-10")
+	for _, want := range []string{"Invalid date range; showing latest activities.", "Before Trip", "After Trip"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q when falling back from an invalid range", want)
+		}
+	}
+	if body = render("?from=April"); !strings.Contains(body, "Invalid date range") {
+		t.Fatalf("expected invalid date message for unparsable from")
+	}
+}
+
+func TestActivities_SortsFeedByWhitelistedKey(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "sort-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 314, AccessToken: "token", AthleteID: 314}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.April, 10, 8, 0, 0, 0, time.UTC)
+	for _, activity := range []storage.Activity{
+		{UserID: 314, Type: "Ride", Name: "Short Spin", StartTime: start, Distance: 3000},
+		{UserID: 314, Type: "Ride", Name: "Long Haul", StartTime: start.Add(time.Hour), Distance: 90000},
+	} {
+		if _, err := store.InsertActivity(ctx, activity, nil); err != nil {
+			t.Fatalf("insert activity %q: %v", activity.Name, err)
+		}
+	}
+
+	render := func(query string) string {
+		req := sessionRequest(t, server, 314)
+		req.URL, _ = req.URL.Parse("/activities/" + query)
+		rec := httptest.NewRecorder()
+		server.Activities(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := render("?sort=distance_asc")
+	if strings.Index(body, "Short Spin") > strings.Index(body, "Long Haul") {
+		t.Fatalf("expected shortest activity first for distance_asc")
+	}
+	if !strings.Contains(body, `<option value="distance_asc" selected>`) {
+		t.Fatalf("expected distance_asc to be selected")
+	}
+	body = render("?sort=name")
+	if strings.Index(body, "Long Haul") > strings.Index(body, "Short Spin") {
+		t.Fatalf("expected unknown sort to fall back to newest first")
+	}
+
+	body = render("?q=haul")
+	if !strings.Contains(body, "Long Haul") || strings.Contains(body, "Short Spin") {
+		t.Fatalf("expected search to only show matching activity names")
+	}
+	if body = render("?q=gravel"); !strings.Contains(body, "No matching activities") {
+		t.Fatalf("expected empty search state")
+	}
+}
+
+func TestActivities_HiddenViewShowsResponsibleRule(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "hidden-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 315, AccessToken: "token", AthleteID: 315}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	now := time.Date(2026, time.April, 10, 8, 0, 0, 0, time.UTC)
+	ruleID, err := store.CreateHideRule(ctx, storage.HideRule{UserID: 315, Name: "Short commutes", Condition: `{}`, Enabled: true, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	hiddenID, err := store.InsertActivity(ctx, storage.Activity{UserID: 315, Type: "Ride", Name: "Commute Home", StartTime: now}, nil)
+	if err != nil {
+		t.Fatalf("insert hidden activity: %v", err)
+	}
+	if _, err := store.InsertActivity(ctx, storage.Activity{UserID: 315, Type: "Ride", Name: "Weekend Loop", StartTime: now.Add(time.Hour)}, nil); err != nil {
+		t.Fatalf("insert visible activity: %v", err)
+	}
+	if err := store.SetActivityHiddenByRule(ctx, hiddenID, ruleID); err != nil {
+		t.Fatalf("set hidden: %v", err)
+	}
+
+	req := sessionRequest(t, server, 315)
+	req.URL, _ = req.URL.Parse("/activities/?hidden=1")
+	rec := httptest.NewRecorder()
+	server.Activities(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Commute Home", "Hidden by “Short commutes”", "Activities hidden by rules"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in hidden view", want)
+		}
+	}
+	if strings.Contains(body, "Weekend Loop") {
+		t.Fatalf("did not expect visible activity in hidden view")
+	}
+}
+
 func TestActivities_ShowsStravaDescriptionAndDetectedFactCount(t *testing.T) {
 	ctx := context.Background()
 	store, err := storage.Open(":memory:")
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"weirdstats/internal/stats"
+)
+
+const (
+	descriptionTemplateSettingKey = "description_template"
+	defaultDescriptionTemplate    = "{{.Facts}}"
+	maxDescriptionTemplateLength  = 200
+)
+
+// descriptionTemplateData is everything a user template can reference.
+// Templates may only print these fields; actions, pipelines and builtins such
+// as printf are rejected.
+type descriptionTemplateData struct {
+	Facts             string
+	StopCount         int
+	StopTotal         string
+	TrafficLightStops int
+	RoadCrossings     int
+}
+
+type descriptionTemplatePreset struct {
+	Label    string
+	Template string
+}
+
+var descriptionTemplatePresets = []descriptionTemplatePreset{
+	{Label: "Detected facts (default)", Template: defaultDescriptionTemplate},
+	{Label: "Stops with traffic lights", Template: "{{.StopCount}} stops ({{.StopTotal}} total) · {{.TrafficLightStops}} at lights"},
+	{Label: "Stops only", Template: "{{.StopCount}} stops ({{.StopTotal}} total)"},
+}
+
+var sampleDescriptionTemplateData = descriptionTemplateData{
+	Facts:             "2 stops (42s total) · 1 at lights",
+	StopCount:         2,
+	StopTotal:         "42s",
+	TrafficLightStops: 1,
+	RoadCrossings:     1,
+}
+
+// parseDescriptionTemplate validates a user template: a single line that only
+// references descriptionTemplateData fields and renders something we can
+// recognize as our own line on the next sync.
+func parseDescriptionTemplate(text string) (*template.Template, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("template is empty")
+	}
+	if len(text) > maxDescriptionTemplateLength {
+		return nil, errors.New("template is too long")
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		return nil, errors.New("template must be a single line")
+	}
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if !onlyFieldReferences(tmpl.Tree.Root) {
+		return nil, errors.New("template may only use fields like {{.StopCount}}")
+	}
+	line, err := executeDescriptionTemplate(tmpl, sampleDescriptionTemplateData)
+	if err != nil {
+		return nil, err
+	}
+	if !isWeirdstatsManagedLine(appendWeirdstatsTag(line)) {
+		return nil, errors.New("template must include a stats field such as {{.Facts}} or {{.StopCount}} stops")
+	}
+	return tmpl, nil
+}
+
+func onlyFieldReferences(root *parse.ListNode) bool {
+	for _, node := range root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+				return false
+			}
+			field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+			if !ok || len(field.Ident) != 1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func executeDescriptionTemplate(tmpl *template.Template, data descriptionTemplateData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
+
+// renderDescriptionLine formats the facts line with the user's template,
+// falling back to the plain facts line when the template is unusable.
+func renderDescriptionLine(templateText, factsLine string, snapshot stats.StopStats) string {
+	if factsLine == "" {
+		return ""
+	}
+	templateText = strings.TrimSpace(templateText)
+	if templateText == "" || templateText == defaultDescriptionTemplate {
+		return factsLine
+	}
+	tmpl, err := parseDescriptionTemplate(templateText)
+	if err != nil {
+		return factsLine
+	}
+	line, err := executeDescriptionTemplate(tmpl, descriptionTemplateData{
+		Facts:             factsLine,
+		StopCount:         snapshot.StopCount,
+		StopTotal:         formatDuration(snapshot.StopTotalSeconds),
+		TrafficLightStops: snapshot.TrafficLightStopCount,
+		RoadCrossings:     snapshot.RoadCrossingCount,
+	})
+	if err != nil || line == "" || !isWeirdstatsManagedLine(appendWeirdstatsTag(line)) {
+		return factsLine
+	}
+	return line
+}
+
+func (s *Server) renderUserDescriptionLine(ctx context.Context, userID int64, factsLine string, snapshot stats.StopStats) string {
+	templateText, err := s.store.GetUserSetting(ctx, userID, descriptionTemplateSettingKey)
+	if err != nil {
+		log.Printf("description template load failed for user %d: %v", userID, err)
+	}
+	return renderDescriptionLine(templateText, factsLine, snapshot)
+}
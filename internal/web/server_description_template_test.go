@@ -0,0 +1,69 @@
+package web
+
+import (
+	"testing"
+
+	"weirdstats/internal/stats"
+)
+
+func TestRenderDescriptionLineTemplates(t *testing.T) {
+	snapshot := stats.StopStats{StopCount: 3, StopTotalSeconds: 95, TrafficLightStopCount: 2}
+	facts := "3 stops (1m 35s total) · 2 at lights"
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{name: "unset uses facts line", template: "", want: facts},
+		{name: "default template", template: defaultDescriptionTemplate, want: facts},
+		{name: "stops with lights", template: "{{.StopCount}} stops ({{.StopTotal}} total) · {{.TrafficLightStops}} at lights", want: "3 stops (1m 35s total) · 2 at lights"},
+		{name: "stops only", template: "{{.StopCount}} stops ({{.StopTotal}} total)", want: "3 stops (1m 35s total)"},
+		{name: "broken template falls back", template: "{{.StopCount", want: facts},
+		{name: "unknown field falls back", template: "{{.Missing}} stops", want: facts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderDescriptionLine(tt.template, facts, snapshot); got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := renderDescriptionLine("{{.StopCount}} stops", "", snapshot); got != "" {
+		t.Fatalf("expected no line without facts, got %q", got)
+	}
+}
+
+func TestParseDescriptionTemplateRejectsUnsafeTemplates(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"{{.StopCount}} stops\nsecond line",
+		"{{printf \"%d\" .StopCount}} stops",
+		"{{.Missing}}",
+		"Nice ride",
+	} {
+		if _, err := parseDescriptionTemplate(text); err == nil {
+			t.Fatalf("expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestTemplatedDescriptionLineReplacesPreviousLine(t *testing.T) {
+	snapshot := stats.StopStats{StopCount: 2, StopTotalSeconds: 42, TrafficLightStopCount: 1}
+	existing := "Morning ride\n\n3 stops (1m 35s total) · 2 at lights #weirdstats"
+	line := renderDescriptionLine("{{.StopCount}} stops ({{.StopTotal}} total)", "2 stops (42s total) · 1 at lights", snapshot)
+
+	got, changed := applyWeirdStatsDescriptionLine(existing, line)
+	if !changed {
+		t.Fatalf("expected description to change")
+	}
+	if want := "Morning ride\n\n2 stops (42s total) #weirdstats"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	again, changed := applyWeirdStatsDescriptionLine(got, line)
+	if changed || again != got {
+		t.Fatalf("expected re-applying the same template to be a no-op, got %q", again)
+	}
+}
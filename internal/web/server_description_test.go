@@ -65,7 +65,18 @@ func TestApplyWeirdStatsDescription(t *testing.T) {
 			coffeeFact: coffeeFact,
 			routeFact:  routeFact,
 			roadFact:   roadFact,
-			want:       "First paragraph.\n\nSecond paragraph.\n\n" + line,
+			want:       "First paragraph.\n\nSecond paragraph.\n" + line,
+			changed:    true,
+		},
+		{
+			name:       "unwraps a line written with legacy markers",
+			existing:   "Morning ride with intervals\n\n" + legacyWeirdstatsBlockStart + line + legacyWeirdstatsBlockEnd,
+			stats:      snapshot,
+			rideFact:   rideFact,
+			coffeeFact: coffeeFact,
+			routeFact:  routeFact,
+			roadFact:   roadFact,
+			want:       "Morning ride with intervals\n\n" + line,
 			changed:    true,
 		},
 		{
@@ -170,6 +181,62 @@ func TestApplyWeirdStatsDescription_RemovesManagedLineWhenNoFactsRemain(t *testi
 	}
 }
 
+func TestApplyWeirdStatsDescription_UpdatesLineInPlace(t *testing.T) {
+	snapshot := stats.StopStats{StopCount: 2, StopTotalSeconds: 42, TrafficLightStopCount: 1}
+	oldLine := "3 stops (1m 35s total) · 2 at lights #weirdstats"
+	newLine := "2 stops (42s total) · 1 at lights #weirdstats"
+
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{
+			name:     "line at the end keeps user blank lines above",
+			existing: "Morning ride\n\n\n\nlegs felt good\n\n" + oldLine,
+			want:     "Morning ride\n\n\n\nlegs felt good\n\n" + newLine,
+		},
+		{
+			name:     "line in the middle leaves text after it untouched",
+			existing: "Intro\n" + oldLine + "\n\n\nPS: great coffee\n",
+			want:     "Intro\n" + newLine + "\n\n\nPS: great coffee\n",
+		},
+		{
+			name:     "Windows line endings are kept",
+			existing: "Line one\r\n\r\n" + oldLine + "\r\nafter",
+			want:     "Line one\r\n\r\n" + newLine + "\r\nafter",
+		},
+		{
+			name:     "legacy marked block is unwrapped in place",
+			existing: "Intro\n" + legacyWeirdstatsBlockStart + oldLine + legacyWeirdstatsBlockEnd + "\nafter",
+			want:     "Intro\n" + newLine + "\nafter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := applyWeirdStatsDescription(tt.existing, snapshot, rideSegmentFact{}, nil, coffeeStopFact{}, routeHighlightFact{}, roadCrossingFact{})
+			if got != tt.want {
+				t.Fatalf("unexpected description\nwant: %q\n got: %q", tt.want, got)
+			}
+			if !changed {
+				t.Fatalf("expected description to change")
+			}
+			again, changed := applyWeirdStatsDescription(got, snapshot, rideSegmentFact{}, nil, coffeeStopFact{}, routeHighlightFact{}, roadCrossingFact{})
+			if changed || again != got {
+				t.Fatalf("expected second sync to be a no-op, got %q", again)
+			}
+		})
+	}
+}
+
+func TestApplyWeirdStatsDescription_RemovesMarkedBlockWhenNoFactsRemain(t *testing.T) {
+	block := legacyWeirdstatsBlockStart + "2 stops (42s total) #weirdstats" + legacyWeirdstatsBlockEnd
+	got, changed := applyWeirdStatsDescription("Morning ride\n\n"+block, stats.StopStats{}, rideSegmentFact{}, nil, coffeeStopFact{}, routeHighlightFact{}, roadCrossingFact{})
+	if got != "Morning ride" || !changed {
+		t.Fatalf("expected trailing block removed, got %q (changed=%v)", got, changed)
+	}
+}
+
 func TestFilterWeirdStatsSnapshot(t *testing.T) {
 	snapshot := stats.StopStats{
 		StopCount:             3,
@@ -494,6 +561,12 @@ func TestSplitStoredActivityDescription(t *testing.T) {
 			wantText:    "Met up with Sam at the cafe.",
 			wantCount:   2,
 		},
+		{
+			name:        "strips marked block and counts its facts",
+			description: "Met up with Sam.\n\n" + legacyWeirdstatsBlockStart + "2 stops (42s total) · 1 at lights #weirdstats" + legacyWeirdstatsBlockEnd,
+			wantText:    "Met up with Sam.",
+			wantCount:   2,
+		},
 		{
 			name:        "counts legacy managed line",
 			description: "Weirdstats: 2 stops (42s total)",
@@ -578,6 +651,9 @@ func TestStopStatsFromStops(t *testing.T) {
 	if got.StopTotalSeconds != 70 {
 		t.Fatalf("expected 70 total seconds, got %d", got.StopTotalSeconds)
 	}
+	if got.LongestStopSeconds != 35 {
+		t.Fatalf("expected 35 longest stop seconds, got %d", got.LongestStopSeconds)
+	}
 	if got.TrafficLightStopCount != 2 {
 		t.Fatalf("expected 2 traffic-light stops, got %d", got.TrafficLightStopCount)
 	}
@@ -0,0 +1,81 @@
+package web
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const exportPageSize = 500
+
+var exportCSVHeader = []string{
+	"id",
+	"name",
+	"type",
+	"start_time",
+	"distance_m",
+	"moving_time_s",
+	"stop_count",
+	"stop_total_seconds",
+	"traffic_light_stops",
+}
+
+// ExportCSV streams every activity of the signed-in user as CSV, one page of
+// rows at a time.
+func (s *Server) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/activities/export.csv" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := s.store.ListActivitiesWithStatsPage(r.Context(), userID, 0, exportPageSize)
+	if err != nil {
+		http.Error(w, "failed to load activities", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="weirdstats-activities.csv"`)
+	writer := csv.NewWriter(w)
+	_ = writer.Write(exportCSVHeader)
+
+	for offset := 0; len(page) > 0; {
+		for _, item := range page {
+			_ = writer.Write([]string{
+				strconv.FormatInt(item.ID, 10),
+				item.Name,
+				item.Type,
+				item.StartTime.UTC().Format(time.RFC3339),
+				strconv.FormatFloat(item.Distance, 'f', 1, 64),
+				strconv.Itoa(item.MovingTime),
+				strconv.Itoa(item.StopCount),
+				strconv.Itoa(item.StopTotalSeconds),
+				strconv.Itoa(item.TrafficLightStopCount),
+			})
+		}
+		writer.Flush()
+		if len(page) < exportPageSize {
+			break
+		}
+		offset += len(page)
+		page, err = s.store.ListActivitiesWithStatsPage(r.Context(), userID, offset, exportPageSize)
+		if err != nil {
+			// Headers are already sent; the truncated file is the best we can do.
+			log.Printf("csv export: user %d page at offset %d failed: %v", userID, offset, err)
+			return
+		}
+	}
+	if err := writer.Error(); err != nil {
+		log.Printf("csv export: user %d write failed: %v", userID, err)
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+)
+
+func TestExportCSV_StreamsAllActivities(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "export-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 41, AccessToken: "token", AthleteID: 41}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	start := time.Date(2026, time.January, 1, 8, 0, 0, 0, time.UTC)
+	total := exportPageSize + 2
+	for i := 0; i < total; i++ {
+		name := "Ride"
+		if i == total-1 {
+			name = `Coffee, then "hills"`
+		}
+		if _, err := store.InsertActivity(ctx, storage.Activity{
+			ID:         int64(1000 + i),
+			UserID:     41,
+			Type:       "Ride",
+			Name:       name,
+			StartTime:  start.Add(time.Duration(i) * time.Hour),
+			Distance:   12345.67,
+			MovingTime: 1800,
+		}, nil); err != nil {
+			t.Fatalf("insert activity: %v", err)
+		}
+	}
+	if err := store.UpsertActivityStats(ctx, int64(1000+total-1), stats.StopStats{
+		StopCount:             3,
+		StopTotalSeconds:      95,
+		TrafficLightStopCount: 2,
+	}); err != nil {
+		t.Fatalf("upsert stats: %v", err)
+	}
+	if _, err := store.InsertActivity(ctx, storage.Activity{ID: 5000, UserID: 42, Type: "Run", Name: "Not mine", StartTime: start}, nil); err != nil {
+		t.Fatalf("insert other user's activity: %v", err)
+	}
+
+	req := sessionRequest(t, server, 41)
+	req.URL.Path = "/activities/export.csv"
+	rec := httptest.NewRecorder()
+	server.ExportCSV(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, "attachment") {
+		t.Fatalf("expected attachment disposition, got %q", got)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(records) != total+1 {
+		t.Fatalf("expected header plus %d rows, got %d", total, len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(exportCSVHeader, ",") {
+		t.Fatalf("unexpected header: %v", records[0])
+	}
+	newest := records[1]
+	want := []string{"1501", `Coffee, then "hills"`, "Ride", "2026-01-22T05:00:00Z", "12345.7", "1800", "3", "95", "2"}
+	if strings.Join(newest, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected newest row:\n got %q\nwant %q", newest, want)
+	}
+	seen := map[string]bool{}
+	for _, record := range records[1:] {
+		if seen[record[0]] {
+			t.Fatalf("activity %s exported twice", record[0])
+		}
+		seen[record[0]] = true
+	}
+	if seen["5000"] {
+		t.Fatalf("exported another user's activity")
+	}
+}
@@ -87,6 +87,10 @@ func buildPointsFromStreams(start time.Time, streams strava.StreamSet) []gps.Poi
 			point.HeartRate = streams.Heartrate[idx]
 			point.HasHeartRate = true
 		}
+		if idx < len(streams.Altitude) {
+			point.Altitude = streams.Altitude[idx]
+			point.HasAltitude = true
+		}
 		points = append(points, point)
 	}
 	return points
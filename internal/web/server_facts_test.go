@@ -283,9 +283,7 @@ func TestBuildRouteHighlightCandidates(t *testing.T) {
 
 	pois := []maps.POI{
 		{
-			Feature: maps.Feature{Name: "Brandenburg Gate"},
-			Lat:     52.5201,
-			Lon:     13.4055,
+			Feature: maps.Feature{Name: "Brandenburg Gate", Lat: 52.5201, Lon: 13.4055},
 			Tags: map[string]string{
 				"tourism":   "attraction",
 				"wikidata":  "Q82494",
@@ -293,17 +291,13 @@ func TestBuildRouteHighlightCandidates(t *testing.T) {
 			},
 		},
 		{
-			Feature: maps.Feature{Name: "Neighborhood Church"},
-			Lat:     52.5206,
-			Lon:     13.4062,
+			Feature: maps.Feature{Name: "Neighborhood Church", Lat: 52.5206, Lon: 13.4062},
 			Tags: map[string]string{
 				"building": "church",
 			},
 		},
 		{
-			Feature: maps.Feature{Name: "Far Museum"},
-			Lat:     52.5230,
-			Lon:     13.4060,
+			Feature: maps.Feature{Name: "Far Museum", Lat: 52.5230, Lon: 13.4060},
 			Tags: map[string]string{
 				"tourism":   "museum",
 				"wikidata":  "Q1",
@@ -311,9 +305,7 @@ func TestBuildRouteHighlightCandidates(t *testing.T) {
 			},
 		},
 		{
-			Feature: maps.Feature{Name: "Brandenburg Gate"},
-			Lat:     52.5202,
-			Lon:     13.4056,
+			Feature: maps.Feature{Name: "Brandenburg Gate", Lat: 52.5202, Lon: 13.4056},
 			Tags: map[string]string{
 				"tourism": "attraction",
 			},
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/storage"
+)
+
+func TestHealthz(t *testing.T) {
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	server, err := NewServer(store, nil, nil, nil, gps.StopOptions{}, StravaConfig{})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("expected 200 ok, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	_ = store.Close()
+	rec = httptest.NewRecorder()
+	server.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with closed store, got %d", rec.Code)
+	}
+}
+
+func TestUsersCount(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "session-secret", "")
+	for _, id := range []int64{4, 5, 6} {
+		if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: id, AccessToken: "token", AthleteID: id * 100, AthleteName: "Rider"}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.UsersCount(rec, httptest.NewRequest(http.MethodGet, "/stats/users", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["count"] != float64(3) {
+		t.Fatalf("expected count 3, got %v", body)
+	}
+	if strings.Contains(rec.Body.String(), "Rider") {
+		t.Fatalf("users endpoint leaked athlete names: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.UsersCount(rec, httptest.NewRequest(http.MethodPost, "/stats/users", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ActivityMapData backs the full-page map; the track and stops are fetched
+// from GeoJSONURL by the page itself.
+type ActivityMapData struct {
+	PageData
+	ActivityID   int64
+	ActivityName string
+	StartTime    string
+	GeoJSONURL   string
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// ActivityMap renders /activity/{id}/map.
+func (s *Server) ActivityMap(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+	activityID, err := posterActivityID(r.URL.Path, "/map")
+	if err != nil {
+		http.Error(w, "invalid activity id", http.StatusBadRequest)
+		return
+	}
+	activity, err := s.store.GetActivityForUser(r.Context(), userID, activityID)
+	if err != nil {
+		http.Error(w, "activity not found", http.StatusNotFound)
+		return
+	}
+
+	data := ActivityMapData{
+		PageData: PageData{
+			Title:     activity.Name + " map",
+			Page:      "activity",
+			Strava:    s.getStravaInfo(r.Context(), userID),
+			UserCount: s.userCount(r.Context()),
+		},
+		ActivityID:   activityID,
+		ActivityName: activity.Name,
+		StartTime:    activity.StartTime.Format(time.RFC1123),
+		GeoJSONURL:   fmt.Sprintf("/activity/%d/geojson", activityID),
+	}
+	if err := s.templates["map"].ExecuteTemplate(w, "base", data); err != nil {
+		log.Printf("activity map render failed for activity %d: %v", activityID, err)
+		http.Error(w, "template render failed", http.StatusInternalServerError)
+	}
+}
+
+// ActivityGeoJSON serves /activity/{id}/geojson: the track as a LineString
+// followed by one Point per detected stop.
+func (s *Server) ActivityGeoJSON(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+	activityID, err := posterActivityID(r.URL.Path, "/geojson")
+	if err != nil {
+		http.Error(w, "invalid activity id", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.GetActivityForUser(r.Context(), userID, activityID); err != nil {
+		http.Error(w, "activity not found", http.StatusNotFound)
+		return
+	}
+	points, err := s.store.LoadActivityPoints(r.Context(), activityID)
+	if err != nil {
+		http.Error(w, "failed to load points", http.StatusInternalServerError)
+		return
+	}
+	storedStops, err := s.store.LoadActivityStops(r.Context(), activityID)
+	if err != nil {
+		http.Error(w, "failed to load stops", http.StatusInternalServerError)
+		return
+	}
+
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	if len(points) > 1 {
+		coords := make([][2]float64, 0, len(points))
+		for _, p := range points {
+			coords = append(coords, [2]float64{p.Lon, p.Lat})
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coords},
+			Properties: map[string]any{"kind": "track"},
+		})
+	}
+	for _, stop := range buildStopViews(storedStops) {
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: [2]float64{stop.Lon, stop.Lat}},
+			Properties: map[string]any{
+				"kind":                  "stop",
+				"duration":              stop.Duration,
+				"duration_seconds":      stop.DurationSeconds,
+				"has_traffic_light":     stop.HasTrafficLight,
+				"traffic_light_unknown": stop.TrafficLightUnknown,
+				"has_road_crossing":     stop.HasRoadCrossing,
+				"crossing_road":         stop.CrossingRoad,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	if err := json.NewEncoder(w).Encode(collection); err != nil {
+		log.Printf("activity geojson encode failed for activity %d: %v", activityID, err)
+	}
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/storage"
+)
+
+func TestActivityMap_RendersPageAndGeoJSON(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "map-secret", "")
+	for _, userID := range []int64{909, 910} {
+		if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: userID, AccessToken: "token", AthleteID: userID}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+	start := time.Date(2026, time.March, 24, 7, 30, 0, 0, time.UTC)
+	activityID, err := store.InsertActivity(ctx, storage.Activity{
+		UserID:    909,
+		Type:      "Ride",
+		Name:      "Map Route",
+		StartTime: start,
+	}, []gps.Point{
+		{Lat: 52.5200, Lon: 13.4040, Time: start, Speed: 7},
+		{Lat: 52.5205, Lon: 13.4050, Time: start.Add(2 * time.Minute), Speed: 0},
+		{Lat: 52.5210, Lon: 13.4062, Time: start.Add(4 * time.Minute), Speed: 7},
+	})
+	if err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	if err := store.ReplaceActivityStops(ctx, activityID, []storage.ActivityStop{
+		{Seq: 0, Lat: 52.5205, Lon: 13.4050, StartSeconds: 120, DurationSeconds: 35, HasTrafficLight: true},
+	}, time.Now()); err != nil {
+		t.Fatalf("replace stops: %v", err)
+	}
+	base := "/activity/" + strconv.FormatInt(activityID, 10)
+
+	get := func(path string, userID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, cookie := range sessionRequest(t, server, userID).Cookies() {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		server.Activity(rec, req)
+		return rec
+	}
+
+	rec := get(base+"/map", 909)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for map page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, text := range []string{"Map Route", base + "/geojson", "/static/leaflet/leaflet.js", `id="map"`} {
+		if !strings.Contains(body, text) {
+			t.Fatalf("expected map page to contain %q", text)
+		}
+	}
+
+	rec = get(base+"/geojson", 909)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for geojson, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/geo+json" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("decode geojson: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("expected a track and one stop, got %+v", collection)
+	}
+	var track [][2]float64
+	if err := json.Unmarshal(collection.Features[0].Geometry.Coordinates, &track); err != nil {
+		t.Fatalf("decode track: %v", err)
+	}
+	if collection.Features[0].Geometry.Type != "LineString" || len(track) != 3 || track[0] != [2]float64{13.4040, 52.5200} {
+		t.Fatalf("unexpected track %s %v", collection.Features[0].Geometry.Type, track)
+	}
+	stop := collection.Features[1]
+	if stop.Geometry.Type != "Point" || stop.Properties["has_traffic_light"] != true || stop.Properties["duration"] != formatDuration(35) {
+		t.Fatalf("unexpected stop feature %+v", stop)
+	}
+
+	if rec := get(base+"/map", 910); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's map, got %d", rec.Code)
+	}
+	if rec := get(base+"/geojson", 910); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's geojson, got %d", rec.Code)
+	}
+}
@@ -261,7 +261,7 @@ func (s *Server) StravaMobileCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := s.connectStravaUser(r.Context(), r.URL.Query().Get("code"))
+	userID, err := s.connectStravaUser(r.Context(), r.URL.Query().Get("code"), r.URL.Query().Get("scope"))
 	if err != nil {
 		http.Redirect(w, r, appendQueryValue(appRedirect, "error", compactForLog(err.Error(), 64)), http.StatusFound)
 		return
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	reprocessAllSettingKey = "reprocess_all_at"
+	reprocessAllCooldown   = 10 * time.Minute
+	reprocessAllPageSize   = 200
+)
+
+var errReprocessAllTooSoon = errors.New("reprocess already requested recently")
+
+// reprocessAllActivities queues every activity of the user for the stats
+// pipeline. It may run once per reprocessAllCooldown; the last run time is
+// kept as a user setting so the limit survives restarts.
+func (s *Server) reprocessAllActivities(ctx context.Context, userID int64, now time.Time) (int, error) {
+	claimed, err := s.store.ClaimUserSettingCooldown(ctx, userID, reprocessAllSettingKey, now, reprocessAllCooldown)
+	if err != nil {
+		return 0, err
+	}
+	if !claimed {
+		return 0, errReprocessAllTooSoon
+	}
+
+	queued := 0
+	for offset := 0; ; offset += reprocessAllPageSize {
+		page, err := s.store.ListActivitiesWithStatsPage(ctx, userID, offset, reprocessAllPageSize)
+		if err != nil {
+			return queued, err
+		}
+		for _, activity := range page {
+			if err := s.store.EnqueueActivity(ctx, activity.ID, userID); err != nil {
+				return queued, err
+			}
+			queued++
+		}
+		if len(page) < reprocessAllPageSize {
+			return queued, nil
+		}
+	}
+}
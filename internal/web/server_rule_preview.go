@@ -0,0 +1,77 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"weirdstats/internal/rules"
+)
+
+type rulePreviewResponse struct {
+	ActivityID   int64  `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	Matched      bool   `json:"matched"`
+	Hide         bool   `json:"hide"`
+	Description  string `json:"description"`
+}
+
+// RulePreview evaluates a posted rule against the user's most recent activity
+// without storing the rule.
+func (s *Server) RulePreview(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/rules/preview" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+	condition := strings.TrimSpace(r.FormValue("condition"))
+	if condition == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing rule condition"})
+		return
+	}
+	registry := rules.DefaultRegistry()
+	ruleDef, err := rules.ParseRuleJSON(condition)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid rule json: " + compactErrMessage(err)})
+		return
+	}
+	if err := rules.ValidateRule(ruleDef, registry); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid rule definition: " + compactErrMessage(err)})
+		return
+	}
+
+	activity, err := s.store.MostRecentActivity(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no activities to test against"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load activity"})
+		return
+	}
+	statsSnapshot, err := s.loadStatsSnapshot(r.Context(), activity.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load activity stats"})
+		return
+	}
+	matched, hide, err := rules.Evaluate(ruleDef, registry, rules.BuildRuleContext(activity, statsSnapshot), 0)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "rule evaluation failed: " + compactErrMessage(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, rulePreviewResponse{
+		ActivityID:   activity.ID,
+		ActivityName: activity.Name,
+		Matched:      matched,
+		Hide:         matched && hide,
+		Description:  rules.Describe(ruleDef, registry),
+	})
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/stats"
+	"weirdstats/internal/storage"
+)
+
+func postRulePreview(t *testing.T, server *Server, userID int64, condition string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{}
+	form.Set("condition", condition)
+	req := httptest.NewRequest(http.MethodPost, "/api/rules/preview", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range sessionRequest(t, server, userID).Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.RulePreview(rec, req)
+	return rec
+}
+
+func TestRulePreview_EvaluatesAgainstMostRecentActivity(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "preview-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 31, AccessToken: "token", AthleteID: 31}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	stopRule := `{"match":"all","conditions":[{"metric":"stop_count","op":"gt","values":[2]}],"action":{"type":"hide"}}`
+	if rec := postRulePreview(t, server, 31, stopRule); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without activities, got %d", rec.Code)
+	}
+
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	if _, err := store.InsertActivity(ctx, storage.Activity{
+		ID:        901,
+		UserID:    31,
+		Type:      "Ride",
+		Name:      "Commute home",
+		StartTime: start,
+		Distance:  8000,
+	}, nil); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	if err := store.UpsertActivityStats(ctx, 901, stats.StopStats{StopCount: 4}); err != nil {
+		t.Fatalf("upsert stats: %v", err)
+	}
+
+	rec := postRulePreview(t, server, 31, stopRule)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got rulePreviewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ActivityID != 901 || !got.Matched || !got.Hide {
+		t.Fatalf("expected activity 901 to match and hide, got %+v", got)
+	}
+	if got.Description == "" {
+		t.Fatalf("expected rule description")
+	}
+
+	rec = postRulePreview(t, server, 31, `{"match":"all","conditions":[{"metric":"distance_m","op":"gt","values":[20000]}],"action":{"type":"hide"}}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Matched || got.Hide {
+		t.Fatalf("expected long-ride rule not to match, got %+v", got)
+	}
+
+	rec = postRulePreview(t, server, 31, `{"match":"all","conditions":[{"metric":"banana_count","op":"gt","values":[1]}],"action":{"type":"hide"}}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid rule definition") {
+		t.Fatalf("expected 400 for invalid rule, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"weirdstats/internal/storage"
+)
+
+func TestRulesMetadata_ReturnsSortedMetricsAndOperators(t *testing.T) {
+	server, store := newSessionTestServer(t, "metadata-secret", "")
+	if err := store.UpsertStravaToken(context.Background(), storage.StravaToken{UserID: 21, AccessToken: "token", AthleteID: 21}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.RulesMetadata(rec, httptest.NewRequest(http.MethodGet, "/api/rules/metadata", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected anonymous request to redirect to sign-in, got %d", rec.Code)
+	}
+
+	req := sessionRequest(t, server, 21)
+	req.URL.Path = "/api/rules/metadata"
+	rec = httptest.NewRecorder()
+	server.RulesMetadata(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	var payload struct {
+		Metrics []struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		} `json:"metrics"`
+		Operators map[string][]struct {
+			ID string `json:"id"`
+		} `json:"operators"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if len(payload.Metrics) == 0 {
+		t.Fatalf("expected metrics")
+	}
+	if !sort.SliceIsSorted(payload.Metrics, func(i, j int) bool { return payload.Metrics[i].ID < payload.Metrics[j].ID }) {
+		t.Fatalf("expected metrics sorted by id")
+	}
+	for _, metric := range payload.Metrics {
+		if len(payload.Operators[metric.Type]) == 0 {
+			t.Fatalf("expected operators for %s (type %q)", metric.ID, metric.Type)
+		}
+	}
+	for _, valueType := range []string{"number", "enum", "bool"} {
+		if len(payload.Operators[valueType]) == 0 {
+			t.Fatalf("expected %s operators, got %v", valueType, payload.Operators)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	server.RulesMetadata(rec, httptest.NewRequest(http.MethodPost, "/api/rules/metadata", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestRulesMetadata_HonorsIfNoneMatch(t *testing.T) {
+	server, store := newSessionTestServer(t, "metadata-secret", "")
+	if err := store.UpsertStravaToken(context.Background(), storage.StravaToken{UserID: 21, AccessToken: "token", AthleteID: 21}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	req := sessionRequest(t, server, 21)
+	req.URL.Path = "/api/rules/metadata"
+	rec := httptest.NewRecorder()
+	server.RulesMetadata(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+
+	req = sessionRequest(t, server, 21)
+	req.URL.Path = "/api/rules/metadata"
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	server.RulesMetadata(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected empty body on 304, got %q", rec.Body.String())
+	}
+
+	req = sessionRequest(t, server, 21)
+	req.URL.Path = "/api/rules/metadata"
+	req.Header.Set("If-None-Match", `W/"stale"`)
+	rec = httptest.NewRecorder()
+	server.RulesMetadata(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a stale ETag, got %d", rec.Code)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := `W/"abc"`
+	cases := map[string]bool{
+		"":               false,
+		`W/"abc"`:        true,
+		`"abc"`:          true,
+		`W/"x", W/"abc"`: true,
+		"*":              true,
+		`W/"abcd"`:       false,
+	}
+	for header, want := range cases {
+		if got := etagMatches(header, etag); got != want {
+			t.Fatalf("etagMatches(%q) = %t, want %t", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"weirdstats/internal/gps"
+	"weirdstats/internal/storage"
+)
+
+func newSessionTestServer(t *testing.T, secret string, authBaseURL string) (*Server, *storage.Store) {
+	t.Helper()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.InitSchema(context.Background()); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	server, err := NewServer(store, nil, nil, nil, gps.StopOptions{}, StravaConfig{
+		ClientID:      "client-123",
+		ClientSecret:  "secret-123",
+		AuthBaseURL:   authBaseURL,
+		SessionSecret: secret,
+	})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return server, store
+}
+
+func sessionRequest(t *testing.T, server *Server, userID int64) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/activities/", nil)
+	if err := server.setSession(rec, req, userID); err != nil {
+		t.Fatalf("set session: %v", err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestCurrentUserID_ResolvesSignedSession(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "session-secret", "")
+	for _, id := range []int64{42, 43} {
+		if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: id, AccessToken: "token", AthleteID: id}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+
+	for _, id := range []int64{42, 43} {
+		got, ok := server.currentUserID(ctx, sessionRequest(t, server, id))
+		if !ok || got != id {
+			t.Fatalf("expected user %d, got %d (ok=%t)", id, got, ok)
+		}
+	}
+
+	if _, ok := server.currentUserID(ctx, httptest.NewRequest(http.MethodGet, "/", nil)); ok {
+		t.Fatalf("expected no user without a session cookie")
+	}
+	if _, ok := server.currentUserID(ctx, sessionRequest(t, server, 99)); ok {
+		t.Fatalf("expected session for unknown user to be rejected")
+	}
+
+	other, _ := newSessionTestServer(t, "other-secret", "")
+	if _, ok := server.currentUserID(ctx, sessionRequest(t, other, 42)); ok {
+		t.Fatalf("expected session signed with another secret to be rejected")
+	}
+
+	tampered := httptest.NewRequest(http.MethodGet, "/", nil)
+	cookie, err := sessionRequest(t, server, 42).Cookie(sessionCookieName)
+	if err != nil {
+		t.Fatalf("session cookie: %v", err)
+	}
+	payload, sig, _ := strings.Cut(cookie.Value, ".")
+	tampered.AddCookie(&http.Cookie{Name: sessionCookieName, Value: payload + "x." + sig})
+	if _, ok := server.currentUserID(ctx, tampered); ok {
+		t.Fatalf("expected tampered session to be rejected")
+	}
+}
+
+func TestStravaCallback_SignsInEachAthleteAsTheirOwnUser(t *testing.T) {
+	ctx := context.Background()
+	athletes := map[string]int64{"code-a": 501, "code-b": 502}
+	stravaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"access_token":  "strava-access",
+			"refresh_token": "strava-refresh",
+			"expires_at":    time.Now().Add(time.Hour).Unix(),
+			"athlete":       map[string]any{"id": athletes[r.Form.Get("code")], "firstname": "Rider"},
+		})
+	}))
+	defer stravaServer.Close()
+
+	server, store := newSessionTestServer(t, "session-secret", stravaServer.URL)
+
+	for code, athleteID := range athletes {
+		req := httptest.NewRequest(http.MethodGet, "/connect/strava/callback?state=s1&code="+url.QueryEscape(code), nil)
+		req.AddCookie(&http.Cookie{Name: oauthStateCookieName, Value: "s1"})
+		rec := httptest.NewRecorder()
+
+		server.StravaCallback(rec, req)
+
+		if rec.Code != http.StatusFound {
+			t.Fatalf("expected redirect, got %d", rec.Code)
+		}
+		var session *http.Cookie
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == sessionCookieName && cookie.Value != "" {
+				session = cookie
+			}
+		}
+		if session == nil {
+			t.Fatalf("expected session cookie for athlete %d", athleteID)
+		}
+		next := httptest.NewRequest(http.MethodGet, "/activities/", nil)
+		next.AddCookie(session)
+		if got, ok := server.currentUserID(ctx, next); !ok || got != athleteID {
+			t.Fatalf("expected session user %d, got %d (ok=%t)", athleteID, got, ok)
+		}
+		if _, err := store.GetStravaToken(ctx, athleteID); err != nil {
+			t.Fatalf("expected token stored for athlete %d: %v", athleteID, err)
+		}
+	}
+
+	if count, err := store.CountUsers(ctx); err != nil || count != 2 {
+		t.Fatalf("expected 2 users, got %d (err=%v)", count, err)
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"weirdstats/internal/gps"
+	"weirdstats/internal/ingest"
 	"weirdstats/internal/storage"
+	"weirdstats/internal/strava"
 )
 
 func TestSettings_ShowsFactPreferences(t *testing.T) {
@@ -133,6 +139,7 @@ func TestSettings_UpdateFacts(t *testing.T) {
 	for _, cookie := range sessionRec.Result().Cookies() {
 		req.AddCookie(cookie)
 	}
+	req.Header.Set(csrfHeaderName, server.csrfToken(req))
 	rec := httptest.NewRecorder()
 
 	server.Settings(rec, req)
@@ -223,3 +230,351 @@ func TestSettings_ShowsPaceRuleTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestSettings_AddRuleReappliesHideRulesWithoutStrava(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{
+		UserID:      505,
+		AccessToken: "token",
+	}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	start := time.Date(2026, time.March, 20, 8, 0, 0, 0, time.UTC)
+	shortID, err := store.InsertActivity(ctx, storage.Activity{
+		UserID:    505,
+		Type:      "Ride",
+		Name:      "Short Ride",
+		StartTime: start,
+		Distance:  5000,
+	}, []gps.Point{{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6}})
+	if err != nil {
+		t.Fatalf("insert short activity: %v", err)
+	}
+	longID, err := store.InsertActivity(ctx, storage.Activity{
+		UserID:    505,
+		Type:      "Ride",
+		Name:      "Long Ride",
+		StartTime: start.Add(24 * time.Hour),
+		Distance:  50000,
+	}, []gps.Point{{Lat: 52.52, Lon: 13.405, Time: start.Add(24 * time.Hour), Speed: 6}})
+	if err != nil {
+		t.Fatalf("insert long activity: %v", err)
+	}
+
+	var stravaCalls int32
+	stravaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stravaCalls, 1)
+		http.Error(w, "unexpected call", http.StatusInternalServerError)
+	}))
+	defer stravaServer.Close()
+
+	ingestor := &ingest.Ingestor{
+		Store:  store,
+		Strava: &strava.Client{BaseURL: stravaServer.URL, AccessToken: "token"},
+	}
+	server, err := NewServer(store, ingestor, nil, nil, gps.StopOptions{}, StravaConfig{})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("action", "add-rule")
+	form.Set("name", "Hide short rides")
+	form.Set("condition", `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[20000]}],"action":{"type":"hide"}}`)
+	form.Set("enabled", "on")
+	req := httptest.NewRequest(http.MethodPost, "/activities/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	sessionRec := httptest.NewRecorder()
+	if err := server.setSession(sessionRec, req, 505); err != nil {
+		t.Fatalf("set session: %v", err)
+	}
+	for _, cookie := range sessionRec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	req.Header.Set(csrfHeaderName, server.csrfToken(req))
+	rec := httptest.NewRecorder()
+
+	server.Settings(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "/activities/settings?msg=rule+added" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	server.background.Wait()
+
+	short, err := store.GetActivity(ctx, shortID)
+	if err != nil {
+		t.Fatalf("get short activity: %v", err)
+	}
+	if !short.HiddenByRule {
+		t.Fatalf("expected short activity to be hidden by rule")
+	}
+	long, err := store.GetActivity(ctx, longID)
+	if err != nil {
+		t.Fatalf("get long activity: %v", err)
+	}
+	if long.HiddenByRule {
+		t.Fatalf("expected long activity to stay visible")
+	}
+	if calls := atomic.LoadInt32(&stravaCalls); calls != 0 {
+		t.Fatalf("expected no strava calls, got %d", calls)
+	}
+}
+
+func TestSettings_AddRuleRejectsInvalidRule(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "settings-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 606, AccessToken: "token", AthleteID: 606}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		wantMsg   string
+	}{
+		{
+			name:      "malformed json",
+			condition: `{"match":"all","conditions":[`,
+			wantMsg:   "invalid rule json: ",
+		},
+		{
+			name:      "unknown metric",
+			condition: `{"match":"all","conditions":[{"metric":"banana_count","op":"gt","values":[1]}],"action":{"type":"hide"}}`,
+			wantMsg:   "invalid rule definition: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("action", "add-rule")
+			form.Set("name", "Broken rule")
+			form.Set("condition", tt.condition)
+			form.Set("enabled", "on")
+			req := httptest.NewRequest(http.MethodPost, "/activities/settings", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range sessionRequest(t, server, 606).Cookies() {
+				req.AddCookie(cookie)
+			}
+			req.Header.Set(csrfHeaderName, server.csrfToken(req))
+			rec := httptest.NewRecorder()
+
+			server.Settings(rec, req)
+
+			if rec.Code != http.StatusFound {
+				t.Fatalf("expected redirect, got %d", rec.Code)
+			}
+			location, err := url.Parse(rec.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("parse redirect: %v", err)
+			}
+			msg := location.Query().Get("msg")
+			if !strings.HasPrefix(msg, tt.wantMsg) || len(msg) == len(tt.wantMsg) {
+				t.Fatalf("expected specific error after %q, got %q", tt.wantMsg, msg)
+			}
+			stored, err := store.ListHideRules(ctx, 606)
+			if err != nil {
+				t.Fatalf("list rules: %v", err)
+			}
+			if len(stored) != 0 {
+				t.Fatalf("expected invalid rule not stored, got %d rules", len(stored))
+			}
+		})
+	}
+}
+
+func postSettingsForm(t *testing.T, server *Server, userID int64, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/activities/settings", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range sessionRequest(t, server, userID).Cookies() {
+		req.AddCookie(cookie)
+	}
+	req.Header.Set(csrfHeaderName, server.csrfToken(req))
+	rec := httptest.NewRecorder()
+	server.Settings(rec, req)
+	return rec
+}
+
+func TestSettings_EditRule(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "settings-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 707, AccessToken: "token", AthleteID: 707}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	original := `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[20000]}],"action":{"type":"hide"}}`
+	ruleID, err := store.CreateHideRule(ctx, storage.HideRule{UserID: 707, Name: "Short rides", Condition: original, Enabled: true})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("action", "edit-rule")
+	form.Set("rule_id", strconv.FormatInt(ruleID, 10))
+	form.Set("name", "Broken")
+	form.Set("condition", `{"match":"all","conditions":[{"metric":"banana_count","op":"gt","values":[1]}],"action":{"type":"hide"}}`)
+	rec := postSettingsForm(t, server, 707, form)
+	if got := rec.Header().Get("Location"); !strings.Contains(got, "msg=invalid+rule+definition") {
+		t.Fatalf("expected invalid rule redirect, got %q", got)
+	}
+	list, err := store.ListHideRules(ctx, 707)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "Short rides" || list[0].Condition != original {
+		t.Fatalf("expected rule unchanged after rejected edit, got %+v", list)
+	}
+
+	form.Set("name", "Very short rides")
+	form.Set("condition", `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[5000]}],"action":{"type":"hide"}}`)
+	rec = postSettingsForm(t, server, 707, form)
+	if got := rec.Header().Get("Location"); got != "/activities/settings?msg=rule+updated" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	list, err = store.ListHideRules(ctx, 707)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != ruleID || list[0].Name != "Very short rides" {
+		t.Fatalf("expected rule edited in place, got %+v", list)
+	}
+	if !strings.Contains(list[0].Condition, "5000") {
+		t.Fatalf("expected new condition stored, got %s", list[0].Condition)
+	}
+}
+
+func TestSettings_EditRuleOfAnotherUserIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "settings-secret", "")
+	for _, userID := range []int64{707, 717} {
+		if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: userID, AccessToken: "token", AthleteID: userID}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+	original := `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[20000]}],"action":{"type":"hide"}}`
+	ruleID, err := store.CreateHideRule(ctx, storage.HideRule{UserID: 707, Name: "Short rides", Condition: original, Enabled: true})
+	if err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("action", "edit-rule")
+	form.Set("rule_id", strconv.FormatInt(ruleID, 10))
+	form.Set("name", "Hijacked")
+	form.Set("condition", `{"match":"all","conditions":[{"metric":"distance_m","op":"lt","values":[5000]}],"action":{"type":"hide"}}`)
+	if rec := postSettingsForm(t, server, 717, form); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 editing another user's rule, got %d", rec.Code)
+	}
+	list, err := store.ListHideRules(ctx, 707)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "Short rides" || list[0].Condition != original {
+		t.Fatalf("expected rule untouched, got %+v", list)
+	}
+}
+
+func TestSettings_UpdateDescriptionTemplate(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "settings-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 708, AccessToken: "token", AthleteID: 708}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("action", "update-description-template")
+	form.Set("description_template", "{{.StopCount}} stops ({{.StopTotal}} total)")
+	if rec := postSettingsForm(t, server, 708, form); rec.Code != http.StatusFound || !strings.Contains(rec.Header().Get("Location"), "description+template+updated") {
+		t.Fatalf("expected template saved, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if got, err := store.GetUserSetting(ctx, 708, descriptionTemplateSettingKey); err != nil || got != "{{.StopCount}} stops ({{.StopTotal}} total)" {
+		t.Fatalf("expected stored template, got %q (err=%v)", got, err)
+	}
+
+	form.Set("description_template", "{{.Nope}}")
+	rec := postSettingsForm(t, server, 708, form)
+	if !strings.Contains(rec.Header().Get("Location"), "invalid+description+template") {
+		t.Fatalf("expected invalid template message, got %q", rec.Header().Get("Location"))
+	}
+	if got, _ := store.GetUserSetting(ctx, 708, descriptionTemplateSettingKey); got != "{{.StopCount}} stops ({{.StopTotal}} total)" {
+		t.Fatalf("expected invalid template to be ignored, got %q", got)
+	}
+
+	form.Set("description_template", "")
+	postSettingsForm(t, server, 708, form)
+	if got, _ := store.GetUserSetting(ctx, 708, descriptionTemplateSettingKey); got != "" {
+		t.Fatalf("expected template reset to default, got %q", got)
+	}
+}
+
+func TestSettings_ReprocessAllEnqueuesActivitiesOncePerCooldown(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "settings-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 808, AccessToken: "token", AthleteID: 808}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC)
+	for i, activity := range []storage.Activity{
+		{ID: 8001, UserID: 808},
+		{ID: 8002, UserID: 808},
+		{ID: 8003, UserID: 808},
+		{ID: 9001, UserID: 909},
+	} {
+		activity.Type = "Ride"
+		activity.Name = "Ride"
+		activity.StartTime = start.Add(time.Duration(i) * time.Hour)
+		if _, err := store.InsertActivity(ctx, activity, nil); err != nil {
+			t.Fatalf("insert activity %d: %v", activity.ID, err)
+		}
+	}
+
+	form := url.Values{}
+	form.Set("action", "reprocess-all")
+	rec := postSettingsForm(t, server, 808, form)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "/activities/settings?msg=queued+3+activities+for+reprocessing" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	jobs, err := store.ListJobsByType(ctx, "process_activity", 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 queued jobs, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if strings.Contains(job.Payload, "9001") {
+			t.Fatalf("queued another user's activity: %s", job.Payload)
+		}
+	}
+
+	rec = postSettingsForm(t, server, 808, form)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if msg := location.Query().Get("msg"); !strings.Contains(msg, "try again later") {
+		t.Fatalf("expected second call to be rejected, got %q", msg)
+	}
+	if err := store.SetUserSetting(ctx, 808, reprocessAllSettingKey, strconv.FormatInt(time.Now().Add(-reprocessAllCooldown).Unix(), 10)); err != nil {
+		t.Fatalf("rewind cooldown: %v", err)
+	}
+	rec = postSettingsForm(t, server, 808, form)
+	if got := rec.Header().Get("Location"); !strings.Contains(got, "queued+3") {
+		t.Fatalf("expected reprocess after cooldown, got %q", got)
+	}
+}
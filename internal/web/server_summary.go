@@ -0,0 +1,68 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+type typeSummary struct {
+	Activities       int     `json:"activities"`
+	TotalStops       int     `json:"total_stops"`
+	AvgStops         float64 `json:"avg_stops"`
+	TotalStopSeconds int     `json:"total_stop_seconds"`
+	AvgStopSeconds   float64 `json:"avg_stop_seconds"`
+}
+
+type summaryResponse struct {
+	Since string                 `json:"since"`
+	Types map[string]typeSummary `json:"types"`
+}
+
+// SummaryJSON reports per-type stop averages since ?since=YYYY-MM-DD,
+// defaulting to the start of the current month.
+func (s *Server) SummaryJSON(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/summary" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be YYYY-MM-DD"})
+			return
+		}
+		since = parsed
+	}
+
+	aggregates, err := s.store.AggregateStatsByType(r.Context(), userID, since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load summary"})
+		return
+	}
+	resp := summaryResponse{
+		Since: since.Format("2006-01-02"),
+		Types: make(map[string]typeSummary, len(aggregates)),
+	}
+	for activityType, agg := range aggregates {
+		resp.Types[activityType] = typeSummary{
+			Activities:       agg.ActivityCount,
+			TotalStops:       agg.TotalStopCount,
+			AvgStops:         math.Round(agg.AvgStopCount*100) / 100,
+			TotalStopSeconds: agg.TotalStopSeconds,
+			AvgStopSeconds:   math.Round(agg.AvgStopSeconds*100) / 100,
+		}
+	}
+	writeJSONWithETag(w, r, resp)
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"weirdstats/internal/storage"
+)
+
+func TestSummaryJSON_EmptyWithoutData(t *testing.T) {
+	ctx := context.Background()
+	server, store := newSessionTestServer(t, "summary-secret", "")
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 51, AccessToken: "token", AthleteID: 51}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	req := sessionRequest(t, server, 51)
+	req.URL, _ = req.URL.Parse("/api/summary?since=2026-03-01")
+	rec := httptest.NewRecorder()
+	server.SummaryJSON(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"since":"2026-03-01","types":{}}` {
+		t.Fatalf("unexpected body: %s", got)
+	}
+
+	req.URL, _ = req.URL.Parse("/api/summary?since=March")
+	rec = httptest.NewRecorder()
+	server.SummaryJSON(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid since, got %d", rec.Code)
+	}
+}
@@ -671,6 +671,23 @@ func formatPower(watts float64) (string, string, bool) {
 	return fmt.Sprintf("%.0f", math.Round(watts)), "W", true
 }
 
+// formatPercentile renders 89.6 as "90th percentile".
+func formatPercentile(percentile float64) string {
+	n := int(math.Round(percentile))
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s percentile", n, suffix)
+}
+
 func formatEffort(effort float64) string {
 	if effort <= 0 {
 		return "No effort"
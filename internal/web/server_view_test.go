@@ -204,3 +204,20 @@ func TestBuildCoffeeStopDataItem_ExplainsNoNearbyCafe(t *testing.T) {
 		t.Fatalf("expected warning tone, got %q", item.Tone)
 	}
 }
+
+func TestFormatPercentile(t *testing.T) {
+	cases := map[float64]string{
+		89.6: "90th percentile",
+		1:    "1st percentile",
+		22.2: "22nd percentile",
+		43:   "43rd percentile",
+		11:   "11th percentile",
+		12.4: "12th percentile",
+		100:  "100th percentile",
+	}
+	for in, want := range cases {
+		if got := formatPercentile(in); got != want {
+			t.Fatalf("formatPercentile(%.1f) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// Session cookies are signed auth tokens without a kind, which keeps their
+// payload identical to the original {"user_id","expires"} format.
+const sessionTokenKind = ""
+
+func (s *Server) setSession(w http.ResponseWriter, r *http.Request, userID int64) error {
+	value, err := s.issueSignedAuthToken(sessionTokenKind, userID, time.Now().Add(sessionDuration))
+	if err != nil {
+		return err
+	}
+	setCookie(w, r, sessionCookieName, value, int(sessionDuration.Seconds()))
+	return nil
+}
+
+func (s *Server) clearSession(w http.ResponseWriter, r *http.Request) {
+	setCookie(w, r, sessionCookieName, "", -1)
+}
+
+// userFromRequest returns the user ID carried by a valid session cookie. It
+// does not check that the user still exists; currentUserID does.
+func (s *Server) userFromRequest(r *http.Request) (int64, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return 0, false
+	}
+	payload, ok := s.parseSignedAuthToken(cookie.Value, sessionTokenKind)
+	if !ok {
+		return 0, false
+	}
+	return payload.UserID, true
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func requestWithSessionValue(value string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: value})
+	return req
+}
+
+func TestUserFromRequest_RoundTrip(t *testing.T) {
+	server, _ := newSessionTestServer(t, "session-secret", "")
+
+	got, ok := server.userFromRequest(sessionRequest(t, server, 7))
+	if !ok || got != 7 {
+		t.Fatalf("expected user 7, got %d (ok=%t)", got, ok)
+	}
+
+	// Cookies issued before sessions moved onto signed auth tokens carry no kind.
+	legacyPayload := []byte(fmt.Sprintf(`{"user_id":8,"expires":%d}`, time.Now().Add(time.Hour).Unix()))
+	legacy := base64.RawURLEncoding.EncodeToString(legacyPayload) + "." + base64.RawURLEncoding.EncodeToString(server.sign(legacyPayload))
+	if got, ok := server.userFromRequest(requestWithSessionValue(legacy)); !ok || got != 8 {
+		t.Fatalf("expected legacy cookie for user 8, got %d (ok=%t)", got, ok)
+	}
+}
+
+func TestUserFromRequest_RejectsTamperedAndExpired(t *testing.T) {
+	server, _ := newSessionTestServer(t, "session-secret", "")
+
+	valid, err := server.issueSignedAuthToken(sessionTokenKind, 7, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("issue session: %v", err)
+	}
+	payload, sig, _ := strings.Cut(valid, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"user_id":1,"expires":9999999999}`))
+
+	expired, err := server.issueSignedAuthToken(sessionTokenKind, 7, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("issue expired session: %v", err)
+	}
+	bearer, _, err := server.issueBearerToken(7)
+	if err != nil {
+		t.Fatalf("issue bearer token: %v", err)
+	}
+
+	tests := map[string]string{
+		"forged payload":   forged + "." + sig,
+		"truncated sig":    payload + "." + sig[:len(sig)-2],
+		"missing sig":      payload,
+		"expired":          expired,
+		"bearer as cookie": bearer,
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got, ok := server.userFromRequest(requestWithSessionValue(value)); ok {
+				t.Fatalf("expected rejection, got user %d", got)
+			}
+		})
+	}
+}
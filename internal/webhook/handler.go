@@ -4,11 +4,16 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"weirdstats/internal/jobs"
 	"weirdstats/internal/storage"
@@ -28,8 +33,19 @@ type Handler struct {
 	Store         *storage.Store
 	VerifyToken   string
 	SigningSecret string
+	// MaxEventAge rejects events whose event_time is older than this, so a
+	// captured payload cannot be replayed later. Zero disables the check.
+	MaxEventAge time.Duration
+	// MaxBodyBytes caps the request body. Defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	now func() time.Time
 }
 
+// DefaultMaxBodyBytes is far above any real Strava event, which is a few
+// hundred bytes.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if r.Method == http.MethodGet {
@@ -41,8 +57,17 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload, err := io.ReadAll(r.Body)
+	maxBody := h.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "failed to read body", http.StatusBadRequest)
 		return
 	}
@@ -68,6 +93,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf("strava webhook: user=%d type=%s aspect=%s object=%d",
 		event.OwnerID, event.ObjectType, event.AspectType, event.ObjectID)
 
+	if h.isStale(event) {
+		log.Printf("strava webhook: rejecting stale event object=%d event_time=%d", event.ObjectID, event.EventTime)
+		http.Error(w, "stale event", http.StatusBadRequest)
+		return
+	}
 	if err := h.recordEvent(ctx, event, string(payload)); err != nil {
 		http.Error(w, "failed to record event", http.StatusInternalServerError)
 		return
@@ -77,19 +107,44 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) recordEvent(ctx context.Context, event Event, payload string) error {
-	_, err := h.Store.InsertWebhookEvent(ctx, storage.WebhookEvent{
+	inserted, err := h.Store.InsertWebhookEvent(ctx, storage.WebhookEvent{
 		ObjectID:   event.ObjectID,
 		ObjectType: event.ObjectType,
 		AspectType: event.AspectType,
 		OwnerID:    event.OwnerID,
 		RawPayload: payload,
+		EventTime:  event.EventTime,
 	})
 	if err != nil {
 		return err
 	}
+	if !inserted {
+		log.Printf("strava webhook: ignoring duplicate event object=%d aspect=%s event_time=%d",
+			event.ObjectID, event.AspectType, event.EventTime)
+		return nil
+	}
 
-	if event.ObjectType == "activity" && (event.AspectType == "create" || event.AspectType == "update") {
-		if err := jobs.EnqueueProcessActivity(ctx, h.Store, event.ObjectID, event.OwnerID); err != nil {
+	userID, err := h.Store.UserIDForAthlete(ctx, event.OwnerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("strava webhook: ignoring event for unknown athlete %d", event.OwnerID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case event.ObjectType == "activity" && (event.AspectType == "create" || event.AspectType == "update"):
+		if err := jobs.EnqueueProcessActivity(ctx, h.Store, event.ObjectID, userID); err != nil {
+			return err
+		}
+	case event.ObjectType == "activity" && event.AspectType == "delete":
+		if err := h.Store.DeleteActivityForUser(ctx, userID, event.ObjectID); err != nil {
+			return err
+		}
+	case event.ObjectType == "athlete" && event.AspectType == "update" && isDeauthorization(event.Updates):
+		log.Printf("strava webhook: athlete %d deauthorized, deleting user %d", event.OwnerID, userID)
+		if err := h.Store.DeleteUserData(ctx, userID); err != nil {
 			return err
 		}
 	}
@@ -97,6 +152,34 @@ func (h *Handler) recordEvent(ctx context.Context, event Event, payload string)
 	return nil
 }
 
+func (h *Handler) isStale(event Event) bool {
+	if h.MaxEventAge <= 0 || event.EventTime <= 0 {
+		return false
+	}
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+	return now.Sub(time.Unix(event.EventTime, 0)) > h.MaxEventAge
+}
+
+// isDeauthorization reports whether an athlete update revokes access.
+// Strava sends "authorized": "false" as a string, but accept a bool too.
+func isDeauthorization(updates map[string]interface{}) bool {
+	value, ok := updates["authorized"]
+	if !ok {
+		return false
+	}
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), "false")
+	default:
+		return false
+	}
+}
+
 func (h *Handler) handleVerification(w http.ResponseWriter, r *http.Request) {
 	challenge := r.URL.Query().Get("hub.challenge")
 	verifyToken := r.URL.Query().Get("hub.verify_token")
@@ -104,7 +187,7 @@ func (h *Handler) handleVerification(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing challenge", http.StatusBadRequest)
 		return
 	}
-	if h.VerifyToken != "" && verifyToken != h.VerifyToken {
+	if h.VerifyToken != "" && subtle.ConstantTimeCompare([]byte(verifyToken), []byte(h.VerifyToken)) != 1 {
 		http.Error(w, "invalid verify token", http.StatusForbidden)
 		return
 	}
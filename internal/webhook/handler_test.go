@@ -5,11 +5,17 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"weirdstats/internal/gps"
 	"weirdstats/internal/storage"
 )
 
@@ -24,6 +30,9 @@ func TestHandlerStoresEventAndEnqueues(t *testing.T) {
 	if err := store.InitSchema(ctx); err != nil {
 		t.Fatalf("init schema: %v", err)
 	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 7}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
 
 	handler := &Handler{Store: store, SigningSecret: "secret"}
 	payload := []byte(`{"object_type":"activity","object_id":42,"aspect_type":"create","owner_id":7}`)
@@ -53,6 +62,52 @@ func TestHandlerStoresEventAndEnqueues(t *testing.T) {
 	}
 }
 
+func TestHandlerOnlyEnqueuesKnownAthletes(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 3, AccessToken: "token", AthleteID: 700}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	handler := &Handler{Store: store}
+	for _, payload := range [][]byte{
+		[]byte(`{"object_type":"activity","object_id":42,"aspect_type":"create","owner_id":700}`),
+		[]byte(`{"object_type":"activity","object_id":43,"aspect_type":"create","owner_id":999}`),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	count, err := store.CountWebhookEvents(ctx)
+	if err != nil {
+		t.Fatalf("count webhook events: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected both events recorded, got %d", count)
+	}
+	if queueCount, err := store.CountQueue(ctx); err != nil || queueCount != 1 {
+		t.Fatalf("expected only the known athlete's activity queued, got %d (err=%v)", queueCount, err)
+	}
+	job, err := store.ClaimJob(ctx, time.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("claim job: %v", err)
+	}
+	if job.Payload != `{"activity_id":42,"user_id":3}` {
+		t.Fatalf("expected activity 42 queued for user 3, got %s", job.Payload)
+	}
+}
+
 func TestHandlerRejectsMissingFields(t *testing.T) {
 	ctx := context.Background()
 	store, err := storage.Open(":memory:")
@@ -77,6 +132,297 @@ func TestHandlerRejectsMissingFields(t *testing.T) {
 	}
 }
 
+func TestHandlerDeauthorizationDeletesUser(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{
+		UserID:      7,
+		AccessToken: "token",
+		AthleteID:   7,
+	}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	if _, err := store.InsertActivity(ctx, storage.Activity{
+		ID:        42,
+		UserID:    7,
+		Type:      "Ride",
+		Name:      "Morning Ride",
+		StartTime: start,
+	}, []gps.Point{{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6}}); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	handler := &Handler{Store: store, SigningSecret: "secret"}
+	payload := []byte(`{"object_type":"athlete","object_id":7,"aspect_type":"update","owner_id":7,"updates":{"authorized":"false"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Strava-Signature", signPayload(payload, "secret"))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	if _, err := store.GetStravaToken(ctx, 7); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected token to be deleted, got %v", err)
+	}
+	exists, err := store.HasActivity(ctx, 42)
+	if err != nil {
+		t.Fatalf("has activity: %v", err)
+	}
+	if exists {
+		t.Fatalf("expected activity to be deleted")
+	}
+}
+
+func TestHandlerIgnoresDeauthorizationFromForeignAthlete(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	// A lone env-seeded token without an athlete id must not be treated as
+	// the owner of another athlete's events.
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 1, AccessToken: "seeded"}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	if _, err := store.InsertActivity(ctx, storage.Activity{
+		ID:        42,
+		UserID:    1,
+		Type:      "Ride",
+		Name:      "Morning Ride",
+		StartTime: start,
+	}, []gps.Point{{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6}}); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	handler := &Handler{Store: store, SigningSecret: "secret"}
+	payload := []byte(`{"object_type":"athlete","object_id":31337,"aspect_type":"update","owner_id":31337,"updates":{"authorized":"false"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Strava-Signature", signPayload(payload, "secret"))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if _, err := store.GetStravaToken(ctx, 1); err != nil {
+		t.Fatalf("expected seeded token to survive, got %v", err)
+	}
+	exists, err := store.HasActivity(ctx, 42)
+	if err != nil {
+		t.Fatalf("has activity: %v", err)
+	}
+	if !exists {
+		t.Fatalf("expected activity to survive a foreign deauthorization")
+	}
+}
+
+func TestHandlerActivityDeleteRemovesActivity(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 7}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	start := time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC)
+	if _, err := store.InsertActivity(ctx, storage.Activity{
+		ID:        42,
+		UserID:    7,
+		Type:      "Ride",
+		Name:      "Morning Ride",
+		StartTime: start,
+	}, []gps.Point{
+		{Lat: 52.52, Lon: 13.405, Time: start, Speed: 6},
+		{Lat: 52.521, Lon: 13.406, Time: start.Add(10 * time.Second), Speed: 6},
+	}); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+	if err := store.EnqueueActivity(ctx, 42, 7); err != nil {
+		t.Fatalf("enqueue activity: %v", err)
+	}
+
+	handler := &Handler{Store: store, SigningSecret: "secret"}
+	payload := []byte(`{"object_type":"activity","object_id":42,"aspect_type":"delete","owner_id":7}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Strava-Signature", signPayload(payload, "secret"))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	exists, err := store.HasActivity(ctx, 42)
+	if err != nil {
+		t.Fatalf("has activity: %v", err)
+	}
+	if exists {
+		t.Fatalf("expected activity to be deleted")
+	}
+	pointCount, err := store.CountActivityPoints(ctx, 42)
+	if err != nil {
+		t.Fatalf("count points: %v", err)
+	}
+	if pointCount != 0 {
+		t.Fatalf("expected points to be deleted, got %d", pointCount)
+	}
+	queueCount, err := store.CountQueue(ctx)
+	if err != nil {
+		t.Fatalf("count queue: %v", err)
+	}
+	if queueCount != 0 {
+		t.Fatalf("expected no queued activities, got %d", queueCount)
+	}
+}
+
+func TestHandlerActivityDeleteIgnoresOtherOwnersActivity(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	for _, userID := range []int64{7, 8} {
+		if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: userID, AccessToken: "token", AthleteID: userID}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+	if _, err := store.InsertActivity(ctx, storage.Activity{
+		ID:        42,
+		UserID:    7,
+		Type:      "Ride",
+		Name:      "Morning Ride",
+		StartTime: time.Date(2026, time.March, 10, 8, 0, 0, 0, time.UTC),
+	}, nil); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	handler := &Handler{Store: store}
+	payload := []byte(`{"object_type":"activity","object_id":42,"aspect_type":"delete","owner_id":8}`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	exists, err := store.HasActivity(ctx, 42)
+	if err != nil {
+		t.Fatalf("has activity: %v", err)
+	}
+	if !exists {
+		t.Fatalf("expected another owner's delete event to leave the activity")
+	}
+}
+
+func TestHandlerRejectsStaleEvents(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 7}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	now := time.Date(2026, time.May, 1, 12, 0, 0, 0, time.UTC)
+	handler := &Handler{Store: store, SigningSecret: "secret", MaxEventAge: time.Hour, now: func() time.Time { return now }}
+	payload := []byte(fmt.Sprintf(`{"object_type":"activity","object_id":42,"aspect_type":"create","owner_id":7,"event_time":%d}`, now.Add(-2*time.Hour).Unix()))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Strava-Signature", signPayload(payload, "secret"))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a stale event, got %d", rec.Code)
+	}
+	if count, err := store.CountWebhookEvents(ctx); err != nil || count != 0 {
+		t.Fatalf("expected stale event not to be stored, got %d (%v)", count, err)
+	}
+	if queueCount, err := store.CountQueue(ctx); err != nil || queueCount != 0 {
+		t.Fatalf("expected nothing queued, got %d (%v)", queueCount, err)
+	}
+}
+
+func TestHandlerIgnoresDuplicateEvents(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 7}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	handler := &Handler{Store: store, SigningSecret: "secret", MaxEventAge: time.Hour}
+	payload := []byte(fmt.Sprintf(`{"object_type":"activity","object_id":42,"aspect_type":"update","owner_id":7,"event_time":%d}`, time.Now().Unix()))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-Strava-Signature", signPayload(payload, "secret"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("delivery %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+
+	count, err := store.CountWebhookEventsForObject(ctx, "activity", 42)
+	if err != nil {
+		t.Fatalf("count webhook events: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the duplicate to be ignored, got %d stored events", count)
+	}
+}
+
+func TestHandlerRejectsOversizedBody(t *testing.T) {
+	handler := &Handler{MaxBodyBytes: 64}
+	payload := []byte(`{"object_type":"activity","object_id":42,"aspect_type":"create","owner_id":7,"updates":{"title":"` + strings.Repeat("x", 128) + `"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+}
+
 func TestHandlerVerification(t *testing.T) {
 	handler := &Handler{VerifyToken: "verify-token"}
 	req := httptest.NewRequest(http.MethodGet, "/webhook?hub.challenge=abc&hub.verify_token=verify-token", nil)
@@ -91,6 +437,19 @@ func TestHandlerVerification(t *testing.T) {
 	}
 }
 
+func TestHandlerVerificationRejectsWrongToken(t *testing.T) {
+	handler := &Handler{VerifyToken: "verify-token"}
+	for _, token := range []string{"", "verify", "verify-token-extra", "VERIFY-TOKEN"} {
+		req := httptest.NewRequest(http.MethodGet, "/webhook?hub.challenge=abc&hub.verify_token="+token, nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("token %q: expected 403, got %d", token, rec.Code)
+		}
+	}
+}
+
 func signPayload(payload []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	_, _ = mac.Write(payload)
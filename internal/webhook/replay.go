@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"weirdstats/internal/jobs"
+	"weirdstats/internal/storage"
+)
+
+// Replay re-enqueues the user's activities from stored create/update events
+// received since the given time; other athletes' events are never read.
+// Deletes and athlete events are not replayed. The queue skips activities
+// that already have a pending job, so replaying the same window twice is
+// harmless. It returns the number of distinct activities enqueued.
+func Replay(ctx context.Context, store *storage.Store, userID int64, since time.Time, limit int) (int, error) {
+	token, err := store.GetStravaToken(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && token.AthleteID == 0) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	stored, err := store.ListWebhookEvents(ctx, token.AthleteID, since, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[int64]bool)
+	for _, row := range stored {
+		event := replayEvent(row)
+		if event.ObjectType != "activity" || (event.AspectType != "create" && event.AspectType != "update") {
+			continue
+		}
+		if event.ObjectID == 0 || seen[event.ObjectID] {
+			continue
+		}
+		if err := jobs.EnqueueProcessActivity(ctx, store, event.ObjectID, userID); err != nil {
+			return len(seen), err
+		}
+		seen[event.ObjectID] = true
+	}
+	return len(seen), nil
+}
+
+// replayEvent prefers the raw payload Strava sent and falls back to the
+// columns recorded alongside it.
+func replayEvent(row storage.WebhookEvent) Event {
+	var event Event
+	if err := json.Unmarshal([]byte(row.RawPayload), &event); err != nil || event.ObjectID == 0 {
+		if err != nil {
+			log.Printf("webhook replay: event %d has unreadable payload: %v", row.ID, err)
+		}
+		return Event{
+			ObjectType: row.ObjectType,
+			ObjectID:   row.ObjectID,
+			AspectType: row.AspectType,
+			OwnerID:    row.OwnerID,
+		}
+	}
+	return event
+}
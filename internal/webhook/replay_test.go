@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"weirdstats/internal/storage"
+)
+
+func TestReplayEnqueuesStoredActivityEvents(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.InitSchema(ctx); err != nil {
+		t.Fatalf("init schema: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 7, AccessToken: "token", AthleteID: 700}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+	if err := store.UpsertStravaToken(ctx, storage.StravaToken{UserID: 8, AccessToken: "token", AthleteID: 800}); err != nil {
+		t.Fatalf("upsert token: %v", err)
+	}
+
+	now := time.Now()
+	events := []storage.WebhookEvent{
+		{ObjectID: 1, ObjectType: "activity", AspectType: "create", OwnerID: 700, RawPayload: `{"object_type":"activity","object_id":1,"aspect_type":"create","owner_id":700}`, ReceivedAt: now.Add(-2 * time.Hour)},
+		{ObjectID: 1, ObjectType: "activity", AspectType: "update", OwnerID: 700, RawPayload: `{"object_type":"activity","object_id":1,"aspect_type":"update","owner_id":700}`, ReceivedAt: now.Add(-time.Hour)},
+		{ObjectID: 2, ObjectType: "activity", AspectType: "update", OwnerID: 700, RawPayload: `not json`, ReceivedAt: now.Add(-time.Hour)},
+		{ObjectID: 3, ObjectType: "activity", AspectType: "delete", OwnerID: 700, RawPayload: `{"object_type":"activity","object_id":3,"aspect_type":"delete","owner_id":700}`, ReceivedAt: now.Add(-time.Hour)},
+		{ObjectID: 6, ObjectType: "activity", AspectType: "create", OwnerID: 800, RawPayload: `{"object_type":"activity","object_id":6,"aspect_type":"create","owner_id":800}`, ReceivedAt: now.Add(-time.Hour)},
+		{ObjectID: 4, ObjectType: "activity", AspectType: "create", OwnerID: 999, RawPayload: `{"object_type":"activity","object_id":4,"aspect_type":"create","owner_id":999}`, ReceivedAt: now.Add(-time.Hour)},
+		{ObjectID: 5, ObjectType: "activity", AspectType: "create", OwnerID: 700, RawPayload: `{"object_type":"activity","object_id":5,"aspect_type":"create","owner_id":700}`, ReceivedAt: now.Add(-72 * time.Hour)},
+	}
+	for _, event := range events {
+		if _, err := store.InsertWebhookEvent(ctx, event); err != nil {
+			t.Fatalf("insert webhook event: %v", err)
+		}
+	}
+
+	since := now.Add(-24 * time.Hour)
+	for i := 0; i < 2; i++ {
+		enqueued, err := Replay(ctx, store, 7, since, 100)
+		if err != nil {
+			t.Fatalf("replay %d: %v", i, err)
+		}
+		if enqueued != 2 {
+			t.Fatalf("replay %d: expected activities 1 and 2 enqueued, got %d", i, enqueued)
+		}
+		queueCount, err := store.CountQueue(ctx)
+		if err != nil {
+			t.Fatalf("count queue: %v", err)
+		}
+		if queueCount != 2 {
+			t.Fatalf("replay %d: expected 2 queued jobs, got %d", i, queueCount)
+		}
+	}
+
+	jobRows, err := store.ListJobsByType(ctx, "process_activity", 10)
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	queued := map[int64]bool{}
+	for _, job := range jobRows {
+		var payload struct {
+			ActivityID int64 `json:"activity_id"`
+			UserID     int64 `json:"user_id"`
+		}
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if payload.UserID != 7 {
+			t.Fatalf("expected jobs for user 7, got %+v", payload)
+		}
+		queued[payload.ActivityID] = true
+	}
+	if len(queued) != 2 || !queued[1] || !queued[2] {
+		t.Fatalf("expected activities 1 and 2 queued, got %v", queued)
+	}
+	if queued[6] {
+		t.Fatalf("user 7 replayed user 8's activity")
+	}
+
+	enqueued, err := Replay(ctx, store, 9, since, 100)
+	if err != nil || enqueued != 0 {
+		t.Fatalf("expected nothing replayed for a user without a token, got %d (err=%v)", enqueued, err)
+	}
+}
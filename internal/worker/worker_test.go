@@ -8,19 +8,11 @@ import (
 	"weirdstats/internal/gps"
 	"weirdstats/internal/jobs"
 	"weirdstats/internal/maps"
+	"weirdstats/internal/maps/mapstest"
 	"weirdstats/internal/processor"
 	"weirdstats/internal/storage"
 )
 
-type fakeMapAPI struct{}
-
-func (f fakeMapAPI) NearbyFeatures(lat, lon float64) ([]maps.Feature, error) {
-	if lat == 1 {
-		return []maps.Feature{{Type: maps.FeatureTrafficLight, Name: "Main St"}}, nil
-	}
-	return nil, nil
-}
-
 func TestWorkerProcessesQueue(t *testing.T) {
 	ctx := context.Background()
 	store, err := storage.Open(":memory:")
@@ -59,9 +51,15 @@ func TestWorkerProcessesQueue(t *testing.T) {
 		t.Fatalf("enqueue activity: %v", err)
 	}
 
+	mapAPI := &mapstest.StubAPI{Lookup: func(lat, lon float64) []maps.Feature {
+		if lat == 1 {
+			return []maps.Feature{{Type: maps.FeatureTrafficLight, Name: "Main St"}}
+		}
+		return nil
+	}}
 	statsProcessor := &processor.StopStatsProcessor{
 		Store:   store,
-		MapAPI:  fakeMapAPI{},
+		MapAPI:  mapAPI,
 		Options: gps.StopOptions{SpeedThreshold: 0.5, MinDuration: time.Minute},
 	}
 
@@ -88,6 +86,9 @@ func TestWorkerProcessesQueue(t *testing.T) {
 	if stats.TrafficLightStopCount != 1 {
 		t.Fatalf("expected traffic light stop count 1, got %d", stats.TrafficLightStopCount)
 	}
+	if queries := mapAPI.Queries(); len(queries) != 2 || queries[0] != (mapstest.Query{Lat: 1, Lon: 1}) || queries[1] != (mapstest.Query{Lat: 2, Lon: 2}) {
+		t.Fatalf("expected lookups at both stops, got %v", queries)
+	}
 	if stats.RoadCrossingCount != 0 {
 		t.Fatalf("expected road crossing count 0, got %d", stats.RoadCrossingCount)
 	}